@@ -0,0 +1,175 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/pointcloud"
+
+	"go.viam.com/rplidar/background"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	getForegroundCommand     = "get_foreground"
+	relearnBackgroundCommand = "relearn_background"
+	saveBackgroundCommand    = "save_background"
+
+	// foregroundExtraKey is the NextPointCloud extra that asks for the foreground of the most recent revolution.
+	foregroundExtraKey = "foreground"
+)
+
+// backgroundSubtractor splits each revolution into the learned background and the foreground in front of it.
+type backgroundSubtractor struct {
+	referencePath string
+
+	mutex      sync.Mutex
+	model      *background.Model
+	foreground []nodes.Node
+	// backgroundPoints is the number of returns of the most recent revolution that were background.
+	backgroundPoints int
+	timestamp        time.Time
+}
+
+// setupBackground creates the background model when configured, loading it from its reference file if there is
+// one.
+func (rp *rplidar) setupBackground(conf *Config) error {
+	if conf.Background == nil {
+		return nil
+	}
+	b := &backgroundSubtractor{referencePath: conf.Background.ReferencePath, model: background.NewModel(*conf.Background)}
+	if b.referencePath != "" {
+		//nolint:gosec
+		f, err := os.Open(b.referencePath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			rp.logger.Infof("background: %q does not exist yet, learning the background", b.referencePath)
+		case err != nil:
+			return errors.Wrap(err, "could not open the background reference")
+		default:
+			//nolint:errcheck
+			defer f.Close()
+			if err := b.model.Load(f); err != nil {
+				return errors.Wrapf(err, "could not load the background reference %q", b.referencePath)
+			}
+		}
+	}
+	rp.background = b
+	return nil
+}
+
+// subtractBackground learns from a filtered revolution while the background is being learned, and keeps its
+// foreground afterwards.
+func (rp *rplidar) subtractBackground(scanned []nodes.Node, now time.Time) {
+	b := rp.background
+	if b == nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	foreground, learned := b.model.Update(scanned)
+	if learned && !b.model.Learning() {
+		rp.logger.Infof("background: learned from %d revolutions", b.model.Revolutions())
+	}
+	b.foreground = foreground
+	b.backgroundPoints = len(scanned) - len(foreground)
+	b.timestamp = now
+}
+
+// foregroundPointCloud returns the foreground of the most recent revolution, which may be empty.
+func (rp *rplidar) foregroundPointCloud() (pointcloud.PointCloud, error) {
+	b := rp.background
+	if b == nil {
+		return nil, errors.New("background is not configured")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.model.Learning() {
+		return nil, errors.New("the background is still being learned")
+	}
+	pc, err := pointCloudFromNodes(b.foreground)
+	if err != nil || pc != nil {
+		return pc, err
+	}
+	return pointcloud.NewBasicPointCloud(0), nil
+}
+
+// getForeground returns the foreground returns of the most recent revolution, in the layout of a streamed
+// revolution, and the number of its background returns, along with the progress of learning.
+func (rp *rplidar) getForeground(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	b := rp.background
+	if b == nil {
+		return nil, errors.New("background is not configured")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	out := map[string]interface{}{
+		"learning":            b.model.Learning(),
+		"learned_revolutions": float64(b.model.Revolutions()),
+	}
+	if !b.model.Learning() && !b.timestamp.IsZero() {
+		out["foreground"] = publisher.Revolution{Timestamp: b.timestamp, Nodes: b.foreground}.Map()
+		out["background_points"] = float64(b.backgroundPoints)
+	}
+	return out, nil
+}
+
+// relearnBackground forgets the background, so that it is learned again from the next revolutions.
+func (rp *rplidar) relearnBackground(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	b := rp.background
+	if b == nil {
+		return nil, errors.New("background is not configured")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.model.Reset()
+	b.foreground = nil
+	return map[string]interface{}{"learning": true}, nil
+}
+
+// saveBackground writes the learned background to the path given as {"path": ...}, or to the configured
+// reference_path, so that it is loaded instead of learned when the camera restarts.
+func (rp *rplidar) saveBackground(_ context.Context, args interface{}) (resp map[string]interface{}, err error) {
+	b := rp.background
+	if b == nil {
+		return nil, errors.New("background is not configured")
+	}
+	path := b.referencePath
+	if m, ok := args.(map[string]interface{}); ok {
+		if p, ok := m["path"].(string); ok && p != "" {
+			path = p
+		}
+	}
+	if path == "" {
+		return nil, errors.New("a path must be given when reference_path is not configured")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.model.Learning() {
+		return nil, errors.New("the background is still being learned")
+	}
+	//nolint:gosec
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			resp, err = nil, closeErr
+		}
+	}()
+	if err := b.model.Save(f); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"path": path}, nil
+}
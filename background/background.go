@@ -0,0 +1,189 @@
+// Package background learns the static scene around a stationary RPLiDAR, so that the returns of new objects can be
+// told apart from the walls and furniture that were there all along. All distances are in millimeters.
+package background
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultLearnRevolutions = 50
+	defaultBinDeg           = 1.
+	defaultThresholdMM      = 100.
+	defaultSigmas           = 3.
+)
+
+// Config describes how the background is learned and how far in front of it a return must be to be foreground.
+type Config struct {
+	// LearnRevolutions is the number of revolutions the background is learned from, during which the scene must be
+	// empty of anything that should later be foreground.
+	LearnRevolutions int `json:"learn_revolutions,omitempty"`
+	// BinDeg is the width of the angular bins the background is learned in.
+	BinDeg float64 `json:"bin_deg,omitempty"`
+	// ThresholdMM is the smallest distance in front of the background at which a return is foreground.
+	ThresholdMM float64 `json:"threshold_mm,omitempty"`
+	// Sigmas widens the threshold of noisy bins to this many standard deviations of their range.
+	Sigmas float64 `json:"sigmas,omitempty"`
+	// ReferencePath is a file to load the background from instead of learning it, when it exists. The learned
+	// background is only written to it by the save_background DoCommand.
+	ReferencePath string `json:"reference_path,omitempty"`
+}
+
+// Validate checks that the background attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.LearnRevolutions < 0 {
+		return errors.New("learn_revolutions must be positive")
+	}
+	if conf.BinDeg < 0 || conf.BinDeg > 90 {
+		return errors.New("bin_deg must be between 0 and 90")
+	}
+	if conf.ThresholdMM < 0 || conf.Sigmas < 0 {
+		return errors.New("threshold_mm and sigmas must be positive")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.LearnRevolutions == 0 {
+		conf.LearnRevolutions = defaultLearnRevolutions
+	}
+	if conf.BinDeg == 0 {
+		conf.BinDeg = defaultBinDeg
+	}
+	if conf.ThresholdMM == 0 {
+		conf.ThresholdMM = defaultThresholdMM
+	}
+	if conf.Sigmas == 0 {
+		conf.Sigmas = defaultSigmas
+	}
+	return conf
+}
+
+// Bin is the distribution of the background's range within an angular bin.
+type Bin struct {
+	// Count is the number of returns the bin was learned from. A bin without returns has no background, so any
+	// return in it is foreground.
+	Count  int     `json:"count"`
+	MeanMM float64 `json:"mean_mm"`
+	// m2 is the sum of squared differences from the mean, accumulated with Welford's algorithm.
+	m2 float64
+	// StdMM is the standard deviation of the range, kept up to date by learn.
+	StdMM float64 `json:"std_mm"`
+}
+
+func (b *Bin) learn(rangeMM float64) {
+	b.Count++
+	delta := rangeMM - b.MeanMM
+	b.MeanMM += delta / float64(b.Count)
+	b.m2 += delta * (rangeMM - b.MeanMM)
+	b.StdMM = math.Sqrt(b.m2 / float64(b.Count))
+}
+
+// Model is the background of the scene, one range distribution per angular bin. It is not safe for concurrent use.
+type Model struct {
+	conf        Config
+	binDeg      float64
+	bins        []Bin
+	revolutions int
+	// loaded is set when the background was loaded rather than learned.
+	loaded bool
+}
+
+// NewModel returns a model that learns the background from the next revolutions.
+func NewModel(conf Config) *Model {
+	conf = conf.withDefaults()
+	m := &Model{conf: conf, binDeg: conf.BinDeg}
+	m.Reset()
+	return m
+}
+
+// Reset forgets the background, so that it is learned again from the next revolutions.
+func (m *Model) Reset() {
+	m.binDeg = m.conf.BinDeg
+	m.bins = make([]Bin, int(math.Ceil(360/m.binDeg)))
+	m.revolutions = 0
+	m.loaded = false
+}
+
+// Learning reports whether the background is still being learned.
+func (m *Model) Learning() bool {
+	return !m.loaded && m.revolutions < m.conf.LearnRevolutions
+}
+
+// Revolutions returns the number of revolutions the background was learned from.
+func (m *Model) Revolutions() int {
+	return m.revolutions
+}
+
+func (m *Model) bin(n nodes.Node) *Bin {
+	i := int(math.Mod(n.Angle, 360) / m.binDeg)
+	if i < 0 || i >= len(m.bins) {
+		i = 0
+	}
+	return &m.bins[i]
+}
+
+// Update learns from a revolution while the background is being learned, and returns its foreground returns
+// afterwards. It reports whether the revolution was learned from.
+func (m *Model) Update(revolution []nodes.Node) ([]nodes.Node, bool) {
+	if m.Learning() {
+		for _, n := range revolution {
+			m.bin(n).learn(n.Distance)
+		}
+		m.revolutions++
+		return nil, true
+	}
+	return m.Foreground(revolution), false
+}
+
+// Foreground returns the returns of a revolution that are clearly in front of the background, or in a bin where no
+// background was seen. Returns beyond the background, such as through a door that was opened, are not foreground.
+func (m *Model) Foreground(revolution []nodes.Node) []nodes.Node {
+	var foreground []nodes.Node
+	for _, n := range revolution {
+		b := m.bin(n)
+		if b.Count == 0 || n.Distance < b.MeanMM-math.Max(m.conf.ThresholdMM, m.conf.Sigmas*b.StdMM) {
+			foreground = append(foreground, n)
+		}
+	}
+	return foreground
+}
+
+// modelJSON is the layout of a saved background.
+type modelJSON struct {
+	BinDeg      float64 `json:"bin_deg"`
+	Revolutions int     `json:"revolutions"`
+	Bins        []Bin   `json:"bins"`
+}
+
+// Save writes the background as JSON.
+func (m *Model) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(modelJSON{BinDeg: m.binDeg, Revolutions: m.revolutions, Bins: m.bins})
+}
+
+// Load replaces the background with one written by Save, which may have been learned with other bins. The model
+// is no longer learning afterwards.
+func (m *Model) Load(r io.Reader) error {
+	var saved modelJSON
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return errors.Wrap(err, "invalid background")
+	}
+	if saved.BinDeg <= 0 || len(saved.Bins) != int(math.Ceil(360/saved.BinDeg)) {
+		return errors.New("invalid background: the bins do not cover a revolution")
+	}
+	for i := range saved.Bins {
+		b := &saved.Bins[i]
+		b.m2 = b.StdMM * b.StdMM * float64(b.Count)
+	}
+	m.binDeg = saved.BinDeg
+	m.bins = saved.Bins
+	m.revolutions = saved.Revolutions
+	m.loaded = true
+	return nil
+}
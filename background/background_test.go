@@ -0,0 +1,119 @@
+package background
+
+import (
+	"bytes"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{LearnRevolutions: -1}, "learn_revolutions must be positive"},
+		{Config{BinDeg: 100}, "bin_deg must be between 0 and 90"},
+		{Config{Sigmas: -1}, "threshold_mm and sigmas must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// room returns a revolution of a room whose wall is 3 m away, with a noisy shelf 2 m away from 90 to 100 degrees
+// and no return from 180 to 190 degrees, where a door opens onto a long corridor.
+func room(revolution int) []nodes.Node {
+	var scanned []nodes.Node
+	for angle := 0.5; angle < 360; angle++ {
+		distance := 3000.
+		switch {
+		case angle > 90 && angle < 100:
+			// The shelf's range varies by 100 mm from one revolution to the next.
+			distance = 2000 + 50*float64(revolution%3-1)
+		case angle > 180 && angle < 190:
+			continue
+		}
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: distance})
+	}
+	return scanned
+}
+
+// withNode returns the revolution with the return at an angle replaced.
+func withNode(revolution []nodes.Node, n nodes.Node) []nodes.Node {
+	out := append([]nodes.Node{}, revolution...)
+	for i := range out {
+		if out[i].Angle == n.Angle {
+			out[i] = n
+			return out
+		}
+	}
+	return append(out, n)
+}
+
+func TestModel(t *testing.T) {
+	m := NewModel(Config{LearnRevolutions: 3})
+	test.That(t, m.Learning(), test.ShouldBeTrue)
+	for i := 0; i < 3; i++ {
+		foreground, learned := m.Update(room(i))
+		test.That(t, learned, test.ShouldBeTrue)
+		test.That(t, foreground, test.ShouldBeEmpty)
+	}
+	test.That(t, m.Learning(), test.ShouldBeFalse)
+	test.That(t, m.Revolutions(), test.ShouldEqual, 3)
+
+	foreground, learned := m.Update(room(3))
+	test.That(t, learned, test.ShouldBeFalse)
+	test.That(t, foreground, test.ShouldBeEmpty)
+
+	for _, tc := range []struct {
+		name       string
+		n          nodes.Node
+		foreground bool
+	}{
+		{"a person in front of the wall", nodes.Node{Angle: 45.5, Distance: 1500}, true},
+		{"a return just in front of the wall", nodes.Node{Angle: 45.5, Distance: 2950}, false},
+		// The shelf's standard deviation is about 41 mm, so its threshold is about 122 mm.
+		{"a return in the shelf's noise", nodes.Node{Angle: 95.5, Distance: 1890}, false},
+		{"a return in front of the shelf", nodes.Node{Angle: 95.5, Distance: 1850}, true},
+		{"a person in the doorway", nodes.Node{Angle: 185.5, Distance: 2800}, true},
+		{"a return beyond the wall", nodes.Node{Angle: 270.5, Distance: 5000}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			foreground, _ := m.Update(withNode(room(4), tc.n))
+			if tc.foreground {
+				test.That(t, foreground, test.ShouldResemble, []nodes.Node{tc.n})
+			} else {
+				test.That(t, foreground, test.ShouldBeEmpty)
+			}
+		})
+	}
+
+	t.Run("saved backgrounds load into other models", func(t *testing.T) {
+		var buf bytes.Buffer
+		test.That(t, m.Save(&buf), test.ShouldBeNil)
+
+		loaded := NewModel(Config{BinDeg: 5})
+		test.That(t, loaded.Load(&buf), test.ShouldBeNil)
+		test.That(t, loaded.Learning(), test.ShouldBeFalse)
+		test.That(t, loaded.Revolutions(), test.ShouldEqual, 3)
+		test.That(t, len(loaded.bins), test.ShouldEqual, 360)
+		test.That(t, loaded.bins[95].MeanMM, test.ShouldAlmostEqual, m.bins[95].MeanMM)
+		test.That(t, loaded.bins[95].StdMM, test.ShouldAlmostEqual, m.bins[95].StdMM)
+		test.That(t, loaded.bins[185].Count, test.ShouldEqual, 0)
+
+		person := nodes.Node{Angle: 45.5, Distance: 1500}
+		test.That(t, loaded.Foreground(withNode(room(4), person)), test.ShouldResemble, []nodes.Node{person})
+
+		loaded.Reset()
+		test.That(t, loaded.Learning(), test.ShouldBeTrue)
+		test.That(t, len(loaded.bins), test.ShouldEqual, 72)
+
+		test.That(t, loaded.Load(bytes.NewBufferString(`{"bin_deg": 1, "bins": []}`)), test.ShouldNotBeNil)
+		test.That(t, loaded.Load(bytes.NewBufferString(`[]`)), test.ShouldNotBeNil)
+	})
+}
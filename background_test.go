@@ -0,0 +1,120 @@
+package rplidar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/background"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+// wallRevolution returns a revolution of a wall 2 m away all around, with the given returns replaced.
+func wallRevolution(replaced ...nodes.Node) []nodes.Node {
+	var scanned []nodes.Node
+	for angle := 0.5; angle < 360; angle++ {
+		n := nodes.Node{Angle: angle, Distance: 2000}
+		for _, r := range replaced {
+			if r.Angle == angle {
+				n = r
+			}
+		}
+		scanned = append(scanned, n)
+	}
+	return scanned
+}
+
+func TestBackground(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getForegroundCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "background is not configured")
+	_, err = rp.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	conf := &Config{Background: &background.Config{LearnRevolutions: -1}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid background")
+
+	reference := filepath.Join(t.TempDir(), "background.json")
+	conf.Background = &background.Config{LearnRevolutions: 2, ReferencePath: reference}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rp.setupBackground(conf), test.ShouldBeNil)
+
+	rp.processRevolution(wallRevolution(), time.Now(), 0)
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getForegroundCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["learning"], test.ShouldBeTrue)
+	test.That(t, resp["learned_revolutions"], test.ShouldEqual, 1.)
+	test.That(t, resp, test.ShouldNotContainKey, "foreground")
+	_, err = rp.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "the background is still being learned")
+	_, err = rp.DoCommand(ctx, map[string]interface{}{saveBackgroundCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	rp.processRevolution(wallRevolution(), time.Now(), 0)
+	rp.processRevolution(wallRevolution(), time.Now(), 0)
+	pc, err := rp.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 0)
+
+	person := []nodes.Node{{Angle: 90.5, Distance: 1000}, {Angle: 91.5, Distance: 1010}}
+	rp.processRevolution(wallRevolution(person...), time.Now(), 0)
+	pc, err = rp.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	// The full revolution is still returned without the extra.
+	pc, err = rp.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 360)
+
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{getForegroundCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["learning"], test.ShouldBeFalse)
+	foreground, err := publisher.RevolutionFromMap(resp["foreground"].(map[string]interface{}))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, foreground.Nodes, test.ShouldResemble, person)
+	test.That(t, resp["background_points"], test.ShouldEqual, 358.)
+
+	t.Run("the background is saved and loaded", func(t *testing.T) {
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{saveBackgroundCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["path"], test.ShouldEqual, reference)
+
+		other := filepath.Join(t.TempDir(), "other.json")
+		resp, err = rp.DoCommand(ctx, map[string]interface{}{saveBackgroundCommand: map[string]interface{}{"path": other}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["path"], test.ShouldEqual, other)
+
+		loaded := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+		test.That(t, loaded.setupBackground(conf), test.ShouldBeNil)
+		loaded.processRevolution(wallRevolution(person...), time.Now(), 0)
+		pc, err := loaded.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+		test.That(t, os.WriteFile(other, []byte("{"), 0o600), test.ShouldBeNil)
+		err = loaded.setupBackground(&Config{Background: &background.Config{ReferencePath: other}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "could not load the background reference")
+	})
+
+	t.Run("relearning forgets the background", func(t *testing.T) {
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{relearnBackgroundCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["learning"], test.ShouldBeTrue)
+		_, err = rp.NextPointCloud(ctx, map[string]interface{}{foregroundExtraKey: true})
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
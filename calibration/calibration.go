@@ -0,0 +1,160 @@
+// Package calibration solves for the relative pose of two lidars on the same robot by matching revolutions they
+// captured of the same scene. All distances are in millimeters.
+package calibration
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/odometry"
+)
+
+const (
+	defaultYawStepDeg          = 15.
+	defaultMaxCorrespondenceMM = 100.
+	defaultMinMatchRatio       = 0.5
+
+	// coarseCorrespondenceMM is the correspondence distance used to converge from each starting heading, large
+	// enough to pull in the lidars' offset on a robot.
+	coarseCorrespondenceMM = 1000.
+	// coarseMinMatchRatio only rejects starting headings that match almost nothing, since a poor start still matches
+	// few points.
+	coarseMinMatchRatio = 0.1
+	maxIterations       = 50
+	// maxPoints is higher than for odometry, since calibration runs once rather than on every revolution.
+	maxPoints = 2000
+	// sameRatio is the difference of match ratio below which the lower error decides between two solutions.
+	sameRatio = 0.01
+)
+
+// Config describes how the lidars are matched.
+type Config struct {
+	// Guess is a rough pose of the other lidar in the frame of the reference lidar. When it is nil, starting
+	// headings are tried all the way around from the reference's position.
+	Guess *geometry.Pose `json:"guess,omitempty"`
+	// YawStepDeg is the spacing of the starting headings tried without a guess.
+	YawStepDeg float64 `json:"yaw_step_deg,omitempty"`
+	// MaxCorrespondenceMM is the largest distance between matched points in the final match.
+	MaxCorrespondenceMM float64 `json:"max_correspondence_mm,omitempty"`
+	// MinMatchRatio is the fraction of the other lidar's points that must be matched in the final match.
+	MinMatchRatio float64 `json:"min_match_ratio,omitempty"`
+}
+
+// Validate checks that the calibration attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.YawStepDeg < 0 || conf.YawStepDeg > 180 {
+		return errors.New("yaw_step_deg must be between 0 and 180")
+	}
+	if conf.MaxCorrespondenceMM < 0 {
+		return errors.New("max_correspondence_mm must be positive")
+	}
+	if conf.MinMatchRatio < 0 || conf.MinMatchRatio > 1 {
+		return errors.New("min_match_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.YawStepDeg == 0 {
+		conf.YawStepDeg = defaultYawStepDeg
+	}
+	if conf.MaxCorrespondenceMM == 0 {
+		conf.MaxCorrespondenceMM = defaultMaxCorrespondenceMM
+	}
+	if conf.MinMatchRatio == 0 {
+		conf.MinMatchRatio = defaultMinMatchRatio
+	}
+	return conf
+}
+
+// Result is the relative pose of two lidars.
+type Result struct {
+	// Transform is the pose of the other lidar in the frame of the reference lidar. It maps the other lidar's points
+	// into the reference's frame.
+	Transform geometry.Pose
+	// RMSErrorMM is the root mean square distance of the other lidar's matched points from the reference's surfaces.
+	RMSErrorMM float64
+	// MatchRatio is the fraction of the other lidar's points that were matched.
+	MatchRatio float64
+	// Candidates is the number of starting poses that converged to a match.
+	Candidates int
+}
+
+// better reports whether r is a better solution than other: it matches clearly more points, or as many with a
+// lower error.
+func (r Result) better(other Result) bool {
+	if math.Abs(r.MatchRatio-other.MatchRatio) > sameRatio {
+		return r.MatchRatio > other.MatchRatio
+	}
+	return r.RMSErrorMM < other.RMSErrorMM
+}
+
+// Solve finds the pose of the other lidar relative to the reference lidar. Both sets of points must be in angle
+// order, and may hold several revolutions each, captured while the robot is still. Each starting pose is matched
+// with a large correspondence distance, then refined with the configured one, and the solution that matches the most
+// points is kept.
+func Solve(reference, other []geometry.Point, conf Config) (Result, error) {
+	conf = conf.withDefaults()
+	if len(reference) == 0 || len(other) == 0 {
+		return Result{}, errors.New("both lidars must have returns")
+	}
+
+	var guesses []geometry.Pose
+	if conf.Guess != nil {
+		guesses = []geometry.Pose{*conf.Guess}
+	} else {
+		for yaw := 0.; yaw < 360; yaw += conf.YawStepDeg {
+			guesses = append(guesses, geometry.Pose{ThetaDeg: yaw})
+		}
+	}
+
+	coarse := odometry.Config{
+		MaxIterations:       maxIterations,
+		MaxCorrespondenceMM: coarseCorrespondenceMM,
+		MinMatchRatio:       coarseMinMatchRatio,
+		MaxPoints:           maxPoints,
+	}
+	fine := odometry.Config{
+		MaxIterations:       maxIterations,
+		MaxCorrespondenceMM: conf.MaxCorrespondenceMM,
+		MinMatchRatio:       conf.MinMatchRatio,
+		MaxPoints:           maxPoints,
+	}
+
+	var best Result
+	var candidates int
+	for _, guess := range guesses {
+		match, err := odometry.MatchRevolutions(reference, other, guess, coarse)
+		if err != nil {
+			continue
+		}
+		if match, err = odometry.MatchRevolutions(reference, other, match.Transform, fine); err != nil {
+			continue
+		}
+		candidates++
+		result := Result{Transform: normalize(match.Transform), RMSErrorMM: match.RMSErrorMM, MatchRatio: match.MatchRatio}
+		if candidates == 1 || result.better(best) {
+			best = result
+		}
+	}
+	if candidates == 0 {
+		return Result{}, errors.Errorf(
+			"no starting pose matched %.0f%% of the points, check that the lidars see the same surfaces", conf.MinMatchRatio*100)
+	}
+	best.Candidates = candidates
+	return best, nil
+}
+
+// normalize returns the pose with its heading between -180 and 180 degrees.
+func normalize(p geometry.Pose) geometry.Pose {
+	p.ThetaDeg = math.Mod(p.ThetaDeg, 360)
+	switch {
+	case p.ThetaDeg > 180:
+		p.ThetaDeg -= 360
+	case p.ThetaDeg <= -180:
+		p.ThetaDeg += 360
+	}
+	return p
+}
@@ -0,0 +1,112 @@
+package calibration
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{YawStepDeg: 200}, "yaw_step_deg must be between 0 and 180"},
+		{Config{MaxCorrespondenceMM: -1}, "max_correspondence_mm must be positive"},
+		{Config{MinMatchRatio: 2}, "min_match_ratio must be between 0 and 1"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// room is an L-shaped room with a pillar, so that no rotation maps it onto itself.
+var room = func() [][2]geometry.Point {
+	outline := geometry.PolygonFromConfig([][2]float64{{-3000, -2000}, {3000, -2000}, {3000, 500}, {1000, 500}, {1000, 2000}, {-3000, 2000}})
+	pillar := geometry.PolygonFromConfig([][2]float64{{-1500, -500}, {-1100, -500}, {-1100, -100}, {-1500, -100}})
+	var walls [][2]geometry.Point
+	for _, polygon := range []geometry.Polygon{outline, pillar} {
+		for i := range polygon {
+			walls = append(walls, [2]geometry.Point{polygon[i], polygon[(i+1)%len(polygon)]})
+		}
+	}
+	return walls
+}()
+
+// scan returns the returns of a lidar at a pose in the room every half degree, in the lidar's frame and in angle
+// order.
+func scan(pose geometry.Pose) []geometry.Point {
+	var points []geometry.Point
+	for angle := 0.; angle < 360; angle += 0.5 {
+		sin, cos := math.Sincos((angle + pose.ThetaDeg) * math.Pi / 180)
+		nearest := math.Inf(1)
+		for _, wall := range room {
+			a, b := wall[0], wall[1]
+			// Solve pose + r (cos, sin) = a + s (b - a) for the range r and the position s along the wall.
+			ex, ey := b.X-a.X, b.Y-a.Y
+			det := cos*(-ey) + sin*ex
+			if det == 0 {
+				continue
+			}
+			dx, dy := a.X-pose.X, a.Y-pose.Y
+			r := (dx*(-ey) + dy*ex) / det
+			s := (cos*dy - sin*dx) / det
+			if r > 0 && s >= 0 && s <= 1 && r < nearest {
+				nearest = r
+			}
+		}
+		sin, cos = math.Sincos(angle * math.Pi / 180)
+		points = append(points, geometry.Point{X: nearest * cos, Y: nearest * sin})
+	}
+	return points
+}
+
+func TestSolve(t *testing.T) {
+	reference := scan(geometry.Pose{X: -500, Y: 0, ThetaDeg: 10})
+	// The other lidar is at the back of the robot, facing backwards.
+	expected := geometry.Pose{X: -400, Y: 150, ThetaDeg: 170}
+	other := scan(geometry.Pose{X: -500, Y: 0, ThetaDeg: 10}.Compose(expected))
+
+	result, err := Solve(reference, other, Config{})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result.Transform.X, test.ShouldAlmostEqual, expected.X, 2)
+	test.That(t, result.Transform.Y, test.ShouldAlmostEqual, expected.Y, 2)
+	test.That(t, result.Transform.ThetaDeg, test.ShouldAlmostEqual, expected.ThetaDeg, 0.1)
+	test.That(t, result.RMSErrorMM, test.ShouldBeLessThan, 5)
+	test.That(t, result.MatchRatio, test.ShouldBeGreaterThan, 0.9)
+	test.That(t, result.Candidates, test.ShouldBeGreaterThan, 0)
+
+	t.Run("from a guess", func(t *testing.T) {
+		guess := geometry.Pose{X: -300, Y: 100, ThetaDeg: 160}
+		result, err := Solve(reference, other, Config{Guess: &guess})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, result.Candidates, test.ShouldEqual, 1)
+		test.That(t, result.Transform.X, test.ShouldAlmostEqual, expected.X, 2)
+		test.That(t, result.Transform.ThetaDeg, test.ShouldAlmostEqual, expected.ThetaDeg, 0.1)
+	})
+
+	t.Run("lidars that do not see the same surfaces", func(t *testing.T) {
+		far := make([]geometry.Point, len(other))
+		for i, pt := range other {
+			far[i] = geometry.Point{X: pt.X + 100000, Y: pt.Y}
+		}
+		guess := geometry.Pose{}
+		_, err := Solve(reference, far, Config{Guess: &guess})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "same surfaces")
+
+		_, err = Solve(nil, other, Config{})
+		test.That(t, err, test.ShouldNotBeNil)
+	})
+}
+
+func TestNormalize(t *testing.T) {
+	test.That(t, normalize(geometry.Pose{ThetaDeg: 190}).ThetaDeg, test.ShouldAlmostEqual, -170)
+	test.That(t, normalize(geometry.Pose{ThetaDeg: -540}).ThetaDeg, test.ShouldAlmostEqual, 180)
+	test.That(t, normalize(geometry.Pose{ThetaDeg: 725}).ThetaDeg, test.ShouldAlmostEqual, 5)
+}
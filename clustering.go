@@ -0,0 +1,180 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/vision/classification"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+
+	viz "go.viam.com/rdk/vision"
+
+	"go.viam.com/rplidar/clusters"
+	"go.viam.com/rplidar/publisher"
+)
+
+// ClusteringModel is the model of the vision service that segments the revolutions of an RPLiDAR camera into
+// obstacles.
+var ClusteringModel = resource.NewModel("viam", "lidar", "rplidar-clustering")
+
+func init() {
+	resource.RegisterService(vision.API, ClusteringModel, resource.Registration[vision.Service, *ClusteringConfig]{
+		Constructor: newClusteringService,
+	})
+}
+
+// ClusteringConfig describes how to configure the clustering vision service.
+type ClusteringConfig struct {
+	// Camera is the name of the rplidar camera whose revolutions are segmented.
+	Camera    string  `json:"camera"`
+	GapMM     float64 `json:"gap_mm,omitempty"`
+	GapRatio  float64 `json:"gap_ratio,omitempty"`
+	MinPoints int     `json:"min_points,omitempty"`
+}
+
+func (conf *ClusteringConfig) clusters() clusters.Config {
+	return clusters.Config{GapMM: conf.GapMM, GapRatio: conf.GapRatio, MinPoints: conf.MinPoints}
+}
+
+// Validate checks that the camera is set and depends on it.
+func (conf *ClusteringConfig) Validate(path string) ([]string, []string, error) {
+	if conf.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	clusterConf := conf.clusters()
+	if err := clusterConf.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return []string{conf.Camera}, nil, nil
+}
+
+// clusteringService segments the most recent revolution of an rplidar camera into clusters. It works on the
+// revolution's nodes in angle order, which is lost once they are turned into a point cloud.
+type clusteringService struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	cameraName string
+	// camera is only used through DoCommand.
+	camera resource.Resource
+	conf   clusters.Config
+}
+
+func newClusteringService(
+	_ context.Context,
+	deps resource.Dependencies,
+	c resource.Config,
+	_ logging.Logger,
+) (vision.Service, error) {
+	conf, err := resource.NativeConfig[*ClusteringConfig](c)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := resource.FromProvider[resource.Resource](deps, camera.Named(conf.Camera))
+	if err != nil {
+		return nil, err
+	}
+	return &clusteringService{
+		Named:      c.ResourceName().AsNamed(),
+		cameraName: conf.Camera,
+		camera:     cam,
+		conf:       conf.clusters(),
+	}, nil
+}
+
+// GetObjectPointClouds returns a cluster of the most recent revolution of the camera for each obstacle, with its
+// axis-aligned bounding box. The camera name may be empty to use the configured camera.
+func (s *clusteringService) GetObjectPointClouds(
+	ctx context.Context,
+	cameraName string,
+	_ map[string]interface{},
+) ([]*viz.Object, error) {
+	if cameraName != "" && cameraName != s.cameraName {
+		return nil, errors.Errorf("camera %q is not the configured camera %q", cameraName, s.cameraName)
+	}
+
+	resp, err := s.camera.DoCommand(ctx, map[string]interface{}{getRevolutionCommand: true})
+	if err != nil {
+		return nil, err
+	}
+	rev, err := publisher.RevolutionFromMap(resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "camera %q returned a malformed revolution", s.cameraName)
+	}
+
+	segments := clusters.Segment(rev.Nodes, s.conf)
+	objects := make([]*viz.Object, 0, len(segments))
+	for i, segment := range segments {
+		pc, err := pointCloudFromNodes(segment)
+		if err != nil {
+			return nil, err
+		}
+		object, err := viz.NewObjectWithLabel(pc, fmt.Sprintf("cluster-%d", i), nil)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// GetProperties reports that only object point clouds are supported.
+func (s *clusteringService) GetProperties(context.Context, map[string]interface{}) (*vision.Properties, error) {
+	return &vision.Properties{ObjectPCDsSupported: true, DefaultCamera: &s.cameraName}, nil
+}
+
+// CaptureAllFromCamera returns the objects of the most recent revolution when requested. Images, detections and
+// classifications are not supported.
+func (s *clusteringService) CaptureAllFromCamera(
+	ctx context.Context,
+	cameraName string,
+	opts viscapture.CaptureOptions,
+	extra map[string]interface{},
+) (viscapture.VisCapture, error) {
+	var capture viscapture.VisCapture
+	if !opts.ReturnObject {
+		return capture, nil
+	}
+	objects, err := s.GetObjectPointClouds(ctx, cameraName, extra)
+	if err != nil {
+		return capture, err
+	}
+	capture.Objects = objects
+	return capture, nil
+}
+
+// DetectionsFromCamera is not supported.
+func (s *clusteringService) DetectionsFromCamera(
+	context.Context, string, map[string]interface{},
+) ([]objectdetection.Detection, error) {
+	return nil, errors.New("detections are not supported, use GetObjectPointClouds")
+}
+
+// Detections is not supported.
+func (s *clusteringService) Detections(
+	context.Context, *camera.NamedImage, map[string]interface{},
+) ([]objectdetection.Detection, error) {
+	return nil, errors.New("detections are not supported, use GetObjectPointClouds")
+}
+
+// ClassificationsFromCamera is not supported.
+func (s *clusteringService) ClassificationsFromCamera(
+	context.Context, string, int, map[string]interface{},
+) (classification.Classifications, error) {
+	return nil, errors.New("classifications are not supported, use GetObjectPointClouds")
+}
+
+// Classifications is not supported.
+func (s *clusteringService) Classifications(
+	context.Context, *camera.NamedImage, int, map[string]interface{},
+) (classification.Classifications, error) {
+	return nil, errors.New("classifications are not supported, use GetObjectPointClouds")
+}
@@ -0,0 +1,105 @@
+package rplidar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func TestGetRevolution(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getRevolutionCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "no revolution has been captured yet")
+
+	scanned := []nodes.Node{{Angle: 10, Distance: 1000, Quality: 47}, {Angle: 20, Distance: 2000, Quality: 47}}
+	now := time.Unix(100, 0).UTC()
+	rp.processRevolution(scanned, now, 0)
+
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getRevolutionCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	rev, err := publisher.RevolutionFromMap(resp)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, rev.Nodes, test.ShouldResemble, scanned)
+	test.That(t, rev.Timestamp, test.ShouldResemble, now)
+}
+
+func TestClustering(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	_, _, err := (&ClusteringConfig{}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&ClusteringConfig{Camera: "lidar", MinPoints: -1}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "min_points must be positive")
+	deps, _, err := (&ClusteringConfig{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+
+	rp := &rplidar{Named: camera.Named("lidar").AsNamed(), cache: &dataCache{}, logger: logger}
+	svc, err := newClusteringService(ctx, resource.Dependencies{camera.Named("lidar"): rp}, resource.Config{
+		Name:                "clusters",
+		API:                 vision.API,
+		ConvertedAttributes: &ClusteringConfig{Camera: "lidar"},
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	_, err = svc.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// A wall behind the lidar, a single stray return, and a post to its side.
+	var scanned []nodes.Node
+	for angle := 0.; angle <= 10; angle++ {
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: 1000})
+	}
+	scanned = append(scanned, nodes.Node{Angle: 45, Distance: 500})
+	for angle := 88.; angle <= 92; angle++ {
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: 2000})
+	}
+	for angle := 350.; angle < 360; angle++ {
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: 1000})
+	}
+	rp.processRevolution(scanned, time.Now(), 0)
+
+	objects, err := svc.GetObjectPointClouds(ctx, "lidar", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldEqual, 2)
+	test.That(t, objects[0].Size(), test.ShouldEqual, 21)
+	test.That(t, objects[0].Geometry.Label(), test.ShouldEqual, "cluster-0")
+	// The wall crosses the -x axis, where the node angle 0 points.
+	test.That(t, objects[0].Geometry.Pose().Point().X, test.ShouldBeLessThan, -900)
+	test.That(t, objects[1].Size(), test.ShouldEqual, 5)
+	test.That(t, objects[1].Geometry.Pose().Point().Y, test.ShouldAlmostEqual, 2000, 10)
+
+	_, err = svc.GetObjectPointClouds(ctx, "other", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	props, err := svc.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.ObjectPCDsSupported, test.ShouldBeTrue)
+	test.That(t, props.DetectionSupported, test.ShouldBeFalse)
+	test.That(t, *props.DefaultCamera, test.ShouldEqual, "lidar")
+
+	capture, err := svc.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{ReturnObject: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(capture.Objects), test.ShouldEqual, 2)
+	capture, err = svc.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, capture.Objects, test.ShouldBeEmpty)
+
+	_, err = svc.DetectionsFromCamera(ctx, "lidar", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,97 @@
+// Package clusters segments a revolution of RPLiDAR measurement nodes into clusters of nearby returns, such as the
+// obstacles around a robot. Segmentation walks the nodes in angle order, so it runs in linear time without building
+// a spatial index.
+package clusters
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultGapMM     = 100.
+	defaultGapRatio  = 0.05
+	defaultMinPoints = 3
+)
+
+// Config describes how revolutions are split into clusters. Two consecutive nodes belong to the same cluster when
+// the distance between them is at most GapMM plus GapRatio times the range of the nearer one, which accounts for
+// the spacing between nodes growing with range.
+type Config struct {
+	GapMM     float64 `json:"gap_mm,omitempty"`
+	GapRatio  float64 `json:"gap_ratio,omitempty"`
+	MinPoints int     `json:"min_points,omitempty"`
+}
+
+// Validate checks that the clustering attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.GapMM < 0 {
+		return errors.New("gap_mm must be positive")
+	}
+	if conf.GapRatio < 0 {
+		return errors.New("gap_ratio must be positive")
+	}
+	if conf.MinPoints < 0 {
+		return errors.New("min_points must be positive")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.GapMM == 0 {
+		conf.GapMM = defaultGapMM
+	}
+	if conf.GapRatio == 0 {
+		conf.GapRatio = defaultGapRatio
+	}
+	if conf.MinPoints == 0 {
+		conf.MinPoints = defaultMinPoints
+	}
+	return conf
+}
+
+// Segment splits a revolution, sorted by ascending angle, into clusters at every range jump, and drops the clusters
+// with fewer than the minimum number of points. The first and last clusters are joined when the revolution wraps
+// around through the same object. Nodes without a return must already be removed.
+func Segment(revolution []nodes.Node, conf Config) [][]nodes.Node {
+	conf = conf.withDefaults()
+	if len(revolution) == 0 {
+		return nil
+	}
+
+	var segments [][]nodes.Node
+	start := 0
+	for i := 1; i < len(revolution); i++ {
+		if !conf.Connected(revolution[i-1], revolution[i]) {
+			segments = append(segments, revolution[start:i])
+			start = i
+		}
+	}
+	segments = append(segments, revolution[start:])
+
+	if len(segments) > 1 && conf.Connected(revolution[len(revolution)-1], revolution[0]) {
+		last := segments[len(segments)-1]
+		joined := make([]nodes.Node, 0, len(last)+len(segments[0]))
+		joined = append(append(joined, last...), segments[0]...)
+		segments = append([][]nodes.Node{joined}, segments[1:len(segments)-1]...)
+	}
+
+	clusters := segments[:0]
+	for _, segment := range segments {
+		if len(segment) >= conf.MinPoints {
+			clusters = append(clusters, segment)
+		}
+	}
+	return clusters
+}
+
+// Connected reports whether two nodes are close enough to belong to the same cluster.
+func (conf Config) Connected(a, b nodes.Node) bool {
+	conf = conf.withDefaults()
+	pa, pb := a.Point(), b.Point()
+	gap := math.Hypot(pa.X-pb.X, pa.Y-pb.Y)
+	return gap <= conf.GapMM+conf.GapRatio*math.Min(a.Distance, b.Distance)
+}
@@ -0,0 +1,76 @@
+package clusters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{GapMM: 50, GapRatio: 0.1, MinPoints: 2}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{GapMM: -1}, "gap_mm must be positive"},
+		{Config{GapRatio: -1}, "gap_ratio must be positive"},
+		{Config{MinPoints: -1}, "min_points must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// arc returns nodes every step degrees from start to end, at a constant distance.
+func arc(start, end, step, distance float64) []nodes.Node {
+	var arc []nodes.Node
+	for angle := start; angle <= end; angle += step {
+		arc = append(arc, nodes.Node{Angle: angle, Distance: distance})
+	}
+	return arc
+}
+
+func TestSegment(t *testing.T) {
+	t.Run("splits at range jumps and drops small clusters", func(t *testing.T) {
+		var revolution []nodes.Node
+		revolution = append(revolution, arc(10, 20, 1, 1000)...)
+		revolution = append(revolution, arc(21, 30, 1, 3000)...)
+		revolution = append(revolution, nodes.Node{Angle: 31, Distance: 500})
+		revolution = append(revolution, arc(32, 40, 1, 3000)...)
+
+		clusters := Segment(revolution, Config{})
+		test.That(t, len(clusters), test.ShouldEqual, 3)
+		test.That(t, clusters[0], test.ShouldResemble, revolution[:11])
+		test.That(t, clusters[1], test.ShouldResemble, revolution[11:21])
+		test.That(t, clusters[2], test.ShouldResemble, revolution[22:])
+
+		clusters = Segment(revolution, Config{MinPoints: 1})
+		test.That(t, len(clusters), test.ShouldEqual, 4)
+	})
+
+	t.Run("the gap grows with range", func(t *testing.T) {
+		// Nodes 2 degrees apart at 5 m are about 175 mm apart.
+		far := arc(0, 20, 2, 5000)
+		test.That(t, len(Segment(far, Config{})), test.ShouldEqual, 1)
+		test.That(t, len(Segment(far, Config{GapRatio: 0.001, MinPoints: 1})), test.ShouldEqual, 11)
+	})
+
+	t.Run("joins the object across the start of the revolution", func(t *testing.T) {
+		revolution := append(arc(0, 5, 1, 1000), arc(90, 100, 1, 2000)...)
+		revolution = append(revolution, arc(355, 359, 1, 1000)...)
+
+		clusters := Segment(revolution, Config{})
+		test.That(t, len(clusters), test.ShouldEqual, 2)
+		test.That(t, len(clusters[0]), test.ShouldEqual, 11)
+		test.That(t, clusters[0][0].Angle, test.ShouldEqual, 355)
+		test.That(t, clusters[1], test.ShouldResemble, revolution[6:17])
+	})
+
+	t.Run("a closed loop is a single cluster", func(t *testing.T) {
+		test.That(t, len(Segment(arc(0, 359, 1, 1000), Config{})), test.ShouldEqual, 1)
+		test.That(t, Segment(nil, Config{}), test.ShouldBeEmpty)
+	})
+}
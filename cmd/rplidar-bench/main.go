@@ -0,0 +1,204 @@
+// Package main measures the per-revolution processing cost of the rplidar camera on the machine it runs on, by
+// replaying recorded or synthetic revolutions through the same filters, point cloud conversion, caching and
+// publication as the camera.
+//
+//	rplidar-bench -revolutions 200 -nodes 8192
+//	rplidar-bench -config attributes.json -target-hz 15 recording.ndjson
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/utils"
+
+	"go.viam.com/rplidar"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+type options struct {
+	configPath  string
+	revolutions int
+	nodes       int
+	subscribers int
+	targetHz    float64
+}
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logging.NewLogger("rplidar-bench"))
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var opts options
+	flags.StringVar(&opts.configPath, "config", "", "JSON file with the rplidar attributes whose filters to apply")
+	flags.IntVar(&opts.revolutions, "revolutions", 100, "number of revolutions to process, repeating the recording as needed")
+	flags.IntVar(&opts.nodes, "nodes", 8192, "number of nodes per synthetic revolution, when no recording is given")
+	flags.IntVar(&opts.subscribers, "subscribers", 1, "number of stream subscribers to publish to")
+	flags.Float64Var(&opts.targetHz, "target-hz", 10, "revolution rate the processing must keep up with")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if opts.revolutions < 1 || opts.nodes < 1 || opts.subscribers < 0 {
+		return errors.New("-revolutions and -nodes must be at least 1 and -subscribers must not be negative")
+	}
+
+	conf := &rplidar.Config{}
+	if opts.configPath != "" {
+		//nolint:gosec
+		b, err := os.ReadFile(opts.configPath)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, conf); err != nil {
+			return errors.Wrap(err, "could not parse config")
+		}
+		if _, _, err := conf.Validate(""); err != nil {
+			return err
+		}
+	}
+
+	var recorded [][]nodes.Node
+	if flags.NArg() > 0 {
+		//nolint:gosec
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer utils.UncheckedErrorFunc(f.Close)
+		if recorded, err = readRecording(f); err != nil {
+			return err
+		}
+	} else {
+		recorded = [][]nodes.Node{syntheticRevolution(opts.nodes)}
+	}
+
+	processor := rplidar.NewReplayProcessor(conf, logger)
+	defer processor.Close()
+	for i := 0; i < opts.subscribers; i++ {
+		processor.Subscribe(publisher.DefaultQueueSize)
+	}
+
+	results, err := run(ctx, processor, recorded, opts.revolutions)
+	if err != nil {
+		return err
+	}
+	return report(os.Stdout, results, opts.targetHz)
+}
+
+// readRecording reads every revolution of a recording made from the stream socket.
+func readRecording(in io.Reader) ([][]nodes.Node, error) {
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	var recorded [][]nodes.Node
+	for {
+		var rev publisher.Revolution
+		if err := decoder.Decode(&rev); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, errors.Wrapf(err, "could not read revolution %d", len(recorded)+1)
+		}
+		recorded = append(recorded, rev.Nodes)
+	}
+	if len(recorded) == 0 {
+		return nil, errors.New("the recording has no revolutions")
+	}
+	return recorded, nil
+}
+
+// syntheticRevolution returns a revolution of n evenly spaced nodes on a wavy wall around the lidar.
+func syntheticRevolution(n int) []nodes.Node {
+	revolution := make([]nodes.Node, n)
+	for i := range revolution {
+		angle := 360 * float64(i) / float64(n)
+		revolution[i] = nodes.Node{Angle: angle, Distance: 2000 + 500*math.Sin(3*angle*math.Pi/180), Quality: 47}
+	}
+	return revolution
+}
+
+// stageTimes holds the time each revolution spent in a processing stage.
+type stageTimes struct {
+	name  string
+	times []time.Duration
+}
+
+// run processes revolutions through the filters, then the point cloud conversion, caching and publication, timing
+// each stage.
+func run(ctx context.Context, processor *rplidar.ReplayProcessor, recorded [][]nodes.Node, count int) ([]stageTimes, error) {
+	filter := stageTimes{name: "filter", times: make([]time.Duration, 0, count)}
+	process := stageTimes{name: "point cloud, cache and publish", times: make([]time.Duration, 0, count)}
+	total := stageTimes{name: "total", times: make([]time.Duration, 0, count)}
+
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// Filtering may modify the revolution, so every pass starts from a copy.
+		revolution := append([]nodes.Node(nil), recorded[i%len(recorded)]...)
+
+		start := time.Now()
+		filtered := processor.Filter(revolution)
+		filteredAt := time.Now()
+		processor.Process(filtered, filteredAt)
+		end := time.Now()
+
+		filter.times = append(filter.times, filteredAt.Sub(start))
+		process.times = append(process.times, end.Sub(filteredAt))
+		total.times = append(total.times, end.Sub(start))
+	}
+	return []stageTimes{filter, process, total}, nil
+}
+
+// report prints the distribution of each stage's time and whether the total keeps up with the target rate.
+func report(out io.Writer, results []stageTimes, targetHz float64) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "STAGE\tMEAN\tP50\tP99\tMAX\t")
+	var totalMean time.Duration
+	var totalP99 time.Duration
+	for _, stage := range results {
+		sorted := append([]time.Duration(nil), stage.times...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		var sum time.Duration
+		for _, d := range sorted {
+			sum += d
+		}
+		mean := sum / time.Duration(len(sorted))
+		p99 := percentile(sorted, 0.99)
+		fmt.Fprintf(w, "%s\t%v\t%v\t%v\t%v\t\n", stage.name, mean, percentile(sorted, 0.5), p99, sorted[len(sorted)-1])
+		// The total is the last stage.
+		totalMean, totalP99 = mean, p99
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	budget := time.Duration(float64(time.Second) / targetHz)
+	fmt.Fprintf(out, "\nsustainable rate: %.1f revolutions/s (budget at %v Hz: %v per revolution)\n",
+		float64(time.Second)/float64(totalMean), targetHz, budget)
+	if totalP99 > budget {
+		return errors.Errorf("p99 processing time %v exceeds the %v budget", totalP99, budget)
+	}
+	return nil
+}
+
+// percentile returns the p-th percentile of sorted durations using the nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
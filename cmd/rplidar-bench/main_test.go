@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar"
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func TestReadRecording(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	test.That(t, encoder.Encode(publisher.Revolution{Sequence: 1, Nodes: syntheticRevolution(10)}), test.ShouldBeNil)
+	test.That(t, encoder.Encode(publisher.Revolution{Sequence: 2, Nodes: syntheticRevolution(20)}), test.ShouldBeNil)
+
+	recorded, err := readRecording(&buf)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(recorded), test.ShouldEqual, 2)
+	test.That(t, recorded[1], test.ShouldResemble, syntheticRevolution(20))
+
+	_, err = readRecording(&bytes.Buffer{})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestRun(t *testing.T) {
+	processor := rplidar.NewReplayProcessor(&rplidar.Config{MedianFilter: &filters.MedianConfig{}}, logging.NewTestLogger(t))
+	defer processor.Close()
+	sub := processor.Subscribe(publisher.DefaultQueueSize)
+
+	recorded := [][]nodes.Node{syntheticRevolution(720), syntheticRevolution(360)}
+	results, err := run(context.Background(), processor, recorded, 3)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(results), test.ShouldEqual, 3)
+	for _, stage := range results {
+		test.That(t, len(stage.times), test.ShouldEqual, 3)
+	}
+
+	// The recording is repeated, and is not modified by filtering.
+	test.That(t, len(sub.Drain(0)), test.ShouldEqual, 3)
+	test.That(t, recorded[0], test.ShouldResemble, syntheticRevolution(720))
+}
+
+func TestReport(t *testing.T) {
+	results := []stageTimes{
+		{name: "filter", times: []time.Duration{time.Millisecond, 3 * time.Millisecond}},
+		{name: "total", times: []time.Duration{2 * time.Millisecond, 4 * time.Millisecond}},
+	}
+
+	var buf bytes.Buffer
+	test.That(t, report(&buf, results, 10), test.ShouldBeNil)
+	test.That(t, buf.String(), test.ShouldContainSubstring, "sustainable rate: 333.3 revolutions/s")
+
+	err := report(&buf, results, 500)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "exceeds the 2ms budget")
+}
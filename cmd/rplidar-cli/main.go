@@ -0,0 +1,358 @@
+// Package main is a command line tool for checking an RPLiDAR without a robot config.
+//
+//	rplidar-cli list
+//	rplidar-cli -serial-path /dev/ttyUSB0 info
+//	rplidar-cli health
+//	rplidar-cli modes
+//	rplidar-cli scan -count 10 -format csv > scan.csv
+//	rplidar-cli scan -format pcd -out scan.pcd
+//	rplidar-cli reset
+//	rplidar-cli -serial-path /dev/ttyUSB0 calibrate -other /dev/ttyUSB1 -mounting '{"x_mm": 150}'
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/utils"
+
+	"go.viam.com/rplidar"
+	"go.viam.com/rplidar/calibration"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	formatCSV = "csv"
+	formatPCD = "pcd"
+)
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logging.NewLogger("rplidar-cli"))
+}
+
+func usage(flags *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] list|info|health|modes|scan|reset|calibrate [command flags]\n", flags.Name())
+		flags.PrintDefaults()
+	}
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	serialPath := flags.String("serial-path", "", "serial path of the rplidar, defaults to the first USB candidate")
+	flags.Usage = usage(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("a command must be given")
+	}
+
+	command, commandArgs := flags.Arg(0), flags.Args()[1:]
+	if command == "list" {
+		return list(os.Stdout)
+	}
+
+	// Parse command flags before connecting, so that typos do not spin up the motor.
+	var scanOpts scanOptions
+	var calibrateOpts calibrateOptions
+	switch command {
+	case "info", "health", "modes", "reset":
+		if len(commandArgs) != 0 {
+			return errors.Errorf("%s takes no arguments", command)
+		}
+	case "scan":
+		var err error
+		if scanOpts, err = parseScanOptions(commandArgs); err != nil {
+			return err
+		}
+	case "calibrate":
+		var err error
+		if calibrateOpts, err = parseCalibrateOptions(commandArgs); err != nil {
+			return err
+		}
+	default:
+		flags.Usage()
+		return errors.Errorf("unknown command %q", command)
+	}
+
+	device, err := rplidar.OpenDevice(*serialPath, logger)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	switch command {
+	case "info":
+		return info(os.Stdout, device)
+	case "health":
+		return health(os.Stdout, device)
+	case "modes":
+		return modes(os.Stdout, device)
+	case "scan":
+		return scan(ctx, device, scanOpts)
+	case "calibrate":
+		return calibrate(ctx, device, calibrateOpts, logger)
+	default:
+		if err := device.Reset(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, "reset", device.Info().Path)
+		return nil
+	}
+}
+
+func list(out io.Writer) error {
+	candidates := rplidar.FindUSBCandidates()
+	if len(candidates) == 0 {
+		return errors.New("no usb devices found")
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tVID\tPID\tSERIAL")
+	for _, c := range candidates {
+		fmt.Fprintf(w, "%s\t%04x\t%04x\t%s\n", c.Path, c.VendorID, c.ProductID, c.SerialNumber)
+	}
+	return w.Flush()
+}
+
+func info(out io.Writer, device *rplidar.Device) error {
+	i := device.Info()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "path:\t%s\n", i.Path)
+	fmt.Fprintf(w, "model:\t%s (%d)\n", i.Model, i.ModelID)
+	fmt.Fprintf(w, "serial number:\t%s\n", i.SerialNumber)
+	fmt.Fprintf(w, "firmware version:\t%s\n", i.FirmwareVersion)
+	fmt.Fprintf(w, "hardware revision:\t%d\n", i.HardwareRevision)
+	return w.Flush()
+}
+
+func health(out io.Writer, device *rplidar.Device) error {
+	h, err := device.Health()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "status: %s\nerror code: %#04x\n", h.Status, h.ErrorCode)
+	if h.Status == "error" {
+		return errors.New("the rplidar reported a hardware failure, try the reset command")
+	}
+	return nil
+}
+
+func modes(out io.Writer, device *rplidar.Device) error {
+	scanModes, err := device.ScanModes()
+	if err != nil {
+		return err
+	}
+	return writeModes(out, scanModes)
+}
+
+func writeModes(out io.Writer, scanModes []rplidar.ScanMode) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tUS/SAMPLE\tMAX DISTANCE (M)\tANSWER TYPE\tTYPICAL")
+	for _, m := range scanModes {
+		var typical string
+		if m.Typical {
+			typical = "*"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%.1f\t%.1f\t%#02x\t%s\n", m.ID, m.Name, m.MicrosPerSample, m.MaxDistanceM, m.AnswerType, typical)
+	}
+	return w.Flush()
+}
+
+type scanOptions struct {
+	count  int
+	format string
+	out    string
+}
+
+func parseScanOptions(args []string) (scanOptions, error) {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	var opts scanOptions
+	flags.IntVar(&opts.count, "count", 1, "number of revolutions to capture")
+	flags.StringVar(&opts.format, "format", formatCSV, "output format, csv or pcd")
+	flags.StringVar(&opts.out, "out", "", "output file, defaults to standard output")
+	if err := flags.Parse(args); err != nil {
+		return scanOptions{}, err
+	}
+	if opts.count < 1 {
+		return scanOptions{}, errors.New("-count must be at least 1")
+	}
+	if opts.format != formatCSV && opts.format != formatPCD {
+		return scanOptions{}, errors.Errorf("unknown format %q, supported formats are csv and pcd", opts.format)
+	}
+	return opts, nil
+}
+
+func scan(ctx context.Context, device *rplidar.Device, opts scanOptions) (err error) {
+	revolutions, err := device.Scan(ctx, opts.count)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if opts.out != "" {
+		//nolint:gosec
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+
+	if opts.format == formatPCD {
+		return writePCD(out, revolutions)
+	}
+	return writeCSV(out, revolutions)
+}
+
+// writeCSV writes one row per measurement, numbering the revolutions from 1.
+func writeCSV(out io.Writer, revolutions []publisher.Revolution) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"revolution", "angle_deg", "distance_mm", "quality", "flag"}); err != nil {
+		return err
+	}
+	for _, rev := range revolutions {
+		sequence := strconv.FormatUint(rev.Sequence, 10)
+		for _, node := range rev.Nodes {
+			if err := w.Write([]string{
+				sequence,
+				strconv.FormatFloat(node.Angle, 'f', -1, 64),
+				strconv.FormatFloat(node.Distance, 'f', -1, 64),
+				strconv.Itoa(int(node.Quality)),
+				strconv.Itoa(int(node.Flag)),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writePCD writes every revolution into a single binary PCD, in the same frame as the camera's point clouds.
+func writePCD(out io.Writer, revolutions []publisher.Revolution) error {
+	var scanned []nodes.Node
+	for _, rev := range revolutions {
+		scanned = append(scanned, rev.Nodes...)
+	}
+	pc, err := rplidar.PointCloudFromNodes(scanned)
+	if err != nil {
+		return err
+	}
+	if pc == nil {
+		return errors.New("no points were captured")
+	}
+	return pointcloud.ToPCD(pc, out, pointcloud.PCDBinary)
+}
+
+type calibrateOptions struct {
+	other    string
+	count    int
+	mounting geometry.Pose
+}
+
+func parseCalibrateOptions(args []string) (calibrateOptions, error) {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	var opts calibrateOptions
+	var mounting string
+	flags.StringVar(&opts.other, "other", "", "serial path of the lidar to calibrate against the one given by -serial-path")
+	flags.IntVar(&opts.count, "count", 10, "number of revolutions to capture from each lidar")
+	flags.StringVar(&mounting, "mounting", "", "mounting of the reference lidar as JSON, to print the mounting of the other")
+	if err := flags.Parse(args); err != nil {
+		return calibrateOptions{}, err
+	}
+	if opts.other == "" {
+		return calibrateOptions{}, errors.New("-other must be set")
+	}
+	if opts.count < 1 {
+		return calibrateOptions{}, errors.New("-count must be at least 1")
+	}
+	if mounting != "" {
+		if err := json.Unmarshal([]byte(mounting), &opts.mounting); err != nil {
+			return calibrateOptions{}, errors.Wrap(err, "invalid -mounting")
+		}
+	}
+	return opts, nil
+}
+
+// calibrate captures revolutions from both lidars, one after the other, and prints the pose of the other lidar
+// relative to the reference. The robot and the scene must not move in the meantime.
+func calibrate(ctx context.Context, reference *rplidar.Device, opts calibrateOptions, logger logging.Logger) error {
+	other, err := rplidar.OpenDevice(opts.other, logger)
+	if err != nil {
+		return err
+	}
+	defer other.Close()
+
+	referenceRevolutions, err := reference.Scan(ctx, opts.count)
+	if err != nil {
+		return err
+	}
+	otherRevolutions, err := other.Scan(ctx, opts.count)
+	if err != nil {
+		return err
+	}
+
+	result, err := calibration.Solve(pointsFromRevolutions(referenceRevolutions), pointsFromRevolutions(otherRevolutions),
+		calibration.Config{})
+	if err != nil {
+		return err
+	}
+	return writeCalibration(os.Stdout, result, opts.mounting)
+}
+
+// pointsFromRevolutions returns the points of every revolution, each in angle order.
+func pointsFromRevolutions(revolutions []publisher.Revolution) []geometry.Point {
+	var points []geometry.Point
+	for _, rev := range revolutions {
+		for _, node := range rev.Nodes {
+			points = append(points, node.Point())
+		}
+	}
+	return points
+}
+
+// writeCalibration prints the relative pose and its residual, then the mounting of the other lidar ready to be
+// pasted into its config.
+func writeCalibration(out io.Writer, result calibration.Result, mounting geometry.Pose) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "relative pose:\tx %.1f mm, y %.1f mm, theta %.2f deg\n",
+		result.Transform.X, result.Transform.Y, result.Transform.ThetaDeg)
+	fmt.Fprintf(w, "rms error:\t%.1f mm\n", result.RMSErrorMM)
+	fmt.Fprintf(w, "matched:\t%.0f%%\n", result.MatchRatio*100)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	other := mounting.Compose(result.Transform)
+	b, err := json.Marshal(map[string]geometry.Pose{"mounting": {
+		X:        math.Round(other.X*10) / 10,
+		Y:        math.Round(other.Y*10) / 10,
+		ThetaDeg: math.Round(other.ThetaDeg*100) / 100,
+	}})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar"
+	"go.viam.com/rplidar/calibration"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+var testRevolutions = []publisher.Revolution{
+	{Sequence: 1, Nodes: []nodes.Node{{Angle: 0, Distance: 1000, Quality: 47, Flag: 1}, {Angle: 90.5, Distance: 2000, Quality: 47}}},
+	{Sequence: 2, Nodes: []nodes.Node{{Angle: 180, Distance: 1500.25, Quality: 10, Flag: 1}}},
+}
+
+func TestParseScanOptions(t *testing.T) {
+	opts, err := parseScanOptions(nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts, test.ShouldResemble, scanOptions{count: 1, format: formatCSV})
+
+	opts, err = parseScanOptions([]string{"--count", "5", "--format", "pcd", "--out", "scan.pcd"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts, test.ShouldResemble, scanOptions{count: 5, format: formatPCD, out: "scan.pcd"})
+
+	_, err = parseScanOptions([]string{"-count", "0"})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	_, err = parseScanOptions([]string{"-format", "ply"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "unknown format")
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	test.That(t, writeCSV(&buf, testRevolutions), test.ShouldBeNil)
+	test.That(t, buf.String(), test.ShouldEqual, strings.Join([]string{
+		"revolution,angle_deg,distance_mm,quality,flag",
+		"1,0,1000,47,1",
+		"1,90.5,2000,47,0",
+		"2,180,1500.25,10,1",
+		"",
+	}, "\n"))
+}
+
+func TestWritePCD(t *testing.T) {
+	var buf bytes.Buffer
+	test.That(t, writePCD(&buf, testRevolutions), test.ShouldBeNil)
+	test.That(t, buf.String(), test.ShouldContainSubstring, "POINTS 3\n")
+	test.That(t, buf.String(), test.ShouldContainSubstring, "DATA binary\n")
+
+	err := writePCD(&buf, []publisher.Revolution{{Sequence: 1}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "no points")
+}
+
+func TestWriteModes(t *testing.T) {
+	var buf bytes.Buffer
+	test.That(t, writeModes(&buf, []rplidar.ScanMode{
+		{ID: 0, Name: "Standard", MicrosPerSample: 508, MaxDistanceM: 12, AnswerType: 0x81},
+		{ID: 1, Name: "Express", MicrosPerSample: 254, MaxDistanceM: 12, AnswerType: 0x82, Typical: true},
+	}), test.ShouldBeNil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	test.That(t, len(lines), test.ShouldEqual, 3)
+	test.That(t, strings.Fields(lines[1]), test.ShouldResemble, []string{"0", "Standard", "508.0", "12.0", "0x81"})
+	test.That(t, strings.Fields(lines[2]), test.ShouldResemble, []string{"1", "Express", "254.0", "12.0", "0x82", "*"})
+}
+
+func TestParseCalibrateOptions(t *testing.T) {
+	opts, err := parseCalibrateOptions([]string{"-other", "/dev/ttyUSB1"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts, test.ShouldResemble, calibrateOptions{other: "/dev/ttyUSB1", count: 10})
+
+	opts, err = parseCalibrateOptions([]string{"-other", "/dev/ttyUSB1", "-count", "3", "-mounting", `{"x_mm": 150, "theta_deg": 90}`})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, opts.count, test.ShouldEqual, 3)
+	test.That(t, opts.mounting, test.ShouldResemble, geometry.Pose{X: 150, ThetaDeg: 90})
+
+	_, err = parseCalibrateOptions(nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "-other")
+
+	_, err = parseCalibrateOptions([]string{"-other", "/dev/ttyUSB1", "-mounting", "150"})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid -mounting")
+}
+
+func TestWriteCalibration(t *testing.T) {
+	points := pointsFromRevolutions(testRevolutions)
+	test.That(t, len(points), test.ShouldEqual, 3)
+	test.That(t, points[0].X, test.ShouldAlmostEqual, -1000)
+
+	var buf bytes.Buffer
+	result := calibration.Result{Transform: geometry.Pose{X: -400, Y: 150, ThetaDeg: 180}, RMSErrorMM: 4.24, MatchRatio: 0.87}
+	test.That(t, writeCalibration(&buf, result, geometry.Pose{X: 100, ThetaDeg: 90}), test.ShouldBeNil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	test.That(t, len(lines), test.ShouldEqual, 4)
+	test.That(t, strings.Fields(lines[0]), test.ShouldResemble,
+		[]string{"relative", "pose:", "x", "-400.0", "mm,", "y", "150.0", "mm,", "theta", "180.00", "deg"})
+	test.That(t, strings.Fields(lines[1]), test.ShouldResemble, []string{"rms", "error:", "4.2", "mm"})
+	test.That(t, strings.Fields(lines[2]), test.ShouldResemble, []string{"matched:", "87%"})
+	// The other lidar is 400 mm behind the reference, which faces +y from 100 mm along x.
+	test.That(t, lines[3], test.ShouldEqual, `{"mounting":{"x_mm":-50,"y_mm":-400,"theta_deg":270}}`)
+}
@@ -0,0 +1,224 @@
+// Package main converts revolutions recorded from the rplidar stream socket into MCAP files or directories of
+// timestamped PCD files for offline perception tooling.
+//
+// A recording is the newline delimited JSON written to the stream socket, e.g.
+//
+//	socat UNIX-CONNECT:/tmp/rplidar.sock - > recording.ndjson
+//	rplidar-export -format mcap -out recording.mcap recording.ndjson
+//	rplidar-export -format pcd -out recording_pcds recording.ndjson
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/utils"
+
+	"go.viam.com/rplidar/foxglove"
+	"go.viam.com/rplidar/laserscan"
+	"go.viam.com/rplidar/mcap"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	formatMCAP = "mcap"
+	formatPCD  = "pcd"
+
+	// pcdTimeFormat sorts lexically in time order and is safe to use in file names.
+	pcdTimeFormat = "20060102T150405.000000000Z"
+)
+
+type options struct {
+	format      string
+	out         string
+	frameID     string
+	topicPrefix string
+	scanOpts    laserscan.Options
+}
+
+func main() {
+	utils.ContextualMain(mainWithArgs, logging.NewLogger("rplidar-export"))
+}
+
+func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	var opts options
+	flags.StringVar(&opts.format, "format", formatMCAP, "output format, mcap or pcd")
+	flags.StringVar(&opts.out, "out", "", "output MCAP file, or directory of PCD files")
+	flags.StringVar(&opts.frameID, "frame-id", foxglove.DefaultFrameID, "frame ID of the MCAP messages")
+	flags.StringVar(&opts.topicPrefix, "topic-prefix", foxglove.DefaultTopicPrefix, "prefix of the MCAP topics")
+	flags.Float64Var(&opts.scanOpts.AngleIncrementDeg, "angle-increment-deg", 1, "width of the LaserScan bins in degrees")
+	flags.Float64Var(&opts.scanOpts.RangeMinMM, "range-min-mm", 0, "range_min of the LaserScan messages in mm")
+	flags.Float64Var(&opts.scanOpts.RangeMaxMM, "range-max-mm", 0, "range_max of the LaserScan messages in mm")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if opts.out == "" {
+		return errors.New("-out must be set")
+	}
+
+	in := io.Reader(os.Stdin)
+	if flags.NArg() > 0 {
+		//nolint:gosec
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer utils.UncheckedErrorFunc(f.Close)
+		in = f
+	}
+
+	var count int
+	var err error
+	switch opts.format {
+	case formatMCAP:
+		count, err = exportMCAP(ctx, in, opts)
+	case formatPCD:
+		count, err = exportPCD(ctx, in, opts)
+	default:
+		return errors.Errorf("unknown format %q, supported formats are mcap and pcd", opts.format)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof("exported %d revolutions to %s", count, opts.out)
+	return nil
+}
+
+// readRevolutions calls fn for every revolution in a newline delimited JSON recording.
+func readRevolutions(ctx context.Context, in io.Reader, fn func(publisher.Revolution) error) (int, error) {
+	decoder := json.NewDecoder(bufio.NewReader(in))
+	var count int
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		var rev publisher.Revolution
+		if err := decoder.Decode(&rev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return count, nil
+			}
+			return count, errors.Wrapf(err, "could not read revolution %d", count+1)
+		}
+		if err := fn(rev); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// exportMCAP writes every revolution as a foxglove.LaserScan and a foxglove.PointCloud on the same topics as the
+// Foxglove bridge.
+func exportMCAP(ctx context.Context, in io.Reader, opts options) (count int, err error) {
+	//nolint:gosec
+	f, err := os.Create(opts.out)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	out := bufio.NewWriter(f)
+
+	w, err := mcap.NewWriter(out, "", "rplidar-export")
+	if err != nil {
+		return 0, err
+	}
+	scanChannel, err := addJSONChannel(w, opts.topicPrefix+"/scan", foxglove.LaserScanSchemaName, foxglove.LaserScanSchema)
+	if err != nil {
+		return 0, err
+	}
+	pointsChannel, err := addJSONChannel(w, opts.topicPrefix+"/points", foxglove.PointCloudSchemaName,
+		foxglove.PointCloudSchema)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err = readRevolutions(ctx, in, func(rev publisher.Revolution) error {
+		scan, err := foxglove.EncodeLaserScan(rev, opts.frameID, opts.scanOpts)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteMessage(scanChannel, rev.Timestamp, scan); err != nil {
+			return err
+		}
+		points, err := foxglove.EncodePointCloud(rev, opts.frameID)
+		if err != nil {
+			return err
+		}
+		return w.WriteMessage(pointsChannel, rev.Timestamp, points)
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := w.Close(); err != nil {
+		return count, err
+	}
+	return count, out.Flush()
+}
+
+func addJSONChannel(w *mcap.Writer, topic, schemaName, schema string) (uint16, error) {
+	schemaID, err := w.AddSchema(schemaName, "jsonschema", []byte(schema))
+	if err != nil {
+		return 0, err
+	}
+	return w.AddChannel(schemaID, topic, "json")
+}
+
+// pointCloud returns the point cloud of a revolution as the camera builds it, in the same frame and with the quality
+// of each return as its intensity.
+func pointCloud(rev publisher.Revolution) (pointcloud.PointCloud, error) {
+	pc := pointcloud.NewBasicPointCloud(len(rev.Nodes))
+	for _, node := range rev.Nodes {
+		pt := node.Point()
+		d := pointcloud.NewBasicData()
+		d.SetIntensity(uint16(node.Quality) * 255)
+		if err := pc.Set(pointcloud.NewVector(pt.X, pt.Y, 0), d); err != nil {
+			return nil, err
+		}
+	}
+	return pc, nil
+}
+
+// exportPCD writes the point cloud of every revolution to a binary PCD file named after its timestamp.
+func exportPCD(ctx context.Context, in io.Reader, opts options) (int, error) {
+	if err := os.MkdirAll(opts.out, 0o750); err != nil {
+		return 0, err
+	}
+
+	return readRevolutions(ctx, in, func(rev publisher.Revolution) error {
+		pc, err := pointCloud(rev)
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("%s.pcd", rev.Timestamp.UTC().Format(pcdTimeFormat))
+		//nolint:gosec
+		f, err := os.Create(filepath.Join(opts.out, name))
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		if err := pointcloud.ToPCD(pc, w, pointcloud.PCDBinary); err != nil {
+			utils.UncheckedError(f.Close())
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			utils.UncheckedError(f.Close())
+			return err
+		}
+		return f.Close()
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/mcap"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func recording(t *testing.T, revs ...publisher.Revolution) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, rev := range revs {
+		test.That(t, encoder.Encode(rev), test.ShouldBeNil)
+	}
+	return &buf
+}
+
+var testRevolutions = []publisher.Revolution{
+	{
+		Sequence:  1,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		ScanTime:  100 * time.Millisecond,
+		Nodes:     []nodes.Node{{Angle: 0, Distance: 1000, Quality: 47}, {Angle: 90, Distance: 2000, Quality: 47}},
+	},
+	{
+		Sequence:  2,
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 100000000, time.UTC),
+		ScanTime:  100 * time.Millisecond,
+		Nodes:     []nodes.Node{{Angle: 180, Distance: 1500, Quality: 47}},
+	},
+}
+
+func TestExportMCAP(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "recording.mcap")
+	opts := options{out: out, frameID: "rplidar", topicPrefix: "/rplidar"}
+
+	count, err := exportMCAP(context.Background(), recording(t, testRevolutions...), opts)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 2)
+
+	data, err := os.ReadFile(out)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, data[:len(mcap.Magic)], test.ShouldResemble, mcap.Magic)
+	test.That(t, data[len(data)-len(mcap.Magic):], test.ShouldResemble, mcap.Magic)
+	// Both topics are exported, and every revolution is written to each.
+	test.That(t, bytes.Count(data, []byte("/rplidar/scan")), test.ShouldEqual, 1)
+	test.That(t, bytes.Count(data, []byte("/rplidar/points")), test.ShouldEqual, 1)
+	test.That(t, bytes.Count(data, []byte(`"ranges":[`)), test.ShouldEqual, 2)
+	test.That(t, bytes.Count(data, []byte(`"point_stride":16`)), test.ShouldEqual, 2)
+}
+
+func TestExportPCD(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "pcds")
+
+	count, err := exportPCD(context.Background(), recording(t, testRevolutions...), options{out: out})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, count, test.ShouldEqual, 2)
+
+	entries, err := os.ReadDir(out)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(entries), test.ShouldEqual, 2)
+	test.That(t, entries[0].Name(), test.ShouldEqual, "20240501T120000.000000000Z.pcd")
+	test.That(t, entries[1].Name(), test.ShouldEqual, "20240501T120000.100000000Z.pcd")
+
+	pc, err := pointcloud.NewFromFile(filepath.Join(out, entries[0].Name()), "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	_, ok := pc.At(-1000, 0, 0)
+	test.That(t, ok, test.ShouldBeTrue)
+
+	t.Run("the intensity is the quality of each return, as in the camera's point clouds", func(t *testing.T) {
+		pc, err := pointCloud(testRevolutions[0])
+		test.That(t, err, test.ShouldBeNil)
+		d, ok := pc.At(-1000, 0, 0)
+		test.That(t, ok, test.ShouldBeTrue)
+		test.That(t, d.Intensity(), test.ShouldEqual, 47*255)
+	})
+}
+
+func TestReadRevolutions(t *testing.T) {
+	t.Run("invalid recording", func(t *testing.T) {
+		in := recording(t, testRevolutions[0])
+		in.WriteString("not json\n")
+
+		count, err := readRevolutions(context.Background(), in, func(publisher.Revolution) error { return nil })
+		test.That(t, count, test.ShouldEqual, 1)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "could not read revolution 2")
+	})
+	t.Run("decodes every revolution", func(t *testing.T) {
+		var read []publisher.Revolution
+		count, err := readRevolutions(context.Background(), recording(t, testRevolutions...),
+			func(rev publisher.Revolution) error {
+				read = append(read, rev)
+				return nil
+			})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, count, test.ShouldEqual, 2)
+		test.That(t, read[1].Sequence, test.ShouldEqual, 2)
+		test.That(t, read[1].Nodes, test.ShouldResemble, testRevolutions[1].Nodes)
+	})
+}
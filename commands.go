@@ -0,0 +1,135 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/laserscan"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	getMaskedPointsCommand = "get_masked_points"
+	getLaserScanCommand    = "get_laser_scan"
+	getRevolutionCommand   = "get_revolution"
+)
+
+// commandHandler handles a single DoCommand. args holds the value given for the command's key.
+type commandHandler func(rp *rplidar, ctx context.Context, args interface{}) (map[string]interface{}, error)
+
+// commandHandlers maps each supported DoCommand key to its handler.
+var commandHandlers = map[string]commandHandler{
+	getMaskedPointsCommand:    (*rplidar).getMaskedPoints,
+	getLaserScanCommand:       (*rplidar).getLaserScan,
+	getRevolutionCommand:      (*rplidar).getRevolution,
+	subscribeCommand:          (*rplidar).subscribe,
+	getRevolutionsCommand:     (*rplidar).getRevolutions,
+	unsubscribeCommand:        (*rplidar).unsubscribe,
+	getPipelineStatsCommand:   (*rplidar).getPipelineStats,
+	getZonesCommand:           (*rplidar).getZones,
+	getZoneEventsCommand:      (*rplidar).getZoneEvents,
+	getEmergencyStopCommand:   (*rplidar).getEmergencyStop,
+	resetEmergencyStopCommand: (*rplidar).resetEmergencyStop,
+	getFeaturesCommand:        (*rplidar).getFeatures,
+	getOccupancyGridCommand:   (*rplidar).getOccupancyGrid,
+	getLandmarksCommand:       (*rplidar).getLandmarks,
+	getSweepCommand:           (*rplidar).getSweep,
+	getForegroundCommand:      (*rplidar).getForeground,
+	relearnBackgroundCommand:  (*rplidar).relearnBackground,
+	saveBackgroundCommand:     (*rplidar).saveBackground,
+	getTracksCommand:          (*rplidar).getTracks,
+	resetTracksCommand:        (*rplidar).resetTracks,
+}
+
+// DoCommand runs the command named by the key present in cmd, e.g. {"get_masked_points": true}. Only one command
+// can be given at a time, since the order in which several would run is undefined.
+func (rp *rplidar) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	var names []string
+	for name := range cmd {
+		if _, ok := commandHandlers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	switch len(names) {
+	case 0:
+	case 1:
+		return commandHandlers[names[0]](rp, ctx, cmd[names[0]])
+	default:
+		sort.Strings(names)
+		return nil, errors.Errorf("only one command can be given at a time, got %v", names)
+	}
+
+	supported := make([]string, 0, len(commandHandlers))
+	for name := range commandHandlers {
+		supported = append(supported, name)
+	}
+	sort.Strings(supported)
+	return nil, errors.Errorf("unknown command, supported commands are %v", supported)
+}
+
+// getMaskedPoints returns the points removed by the body mask in the most recent revolution, in the frame of the
+// mask polygon, so that installers can tune the polygon.
+func (rp *rplidar) getMaskedPoints(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	if rp.mask == nil {
+		return nil, errors.New("body_mask is not configured")
+	}
+
+	masked := rp.mask.Masked()
+	points := make([]interface{}, 0, len(masked))
+	for _, pt := range masked {
+		points = append(points, []interface{}{pt.X, pt.Y})
+	}
+	return map[string]interface{}{"points_mm": points}, nil
+}
+
+// laserScanOptions returns the range limits of the connected rplidar for building laser scans.
+func (rp *rplidar) laserScanOptions() laserscan.Options {
+	return laserscan.Options{
+		RangeMinMM: rp.minRangeMM,
+		RangeMaxMM: maxRangeMMByModel[rplidarModelByteMap[rp.device.model]],
+	}
+}
+
+// getLaserScan returns the most recent revolution in the layout of a ROS sensor_msgs/LaserScan. The bin width can be
+// set with {"get_laser_scan": {"angle_increment_deg": 0.5}}.
+func (rp *rplidar) getLaserScan(_ context.Context, args interface{}) (map[string]interface{}, error) {
+	opts := rp.laserScanOptions()
+	if argsMap, ok := args.(map[string]interface{}); ok {
+		if increment, ok := argsMap["angle_increment_deg"]; ok {
+			incrementDeg, ok := increment.(float64)
+			if !ok || incrementDeg <= 0 {
+				return nil, errors.New("angle_increment_deg must be a positive number")
+			}
+			opts.AngleIncrementDeg = incrementDeg
+		}
+	}
+
+	rp.cache.mutex.RLock()
+	defer rp.cache.mutex.RUnlock()
+	if rp.cache.nodes == nil {
+		return nil, errors.New("no revolution has been captured yet")
+	}
+	opts.Timestamp = rp.cache.timestamp
+	opts.ScanTime = rp.cache.scanTime
+
+	return laserscan.FromNodes(rp.cache.nodes, opts).Map(), nil
+}
+
+// getRevolution returns the most recent filtered revolution in the layout of streamed revolutions, for clients that
+// need its nodes in angle order rather than a point cloud.
+func (rp *rplidar) getRevolution(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	rp.cache.mutex.RLock()
+	defer rp.cache.mutex.RUnlock()
+	if rp.cache.nodes == nil {
+		return nil, errors.New("no revolution has been captured yet")
+	}
+
+	return publisher.Revolution{
+		Timestamp: rp.cache.timestamp,
+		ScanTime:  rp.cache.scanTime,
+		Nodes:     rp.cache.nodes,
+	}.Map(), nil
+}
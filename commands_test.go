@@ -0,0 +1,96 @@
+package rplidar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+	"go.viam.com/utils/protoutils"
+
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestDoCommand(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unknown command", func(t *testing.T) {
+		rp := rplidar{}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{"not_a_command": true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "unknown command")
+		test.That(t, resp, test.ShouldBeNil)
+	})
+
+	t.Run("several commands", func(t *testing.T) {
+		rp := rplidar{}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{
+			getEmergencyStopCommand:   true,
+			resetEmergencyStopCommand: true,
+			"not_a_command":           true,
+		})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual,
+			"only one command can be given at a time, got [get_emergency_stop reset_emergency_stop]")
+		test.That(t, resp, test.ShouldBeNil)
+	})
+
+	t.Run("get_masked_points without a body mask", func(t *testing.T) {
+		rp := rplidar{}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getMaskedPointsCommand: true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "body_mask is not configured")
+		test.That(t, resp, test.ShouldBeNil)
+	})
+
+	t.Run("get_masked_points with a body mask", func(t *testing.T) {
+		rp := rplidar{}
+		rp.setupFilters(&Config{
+			BodyMask: &filters.MaskConfig{PolygonMM: [][2]float64{{-300, -50}, {-100, -50}, {-100, 50}, {-300, 50}}},
+		})
+		rp.filters.Filter([]nodes.Node{{Angle: 0, Distance: 200}, {Angle: 90, Distance: 200}})
+
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getMaskedPointsCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		points := resp["points_mm"].([]interface{})
+		test.That(t, len(points), test.ShouldEqual, 1)
+		test.That(t, points[0].([]interface{})[0], test.ShouldAlmostEqual, -200)
+	})
+
+	t.Run("get_laser_scan before a revolution is captured", func(t *testing.T) {
+		rp := rplidar{device: &rplidarDevice{}, cache: &dataCache{}}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getLaserScanCommand: true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "no revolution has been captured yet")
+		test.That(t, resp, test.ShouldBeNil)
+	})
+
+	t.Run("get_laser_scan", func(t *testing.T) {
+		rp := rplidar{
+			device: &rplidarDevice{model: 24},
+			cache: &dataCache{
+				nodes:    []nodes.Node{{Angle: 180, Distance: 1000, Quality: 30}},
+				scanTime: 100 * time.Millisecond,
+			},
+			minRangeMM: 150,
+		}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getLaserScanCommand: map[string]interface{}{"angle_increment_deg": 0.5}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["range_max"], test.ShouldAlmostEqual, 12)
+		test.That(t, resp["range_min"], test.ShouldAlmostEqual, 0.15)
+		test.That(t, resp["scan_time"], test.ShouldAlmostEqual, 0.1)
+		ranges := resp["ranges"].([]interface{})
+		test.That(t, len(ranges), test.ShouldEqual, 720)
+		test.That(t, ranges[360], test.ShouldAlmostEqual, 1)
+		test.That(t, math.IsInf(ranges[0].(float64), 1), test.ShouldBeTrue)
+		// Infinite ranges survive the conversion of the response to a protobuf struct.
+		_, err = protoutils.StructToStructPb(resp)
+		test.That(t, err, test.ShouldBeNil)
+
+		_, err = rp.DoCommand(ctx, map[string]interface{}{getLaserScanCommand: map[string]interface{}{"angle_increment_deg": -1}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "angle_increment_deg must be a positive number")
+	})
+}
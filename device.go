@@ -22,16 +22,17 @@ type rplidarDevice struct {
 	mutex            sync.Mutex
 }
 
-func searchForDevicePath(logger logging.Logger) (string, error) {
-	var usbInfo = &usb.Identifier{
-		Vendor:  0x10c4,
-		Product: 0xea60,
-	}
+// rplidarUSBIdentifier is the CP210x USB to UART bridge used by RPLiDARs.
+var rplidarUSBIdentifier = usb.Identifier{
+	Vendor:  0x10c4,
+	Product: 0xea60,
+}
 
+func searchForDevicePath(logger logging.Logger) (string, error) {
 	usbDevices := usb.Search(
 		usb.SearchFilter{},
 		func(vendorID, productID int) bool {
-			return vendorID == usbInfo.Vendor && productID == usbInfo.Product
+			return vendorID == rplidarUSBIdentifier.Vendor && productID == rplidarUSBIdentifier.Product
 		})
 
 	if len(usbDevices) == 0 {
@@ -46,6 +47,29 @@ func searchForDevicePath(logger logging.Logger) (string, error) {
 }
 
 func getRplidarDevice(devicePath string) (*rplidarDevice, error) {
+	device, err := connectRplidarDevice(devicePath)
+	if err != nil {
+		return device, err
+	}
+
+	health, err := device.health()
+	if err != nil {
+		gen.RPlidarDriverDisposeDriver(device.driver)
+		device.driver = nil
+		return nil, err
+	}
+
+	if health.status == gen.RPLIDAR_STATUS_ERROR {
+		gen.RPlidarDriverDisposeDriver(device.driver)
+		device.driver = nil
+		return nil, errors.New("bad health")
+	}
+
+	return device, nil
+}
+
+// connectRplidarDevice connects to the rplidar at devicePath and reads its device info, without checking its health.
+func connectRplidarDevice(devicePath string) (*rplidarDevice, error) {
 	var driver gen.RPlidarDriver
 	devInfo := gen.NewRplidar_response_device_info_t()
 	defer gen.DeleteRplidar_response_device_info_t(devInfo)
@@ -91,21 +115,6 @@ func getRplidarDevice(devicePath string) (*rplidarDevice, error) {
 		devInfo.GetFirmware_version()&0xFF)
 	hardwareRev := int(devInfo.GetHardware_version())
 
-	healthInfo := gen.NewRplidar_response_device_health_t()
-	defer gen.DeleteRplidar_response_device_health_t(healthInfo)
-
-	if result := driver.GetHealth(healthInfo, defaultDeviceTimeoutMs); Result(result) != ResultOk {
-		gen.RPlidarDriverDisposeDriver(driver)
-		driver = nil
-		return nil, fmt.Errorf("failed to get health: %w", Result(result).Failed())
-	}
-
-	if int(healthInfo.GetStatus()) == gen.RPLIDAR_STATUS_ERROR {
-		gen.RPlidarDriverDisposeDriver(driver)
-		driver = nil
-		return nil, errors.New("bad health")
-	}
-
 	rplidarDevice := &rplidarDevice{
 		driver:           driver,
 		model:            devInfo.GetModel(),
@@ -116,3 +125,47 @@ func getRplidarDevice(devicePath string) (*rplidarDevice, error) {
 
 	return rplidarDevice, nil
 }
+
+type deviceHealth struct {
+	status    int
+	errorCode uint16
+}
+
+// health reads the health status of the rplidar, which reports any hardware failure it detected.
+func (device *rplidarDevice) health() (deviceHealth, error) {
+	healthInfo := gen.NewRplidar_response_device_health_t()
+	defer gen.DeleteRplidar_response_device_health_t(healthInfo)
+
+	if result := device.driver.GetHealth(healthInfo, defaultDeviceTimeoutMs); Result(result) != ResultOk {
+		return deviceHealth{}, fmt.Errorf("failed to get health: %w", Result(result).Failed())
+	}
+	return deviceHealth{status: int(healthInfo.GetStatus()), errorCode: healthInfo.GetError_code()}, nil
+}
+
+// scanModes lists the scan modes supported by the rplidar, marking the one it scans with by default.
+func (device *rplidarDevice) scanModes() ([]ScanMode, error) {
+	modes := gen.NewRplidarScanModeVector()
+	defer gen.DeleteRplidarScanModeVector(modes)
+	if result := device.driver.GetAllSupportedScanModes(modes, defaultDeviceTimeoutMs); Result(result) != ResultOk {
+		return nil, fmt.Errorf("failed to get scan modes: %w", Result(result).Failed())
+	}
+
+	var typical uint16
+	if result := device.driver.GetTypicalScanMode(&typical, defaultDeviceTimeoutMs); Result(result) != ResultOk {
+		return nil, fmt.Errorf("failed to get typical scan mode: %w", Result(result).Failed())
+	}
+
+	scanModes := make([]ScanMode, 0, modes.Size())
+	for i := 0; i < int(modes.Size()); i++ {
+		mode := modes.Get(i)
+		scanModes = append(scanModes, ScanMode{
+			ID:              mode.GetId(),
+			Name:            mode.GetScan_mode(),
+			MicrosPerSample: float64(mode.GetUs_per_sample()),
+			MaxDistanceM:    float64(mode.GetMax_distance()),
+			AnswerType:      mode.GetAns_type(),
+			Typical:         mode.GetId() == typical,
+		})
+	}
+	return scanModes, nil
+}
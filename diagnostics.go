@@ -0,0 +1,251 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	goutils "go.viam.com/utils"
+	"go.viam.com/utils/usb"
+
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/publisher"
+)
+
+// sysClassTTY is where the sysfs entries of serial devices are found on linux. This can be changed for tests.
+var sysClassTTY = "/sys/class/tty"
+
+// USBCandidate is a USB serial device with the USB IDs used by RPLiDARs.
+type USBCandidate struct {
+	Path      string
+	VendorID  int
+	ProductID int
+	// SerialNumber is the serial number of the USB to UART bridge, which is empty when it cannot be read.
+	SerialNumber string
+}
+
+// FindUSBCandidates returns every connected USB serial device that may be an RPLiDAR.
+func FindUSBCandidates() []USBCandidate {
+	usbDevices := usb.Search(
+		usb.SearchFilter{},
+		func(vendorID, productID int) bool {
+			return vendorID == rplidarUSBIdentifier.Vendor && productID == rplidarUSBIdentifier.Product
+		})
+
+	candidates := make([]USBCandidate, 0, len(usbDevices))
+	for _, dev := range usbDevices {
+		candidates = append(candidates, USBCandidate{
+			Path:         dev.Path,
+			VendorID:     dev.ID.Vendor,
+			ProductID:    dev.ID.Product,
+			SerialNumber: usbSerialNumber(dev.Path),
+		})
+	}
+	return candidates
+}
+
+// usbSerialNumber reads the serial number of the USB device behind a serial device path from sysfs, walking up from
+// the tty to the first directory describing a USB device.
+func usbSerialNumber(devicePath string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysClassTTY, filepath.Base(devicePath), "device"))
+	if err != nil {
+		return ""
+	}
+	for ; dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "idVendor")); err != nil {
+			continue
+		}
+		//nolint:gosec
+		serial, err := os.ReadFile(filepath.Join(dir, "serial"))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(serial))
+	}
+	return ""
+}
+
+// DeviceInfo describes a connected RPLiDAR.
+type DeviceInfo struct {
+	Path             string
+	Model            string
+	ModelID          byte
+	SerialNumber     string
+	FirmwareVersion  string
+	HardwareRevision int
+}
+
+// DeviceHealth is the health status reported by an RPLiDAR.
+type DeviceHealth struct {
+	// Status is "ok", "warning" or "error".
+	Status string
+	// ErrorCode is the device specific code of the failure, if any.
+	ErrorCode uint16
+}
+
+// ScanMode is a scan mode supported by an RPLiDAR.
+type ScanMode struct {
+	ID              uint16
+	Name            string
+	MicrosPerSample float64
+	MaxDistanceM    float64
+	AnswerType      byte
+	// Typical is set on the mode the device scans with by default.
+	Typical bool
+}
+
+// Device is a direct connection to an RPLiDAR for diagnostics, without a robot. It is not safe for concurrent use.
+type Device struct {
+	rp       *rplidar
+	path     string
+	scanning bool
+}
+
+// OpenDevice connects to the RPLiDAR at devicePath, or at the first USB candidate when devicePath is empty. Unlike
+// the camera, the device is opened even when it reports bad health so that it can be inspected and reset. The device
+// is not opened when an rplidar-module process is using it.
+func OpenDevice(devicePath string, logger logging.Logger) (*Device, error) {
+	if devicePath == "" {
+		var err error
+		if devicePath, err = searchForDevicePath(logger); err != nil {
+			return nil, errors.Wrap(err, "need to specify a device path (ex. /dev/ttyUSB0)")
+		}
+	}
+
+	pid, err := moduleProcessUsing(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	if pid != 0 {
+		return nil, errors.Errorf("%q is in use by rplidar-module (PID %v), stop the robot before running diagnostics",
+			devicePath, pid)
+	}
+
+	device, err := connectRplidarDevice(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{
+		rp:   &rplidar{device: device, logger: logger},
+		path: devicePath,
+	}, nil
+}
+
+// moduleProcessUsing returns the PID of the rplidar-module process holding the lock file of devicePath, or zero.
+func moduleProcessUsing(devicePath string) (int, error) {
+	if len(devicePath) <= devicePathPrefixOffset {
+		return 0, nil
+	}
+	processes, err := getRplidarProcesses()
+	if err != nil {
+		return 0, errors.Wrap(err, "error getting rplidar-module related processes")
+	}
+	for _, pid := range processes {
+		lockFile := filepath.Join(rplidarModuleLockDir,
+			fmt.Sprintf(rplidarModuleLockFileName, pid, devicePath[devicePathPrefixOffset:]))
+		if _, err := os.Stat(lockFile); err == nil {
+			return pid, nil
+		}
+	}
+	return 0, nil
+}
+
+// Info returns the identity of the device read when it was opened.
+func (d *Device) Info() DeviceInfo {
+	return DeviceInfo{
+		Path:             d.path,
+		Model:            modelToString(rplidarModelByteMap[d.rp.device.model]),
+		ModelID:          d.rp.device.model,
+		SerialNumber:     d.rp.device.serialNumber,
+		FirmwareVersion:  d.rp.device.firmwareVersion,
+		HardwareRevision: d.rp.device.hardwareRevision,
+	}
+}
+
+// Health reads the health status of the device.
+func (d *Device) Health() (DeviceHealth, error) {
+	d.rp.device.mutex.Lock()
+	defer d.rp.device.mutex.Unlock()
+
+	health, err := d.rp.device.health()
+	if err != nil {
+		return DeviceHealth{}, err
+	}
+
+	status := "unknown"
+	switch health.status {
+	case gen.RPLIDAR_STATUS_OK:
+		status = "ok"
+	case gen.RPLIDAR_STATUS_WARNING:
+		status = "warning"
+	case gen.RPLIDAR_STATUS_ERROR:
+		status = "error"
+	}
+	return DeviceHealth{Status: status, ErrorCode: health.errorCode}, nil
+}
+
+// ScanModes returns the scan modes supported by the device.
+func (d *Device) ScanModes() ([]ScanMode, error) {
+	d.rp.device.mutex.Lock()
+	defer d.rp.device.mutex.Unlock()
+
+	return d.rp.device.scanModes()
+}
+
+// Scan captures count revolutions with the device's default scan mode. No filters are applied. The motor is started
+// on the first call and keeps spinning until the device is closed.
+func (d *Device) Scan(ctx context.Context, count int) ([]publisher.Revolution, error) {
+	if !d.scanning {
+		if err := d.rp.setupRPLidar(ctx); err != nil {
+			return nil, errors.Wrap(err, "there was a problem setting up the rplidar")
+		}
+		d.scanning = true
+	}
+
+	revolutions := make([]publisher.Revolution, 0, count)
+	last := time.Now()
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			return revolutions, err
+		}
+		scanned, err := d.rp.scanNodes(ctx, 1)
+		if err != nil {
+			return revolutions, err
+		}
+		now := time.Now()
+		revolutions = append(revolutions, publisher.Revolution{
+			Sequence:  uint64(i + 1),
+			Timestamp: now,
+			ScanTime:  now.Sub(last),
+			Nodes:     scanned,
+		})
+		last = now
+	}
+	return revolutions, nil
+}
+
+// Reset reboots the device's core, clearing a latched error, and waits for it to come back up.
+func (d *Device) Reset(ctx context.Context) error {
+	d.rp.device.mutex.Lock()
+	defer d.rp.device.mutex.Unlock()
+
+	if result := d.rp.device.driver.Reset(defaultDeviceTimeoutMs); Result(result) != ResultOk {
+		return fmt.Errorf("failed to reset: %w", Result(result).Failed())
+	}
+	d.scanning = false
+	goutils.SelectContextOrWait(ctx, defaultWarmUpTimeout)
+	return ctx.Err()
+}
+
+// Close stops the motor and disconnects from the device.
+func (d *Device) Close() {
+	d.rp.device.mutex.Lock()
+	defer d.rp.device.mutex.Unlock()
+	d.rp.closeDevice()
+}
@@ -0,0 +1,81 @@
+package rplidar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/inject"
+)
+
+func TestUSBSerialNumber(t *testing.T) {
+	sys := t.TempDir()
+	oldSysClassTTY := sysClassTTY
+	sysClassTTY = filepath.Join(sys, "class", "tty")
+	defer func() { sysClassTTY = oldSysClassTTY }()
+
+	// Mirror the sysfs layout of a CP210x bridge: the tty's device is a port below the USB interface, which is below
+	// the USB device that holds the serial number.
+	usbDevice := filepath.Join(sys, "devices", "usb1", "1-1")
+	port := filepath.Join(usbDevice, "1-1:1.0", "ttyUSB0")
+	test.That(t, os.MkdirAll(port, 0o750), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(usbDevice, "idVendor"), []byte("10c4\n"), 0o600), test.ShouldBeNil)
+	test.That(t, os.WriteFile(filepath.Join(usbDevice, "serial"), []byte("0001\n"), 0o600), test.ShouldBeNil)
+	test.That(t, os.MkdirAll(filepath.Join(sysClassTTY, "ttyUSB0"), 0o750), test.ShouldBeNil)
+	test.That(t, os.Symlink(port, filepath.Join(sysClassTTY, "ttyUSB0", "device")), test.ShouldBeNil)
+
+	test.That(t, usbSerialNumber("/dev/ttyUSB0"), test.ShouldEqual, "0001")
+	test.That(t, usbSerialNumber("/dev/ttyUSB1"), test.ShouldEqual, "")
+}
+
+func TestDevice(t *testing.T) {
+	ctx := context.Background()
+
+	injectedRPlidarDriver := inject.NewRPLiDARDriver()
+	failed := func(_ ...interface{}) uint {
+		return uint(gen.RESULT_OPERATION_FAIL)
+	}
+	injectedRPlidarDriver.ResetFunc = failed
+	injectedRPlidarDriver.GetHealthFunc = failed
+	injectedRPlidarDriver.GetAllSupportedScanModesFunc = failed
+
+	device := &Device{
+		rp: &rplidar{
+			device: &rplidarDevice{
+				driver:          &injectedRPlidarDriver,
+				model:           49,
+				serialNumber:    "ABCD",
+				firmwareVersion: "1.29",
+			},
+			logger: logging.NewTestLogger(t),
+		},
+		path: "/dev/ttyUSB0",
+	}
+
+	t.Run("info", func(t *testing.T) {
+		info := device.Info()
+		test.That(t, info.Path, test.ShouldEqual, "/dev/ttyUSB0")
+		test.That(t, info.Model, test.ShouldEqual, "A3")
+		test.That(t, info.SerialNumber, test.ShouldEqual, "ABCD")
+		test.That(t, info.FirmwareVersion, test.ShouldEqual, "1.29")
+	})
+
+	t.Run("driver failures are reported", func(t *testing.T) {
+		err := device.Reset(ctx)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "failed to reset")
+
+		_, err = device.Health()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "failed to get health")
+
+		_, err = device.ScanModes()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "failed to get scan modes")
+	})
+}
@@ -0,0 +1,321 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/base"
+	"go.viam.com/rdk/resource"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/zones"
+)
+
+const (
+	getEmergencyStopCommand   = "get_emergency_stop"
+	resetEmergencyStopCommand = "reset_emergency_stop"
+
+	// emergencyStopTimeout bounds each call to Stop, so that an unreachable base does not hold up the next attempt.
+	emergencyStopTimeout = time.Second
+
+	// emergencyStopWatchdogRevolutions is the number of revolution periods without a revolution after which the base
+	// is stopped, and emergencyStopDefaultPeriod the period assumed until one is measured, that of the slowest scan
+	// rate of the supported models.
+	emergencyStopWatchdogRevolutions = 3
+	emergencyStopDefaultPeriod       = 200 * time.Millisecond
+	// emergencyStopWatchdogInterval is how often the watchdog checks for revolutions, and so how often the base is
+	// stopped while none arrive.
+	emergencyStopWatchdogInterval = 50 * time.Millisecond
+
+	// The reasons the emergency stop was engaged.
+	emergencyStopIntruded          = "sector intruded"
+	emergencyStopNoRevolution      = "no revolution received"
+	emergencyStopAcquisitionFailed = "acquisition failed"
+)
+
+// EmergencyStopConfig describes when the rplidar stops a base by itself: when the nearest return in a sector falls
+// below a distance for consecutive revolutions.
+type EmergencyStopConfig struct {
+	// Base is the name of the base to stop.
+	Base string `json:"base"`
+	// Frame is the frame of the sector, either "sensor" (the default) or "base".
+	Frame          string  `json:"frame,omitempty"`
+	StartDeg       float64 `json:"start_deg"`
+	EndDeg         float64 `json:"end_deg"`
+	StopDistanceMM float64 `json:"stop_distance_mm"`
+	// ConsecutiveRevolutions is the number of revolutions the sector must be intruded before stopping, and clear
+	// before an automatic reset.
+	ConsecutiveRevolutions int `json:"consecutive_revolutions,omitempty"`
+	// AutoReset releases the stop once the sector is clear again, instead of latching until reset through DoCommand.
+	AutoReset bool `json:"auto_reset,omitempty"`
+}
+
+// zone returns the protection zone equivalent to the emergency stop sector.
+func (conf *EmergencyStopConfig) zone() zones.Config {
+	return zones.Config{
+		Name:                "emergency_stop",
+		Frame:               conf.Frame,
+		Arc:                 &zones.ArcConfig{StartDeg: conf.StartDeg, EndDeg: conf.EndDeg, StopDistanceMM: conf.StopDistanceMM},
+		DebounceRevolutions: conf.ConsecutiveRevolutions,
+	}
+}
+
+// Validate checks that the emergency stop attributes are valid.
+func (conf *EmergencyStopConfig) Validate() error {
+	if conf.Base == "" {
+		return errors.New("base must be set")
+	}
+	if conf.StopDistanceMM <= 0 {
+		return errors.New("stop_distance_mm must be positive")
+	}
+	if conf.ConsecutiveRevolutions < 0 {
+		return errors.New("consecutive_revolutions must be positive")
+	}
+	zone := conf.zone()
+	return zone.Validate()
+}
+
+// stopper is the part of a base used by the emergency stop.
+type stopper interface {
+	Stop(ctx context.Context, extra map[string]interface{}) error
+}
+
+// emergencyStop stops a base when the stop sector is intruded, and when revolutions fail or stop arriving, since the
+// sector cannot be watched without them. Stop requests are handed to a separate goroutine so that a slow base never
+// delays the processing of revolutions.
+type emergencyStop struct {
+	base      stopper
+	monitor   *zones.Monitor
+	autoReset bool
+	requests  chan struct{}
+
+	mutex        sync.Mutex
+	latched      bool
+	reason       string
+	triggeredAt  time.Time
+	triggerCount uint64
+	lastStopErr  error
+	// lastRevolution is when the most recent revolution was evaluated, or when the emergency stop was created, and
+	// period is the moving average of the time between revolutions.
+	lastRevolution time.Time
+	period         time.Duration
+}
+
+func newEmergencyStop(conf *EmergencyStopConfig, b stopper, mounting geometry.Pose) *emergencyStop {
+	return &emergencyStop{
+		base:           b,
+		monitor:        zones.NewMonitor([]zones.Config{conf.zone()}, mounting),
+		autoReset:      conf.AutoReset,
+		requests:       make(chan struct{}, 1),
+		lastRevolution: time.Now(),
+	}
+}
+
+// watchdogTimeout returns how long the emergency stop waits for a revolution before stopping the base. e.mutex must
+// be held.
+func (e *emergencyStop) watchdogTimeout() time.Duration {
+	period := e.period
+	if period == 0 {
+		period = emergencyStopDefaultPeriod
+	}
+	return emergencyStopWatchdogRevolutions * period
+}
+
+// latch engages the stop for reason, and reports whether it was not engaged already. e.mutex must be held.
+func (e *emergencyStop) latch(reason string, now time.Time) bool {
+	if e.latched {
+		return false
+	}
+	e.latched = true
+	e.reason = reason
+	e.triggeredAt = now
+	e.triggerCount++
+	return true
+}
+
+// requestStop asks the stop goroutine to stop the base.
+func (e *emergencyStop) requestStop() {
+	select {
+	case e.requests <- struct{}{}:
+	default:
+		// A stop is already pending.
+	}
+}
+
+// setupEmergencyStop looks up the base of the emergency stop and starts the goroutine that stops it.
+func (rp *rplidar) setupEmergencyStop(ctx context.Context, conf *Config, deps resource.Dependencies) error {
+	if conf.EmergencyStop == nil {
+		return nil
+	}
+	b, err := base.FromProvider(deps, conf.EmergencyStop.Base)
+	if err != nil {
+		return errors.Wrap(err, "could not get the emergency stop base")
+	}
+	var mounting geometry.Pose
+	if conf.Mounting != nil {
+		mounting = *conf.Mounting
+	}
+	rp.estop = newEmergencyStop(conf.EmergencyStop, b, mounting)
+
+	rp.cacheBackgroundWorkers.Add(2)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.stopBaseLoop(ctx)
+	}()
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.emergencyStopWatchdog(ctx)
+	}()
+	return nil
+}
+
+// evaluateEmergencyStop updates the stop sector with a revolution, and requests a stop for as long as the emergency
+// stop is engaged, so that commands sent to the base in the meantime are overridden. The revolution is evaluated
+// before the outlier and median filters, which could remove a real close return as noise.
+func (rp *rplidar) evaluateEmergencyStop(scanned []nodes.Node, now time.Time) {
+	e := rp.estop
+	if e == nil {
+		return
+	}
+
+	events := e.monitor.Evaluate(scanned, now)
+	intruded := e.monitor.States()[0].Level == zones.LevelStop
+	e.mutex.Lock()
+	// Gaps long enough to trip the watchdog are not revolution periods.
+	if interval := now.Sub(e.lastRevolution); interval > 0 && interval < e.watchdogTimeout() {
+		e.period = smooth(e.period, interval)
+	}
+	e.lastRevolution = now
+	for _, event := range events {
+		switch {
+		case event.To == zones.LevelStop:
+			if e.latch(emergencyStopIntruded, now) {
+				rp.logger.Warnf("emergency stop: return at %.0f mm, stopping the base", event.Closest.DistanceMM)
+			}
+			e.reason = emergencyStopIntruded
+		case event.To == zones.LevelClear && e.autoReset && e.latched:
+			e.latched = false
+			e.reason = ""
+			rp.logger.Info("emergency stop: sector clear, releasing the base")
+		}
+	}
+	// A stop engaged because revolutions were missing is released once they are back, if the sector is clear.
+	if e.autoReset && e.latched && e.reason != emergencyStopIntruded && !intruded {
+		e.latched = false
+		e.reason = ""
+		rp.logger.Info("emergency stop: revolutions received again, releasing the base")
+	}
+	latched := e.latched
+	e.mutex.Unlock()
+
+	if latched {
+		e.requestStop()
+	}
+}
+
+// engageEmergencyStop stops the base because the sector cannot be watched, such as when a revolution could not be
+// acquired.
+func (rp *rplidar) engageEmergencyStop(reason string, now time.Time) {
+	e := rp.estop
+	if e == nil {
+		return
+	}
+
+	e.mutex.Lock()
+	if e.latch(reason, now) {
+		rp.logger.Warnf("emergency stop: %s, stopping the base", reason)
+	}
+	e.mutex.Unlock()
+	e.requestStop()
+}
+
+// emergencyStopWatchdog stops the base whenever no revolution has been evaluated for a few revolution periods, such
+// as when the driver stalls, until ctx is done.
+func (rp *rplidar) emergencyStopWatchdog(ctx context.Context) {
+	e := rp.estop
+	ticker := time.NewTicker(emergencyStopWatchdogInterval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		e.mutex.Lock()
+		stalled := now.Sub(e.lastRevolution) > e.watchdogTimeout()
+		e.mutex.Unlock()
+		if stalled {
+			rp.engageEmergencyStop(emergencyStopNoRevolution, now)
+		}
+	}
+}
+
+// stopBaseLoop stops the base for every request, until ctx is done.
+func (rp *rplidar) stopBaseLoop(ctx context.Context) {
+	e := rp.estop
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.requests:
+		}
+
+		stopCtx, cancel := context.WithTimeout(ctx, emergencyStopTimeout)
+		err := e.base.Stop(stopCtx, nil)
+		cancel()
+		if err != nil {
+			rp.logger.Errorf("emergency stop: failed to stop the base: %v", err)
+		}
+		e.mutex.Lock()
+		e.lastStopErr = err
+		e.mutex.Unlock()
+	}
+}
+
+// getEmergencyStop returns whether the emergency stop is engaged, and the state of its sector.
+func (rp *rplidar) getEmergencyStop(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	e := rp.estop
+	if e == nil {
+		return nil, errors.New("emergency_stop is not configured")
+	}
+
+	state := e.monitor.States()[0]
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	out := map[string]interface{}{
+		"latched":       e.latched,
+		"reason":        e.reason,
+		"trigger_count": float64(e.triggerCount),
+		"sector":        state.Map(time.Now()),
+	}
+	if !e.triggeredAt.IsZero() {
+		out["triggered_at"] = e.triggeredAt.Format(time.RFC3339Nano)
+	}
+	if e.lastStopErr != nil {
+		out["last_stop_error"] = e.lastStopErr.Error()
+	}
+	return out, nil
+}
+
+// resetEmergencyStop releases a latched emergency stop. The stop is not released while the sector is intruded.
+func (rp *rplidar) resetEmergencyStop(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	e := rp.estop
+	if e == nil {
+		return nil, errors.New("emergency_stop is not configured")
+	}
+	if state := e.monitor.States()[0]; state.Level == zones.LevelStop {
+		return nil, errors.New("cannot reset the emergency stop while the sector is intruded")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.latched = false
+	e.reason = ""
+	return map[string]interface{}{"latched": false}, nil
+}
@@ -0,0 +1,244 @@
+package rplidar
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/zones"
+)
+
+// fakeBase counts the calls to Stop on a channel.
+type fakeBase struct {
+	stops chan struct{}
+	err   error
+}
+
+func (b *fakeBase) Stop(_ context.Context, _ map[string]interface{}) error {
+	b.stops <- struct{}{}
+	return b.err
+}
+
+func TestEmergencyStopConfigValidate(t *testing.T) {
+	conf := EmergencyStopConfig{Base: "base", StartDeg: 150, EndDeg: 210, StopDistanceMM: 400, ConsecutiveRevolutions: 2}
+	test.That(t, conf.Validate(), test.ShouldBeNil)
+
+	cfg := Config{EmergencyStop: &conf}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"base"})
+
+	cfg.EmergencyStop = &EmergencyStopConfig{Base: "base", Frame: zones.FrameBase, StartDeg: 150, EndDeg: 210, StopDistanceMM: 400}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "emergency_stop is in the base frame, which needs mounting to be set")
+
+	for _, tc := range []struct {
+		conf EmergencyStopConfig
+		err  string
+	}{
+		{EmergencyStopConfig{StopDistanceMM: 400}, "base must be set"},
+		{EmergencyStopConfig{Base: "base"}, "stop_distance_mm must be positive"},
+		{EmergencyStopConfig{Base: "base", StopDistanceMM: 400, ConsecutiveRevolutions: -1}, "consecutive_revolutions must be positive"},
+		{EmergencyStopConfig{Base: "base", StopDistanceMM: 400, Frame: "world"}, `frame must be "sensor" or "base"`},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+func TestEmergencyStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := &fakeBase{stops: make(chan struct{}, 10)}
+	// Node angle 0 lands at 180 degrees in the frame of the points.
+	conf := &EmergencyStopConfig{Base: "base", StartDeg: 150, EndDeg: 210, StopDistanceMM: 400, ConsecutiveRevolutions: 2}
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+	rp.estop = newEmergencyStop(conf, b, geometry.Pose{})
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.stopBaseLoop(ctx)
+	}()
+
+	clear := []nodes.Node{{Angle: 0, Distance: 1000}, {Angle: 90, Distance: 100}}
+	blocked := []nodes.Node{{Angle: 0, Distance: 300}}
+	getState := func() map[string]interface{} {
+		state, err := rp.DoCommand(ctx, map[string]interface{}{getEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		return state
+	}
+
+	rp.evaluateEmergencyStop(clear, time.Now())
+	rp.evaluateEmergencyStop(blocked, time.Now())
+	test.That(t, getState()["latched"], test.ShouldBeFalse)
+	test.That(t, b.stops, test.ShouldBeEmpty)
+
+	rp.evaluateEmergencyStop(blocked, time.Now())
+	<-b.stops
+	state := getState()
+	test.That(t, state["latched"], test.ShouldBeTrue)
+	test.That(t, state["trigger_count"], test.ShouldEqual, 1.)
+
+	t.Run("stops again on every revolution while latched", func(t *testing.T) {
+		rp.evaluateEmergencyStop(clear, time.Now())
+		<-b.stops
+		rp.evaluateEmergencyStop(clear, time.Now())
+		<-b.stops
+		test.That(t, getState()["latched"], test.ShouldBeTrue)
+	})
+
+	t.Run("reset is refused while the sector is intruded", func(t *testing.T) {
+		rp.evaluateEmergencyStop(blocked, time.Now())
+		<-b.stops
+		rp.evaluateEmergencyStop(blocked, time.Now())
+		<-b.stops
+		_, err := rp.DoCommand(ctx, map[string]interface{}{resetEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "cannot reset the emergency stop while the sector is intruded")
+	})
+
+	t.Run("reset releases the base", func(t *testing.T) {
+		rp.evaluateEmergencyStop(clear, time.Now())
+		<-b.stops
+		rp.evaluateEmergencyStop(clear, time.Now())
+		<-b.stops
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{resetEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["latched"], test.ShouldBeFalse)
+
+		rp.evaluateEmergencyStop(clear, time.Now())
+		test.That(t, b.stops, test.ShouldBeEmpty)
+		test.That(t, getState()["latched"], test.ShouldBeFalse)
+	})
+
+	t.Run("failures to stop are reported", func(t *testing.T) {
+		b.err = errors.New("base unreachable")
+		rp.evaluateEmergencyStop(blocked, time.Now())
+		rp.evaluateEmergencyStop(blocked, time.Now())
+		<-b.stops
+		cancel()
+		rp.cacheBackgroundWorkers.Wait()
+		state := getState()
+		test.That(t, state["trigger_count"], test.ShouldEqual, 2.)
+		test.That(t, state["last_stop_error"], test.ShouldEqual, "base unreachable")
+	})
+}
+
+func TestEmergencyStopAutoReset(t *testing.T) {
+	b := &fakeBase{stops: make(chan struct{}, 10)}
+	conf := &EmergencyStopConfig{Base: "base", StartDeg: 150, EndDeg: 210, StopDistanceMM: 400, AutoReset: true}
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+	rp.estop = newEmergencyStop(conf, b, geometry.Pose{})
+
+	rp.evaluateEmergencyStop([]nodes.Node{{Angle: 0, Distance: 300}}, time.Now())
+	test.That(t, rp.estop.latched, test.ShouldBeTrue)
+	test.That(t, len(rp.estop.requests), test.ShouldEqual, 1)
+
+	rp.evaluateEmergencyStop([]nodes.Node{{Angle: 0, Distance: 1000}}, time.Now())
+	test.That(t, rp.estop.latched, test.ShouldBeFalse)
+
+	_, err := (&rplidar{}).getEmergencyStop(context.Background(), nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "emergency_stop is not configured")
+}
+
+func TestEmergencyStopWatchdog(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := &fakeBase{stops: make(chan struct{}, 10)}
+	conf := &EmergencyStopConfig{Base: "base", StartDeg: 150, EndDeg: 210, StopDistanceMM: 400, AutoReset: true}
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+	rp.estop = newEmergencyStop(conf, b, geometry.Pose{})
+	start := time.Now()
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.emergencyStopWatchdog(ctx)
+	}()
+	getState := func() map[string]interface{} {
+		state, err := rp.DoCommand(ctx, map[string]interface{}{getEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		return state
+	}
+
+	// No revolution arrives, so the base is stopped once a few revolution periods have passed.
+	waitFor(t, func() bool { return getState()["latched"] == true })
+	test.That(t, time.Since(start), test.ShouldBeGreaterThanOrEqualTo, emergencyStopWatchdogRevolutions*emergencyStopDefaultPeriod)
+	test.That(t, getState()["reason"], test.ShouldEqual, emergencyStopNoRevolution)
+	test.That(t, len(rp.estop.requests), test.ShouldEqual, 1)
+
+	// Revolutions of a clear sector release a stop that resets automatically.
+	rp.evaluateEmergencyStop([]nodes.Node{{Angle: 0, Distance: 1000}}, time.Now())
+	state := getState()
+	test.That(t, state["latched"], test.ShouldBeFalse)
+	test.That(t, state["reason"], test.ShouldEqual, "")
+	test.That(t, state["trigger_count"], test.ShouldEqual, 1.)
+
+	cancel()
+	rp.cacheBackgroundWorkers.Wait()
+}
+
+func TestEmergencyStopPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := &fakeBase{stops: make(chan struct{}, 10)}
+	conf := &Config{
+		OutlierFilter: &filters.OutlierConfig{RadiusMM: 100, MinNeighbors: 2},
+		EmergencyStop: &EmergencyStopConfig{Base: "base", StartDeg: 150, EndDeg: 210, StopDistanceMM: 400},
+	}
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t), pipelineStats: &pipelineStats{}}
+	rp.setupFilters(conf)
+	rp.estop = newEmergencyStop(conf.EmergencyStop, b, geometry.Pose{})
+	ring := &revolutionRing{free: make(chan *rawRevolution, 2), full: make(chan *rawRevolution, 2)}
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.processingLoop(ctx, ring)
+	}()
+	getState := func() map[string]interface{} {
+		state, err := rp.DoCommand(ctx, map[string]interface{}{getEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		return state
+	}
+
+	t.Run("a failed revolution stops the base", func(t *testing.T) {
+		ring.full <- &rawRevolution{err: errors.New("bad scan"), timestamp: time.Now()}
+		waitFor(t, func() bool { return getState()["latched"] == true })
+		test.That(t, getState()["reason"], test.ShouldEqual, emergencyStopAcquisitionFailed)
+
+		_, err := rp.DoCommand(ctx, map[string]interface{}{resetEmergencyStopCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+	})
+
+	t.Run("the sector is checked before smoothing", func(t *testing.T) {
+		// A lone close return, such as a thin table leg, is an outlier to the filter but still stops the base.
+		ring.full <- &rawRevolution{nodes: []gen.MeasurementNodeHq{
+			{AngleZQ14: 0, DistMMQ2: 300 << 2, Quality: 47},
+			{AngleZQ14: 16384, DistMMQ2: 2000 << 2, Quality: 47},
+		}, timestamp: time.Now()}
+		waitFor(t, func() bool { return getState()["latched"] == true })
+		test.That(t, getState()["reason"], test.ShouldEqual, emergencyStopIntruded)
+
+		waitFor(t, func() bool {
+			rp.cache.mutex.RLock()
+			defer rp.cache.mutex.RUnlock()
+			return rp.cache.nodes != nil
+		})
+		rp.cache.mutex.RLock()
+		defer rp.cache.mutex.RUnlock()
+		test.That(t, rp.cache.nodes, test.ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,73 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/features"
+	"go.viam.com/rplidar/nodes"
+)
+
+const getFeaturesCommand = "get_features"
+
+// featureExtractor holds the line segments and corners of the most recent revolution.
+type featureExtractor struct {
+	conf features.Config
+
+	mutex     sync.Mutex
+	latest    features.Features
+	timestamp time.Time
+}
+
+// setupFeatures enables feature extraction when configured.
+func (rp *rplidar) setupFeatures(conf *Config) {
+	if conf.Features == nil {
+		return
+	}
+	rp.features = &featureExtractor{conf: *conf.Features}
+}
+
+// extractFeatures fits line segments and corners to a filtered revolution.
+func (rp *rplidar) extractFeatures(scanned []nodes.Node, now time.Time) {
+	f := rp.features
+	if f == nil {
+		return
+	}
+	extracted := features.Extract(scanned, f.conf)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.latest = extracted
+	f.timestamp = now
+}
+
+// getFeatures returns the line segments and corners of the most recent revolution, in the frame of the point cloud.
+func (rp *rplidar) getFeatures(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	f := rp.features
+	if f == nil {
+		return nil, errors.New("features are not configured")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.timestamp.IsZero() {
+		return nil, errors.New("no revolution has been captured yet")
+	}
+	segments := make([]interface{}, 0, len(f.latest.Segments))
+	for _, segment := range f.latest.Segments {
+		segments = append(segments, segment.Map())
+	}
+	corners := make([]interface{}, 0, len(f.latest.Corners))
+	for _, corner := range f.latest.Corners {
+		corners = append(corners, corner.Map())
+	}
+	return map[string]interface{}{
+		"timestamp": f.timestamp.Format(time.RFC3339Nano),
+		"segments":  segments,
+		"corners":   corners,
+	}, nil
+}
@@ -0,0 +1,331 @@
+// Package features extracts line segments, such as walls, and the corners between them from a revolution of RPLiDAR
+// measurement nodes, using split-and-merge over the nodes in angle order.
+package features
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/clusters"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultMaxFitErrorMM       = 20.
+	defaultMaxGapMM            = 150.
+	defaultMinPoints           = 6
+	defaultMinLengthMM         = 200.
+	defaultCornerMinAngleDeg   = 45.
+	defaultCornerMaxDistanceMM = 150.
+)
+
+// Config describes how line segments and corners are extracted. Distances are in millimeters.
+type Config struct {
+	// MaxFitErrorMM is the largest distance of a point from the line of its segment.
+	MaxFitErrorMM float64 `json:"max_fit_error_mm,omitempty"`
+	// MaxGapMM is the largest gap between consecutive points of a segment at short range. The gap allowed grows
+	// with range, as for clustering.
+	MaxGapMM    float64 `json:"max_gap_mm,omitempty"`
+	MinPoints   int     `json:"min_points,omitempty"`
+	MinLengthMM float64 `json:"min_length_mm,omitempty"`
+	// CornerMinAngleDeg is the smallest angle between the lines of consecutive segments that forms a corner.
+	CornerMinAngleDeg float64 `json:"corner_min_angle_deg,omitempty"`
+	// CornerMaxDistanceMM is the largest distance from the intersection of the lines to the ends of the segments.
+	CornerMaxDistanceMM float64 `json:"corner_max_distance_mm,omitempty"`
+}
+
+// Validate checks that the feature attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.MaxFitErrorMM < 0 || conf.MaxGapMM < 0 || conf.MinLengthMM < 0 || conf.CornerMaxDistanceMM < 0 {
+		return errors.New("distances must be positive")
+	}
+	if conf.MinPoints < 0 {
+		return errors.New("min_points must be positive")
+	}
+	if conf.MinPoints == 1 {
+		return errors.New("min_points must be at least 2")
+	}
+	if conf.CornerMinAngleDeg < 0 || conf.CornerMinAngleDeg > 90 {
+		return errors.New("corner_min_angle_deg must be between 0 and 90")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.MaxFitErrorMM == 0 {
+		conf.MaxFitErrorMM = defaultMaxFitErrorMM
+	}
+	if conf.MaxGapMM == 0 {
+		conf.MaxGapMM = defaultMaxGapMM
+	}
+	if conf.MinPoints == 0 {
+		conf.MinPoints = defaultMinPoints
+	}
+	if conf.MinLengthMM == 0 {
+		conf.MinLengthMM = defaultMinLengthMM
+	}
+	if conf.CornerMinAngleDeg == 0 {
+		conf.CornerMinAngleDeg = defaultCornerMinAngleDeg
+	}
+	if conf.CornerMaxDistanceMM == 0 {
+		conf.CornerMaxDistanceMM = defaultCornerMaxDistanceMM
+	}
+	return conf
+}
+
+// Segment is a line segment fitted to consecutive returns, in the frame of the point cloud.
+type Segment struct {
+	// Start and End are the first and last returns of the segment, projected onto its line.
+	Start, End geometry.Point
+	// Normal is the unit normal of the line, pointing towards the lidar.
+	Normal geometry.Point
+	// DistanceMM is the distance from the lidar to the line.
+	DistanceMM float64
+	LengthMM   float64
+	// FitErrorMM is the root mean square distance of the returns from the line.
+	FitErrorMM float64
+	Points     int
+}
+
+// Corner is the intersection of the lines of two consecutive segments.
+type Corner struct {
+	Point geometry.Point
+	// AngleDeg is the angle between the lines, between 0 and 90.
+	AngleDeg float64
+	// Segments are the indices of the two segments.
+	Segments [2]int
+}
+
+// Features are the segments and corners of a revolution.
+type Features struct {
+	Segments []Segment
+	Corners  []Corner
+}
+
+// line is a line fitted to points by total least squares.
+type line struct {
+	centroid, direction, normal geometry.Point
+	rmsError, maxError          float64
+}
+
+// fit returns the line that minimizes the perpendicular distances of the points.
+func fit(points []geometry.Point) line {
+	var l line
+	n := float64(len(points))
+	for _, pt := range points {
+		l.centroid.X += pt.X / n
+		l.centroid.Y += pt.Y / n
+	}
+	var sxx, syy, sxy float64
+	for _, pt := range points {
+		dx, dy := pt.X-l.centroid.X, pt.Y-l.centroid.Y
+		sxx += dx * dx
+		syy += dy * dy
+		sxy += dx * dy
+	}
+	theta := math.Atan2(2*sxy, sxx-syy) / 2
+	sin, cos := math.Sincos(theta)
+	l.direction = geometry.Point{X: cos, Y: sin}
+	l.normal = geometry.Point{X: -sin, Y: cos}
+
+	var sumSquares float64
+	for _, pt := range points {
+		d := math.Abs(l.distance(pt))
+		sumSquares += d * d
+		l.maxError = math.Max(l.maxError, d)
+	}
+	l.rmsError = math.Sqrt(sumSquares / n)
+	return l
+}
+
+// distance returns the signed distance of a point from the line.
+func (l line) distance(pt geometry.Point) float64 {
+	return (pt.X-l.centroid.X)*l.normal.X + (pt.Y-l.centroid.Y)*l.normal.Y
+}
+
+// project returns the point of the line closest to pt.
+func (l line) project(pt geometry.Point) geometry.Point {
+	t := (pt.X-l.centroid.X)*l.direction.X + (pt.Y-l.centroid.Y)*l.direction.Y
+	return geometry.Point{X: l.centroid.X + t*l.direction.X, Y: l.centroid.Y + t*l.direction.Y}
+}
+
+// Extract returns the segments and corners of a revolution sorted by ascending angle. Nodes without a return must
+// already be removed. Segments are in angle order, and corners are only found between consecutive segments.
+func Extract(revolution []nodes.Node, conf Config) Features {
+	conf = conf.withDefaults()
+	clusterConf := clusters.Config{GapMM: conf.MaxGapMM, MinPoints: conf.MinPoints}
+	var features Features
+	for _, cluster := range clusters.Segment(revolution, clusterConf) {
+		points := make([]geometry.Point, 0, len(cluster)+1)
+		for _, n := range cluster {
+			points = append(points, n.Point())
+		}
+		spans := splitAndMerge(points, conf.MaxFitErrorMM)
+
+		closed := len(cluster) == len(revolution) && clusterConf.Connected(cluster[len(cluster)-1], cluster[0])
+		if closed && len(spans) > 1 {
+			// The returns surround the lidar, so starting at angle 0 would break the line through it in two. The loop
+			// starts and ends at the end of the first span instead.
+			k := spans[0][1]
+			points = append(append([]geometry.Point{}, points[k:]...), points[:k+1]...)
+			spans = splitAndMerge(points, conf.MaxFitErrorMM)
+		}
+
+		first := len(features.Segments)
+		for _, span := range spans {
+			segment, ok := newSegment(points[span[0]:span[1]+1], conf)
+			if !ok {
+				continue
+			}
+			if len(features.Segments) > first {
+				features.addCorner(len(features.Segments)-1, segment, len(features.Segments), conf)
+			}
+			features.Segments = append(features.Segments, segment)
+		}
+		if last := len(features.Segments) - 1; closed && last > first+1 {
+			features.addCorner(last, features.Segments[first], first, conf)
+		}
+	}
+	return features
+}
+
+// addCorner adds the corner between the segment at index previous and the following segment at index next, if they
+// form one.
+func (f *Features) addCorner(previous int, next Segment, nextIndex int, conf Config) {
+	if corner, ok := newCorner(f.Segments[previous], next, conf); ok {
+		corner.Segments = [2]int{previous, nextIndex}
+		f.Corners = append(f.Corners, corner)
+	}
+}
+
+// splitAndMerge divides points into spans of consecutive points that each fit a line within maxError.
+func splitAndMerge(points []geometry.Point, maxError float64) [][2]int {
+	spans := merge(points, split(points, 0, len(points)-1, maxError, nil), maxError)
+	return assignBreakpoints(points, spans)
+}
+
+// split recursively divides points[first:last+1] at the point furthest from the chord between its ends, until every
+// point is within maxError of its chord, and appends the resulting spans to spans. Consecutive spans share the point
+// they were split at.
+func split(points []geometry.Point, first, last int, maxError float64, spans [][2]int) [][2]int {
+	a, b := points[first], points[last]
+	dx, dy := b.X-a.X, b.Y-a.Y
+	length := math.Hypot(dx, dy)
+
+	furthest, furthestDistance := -1, maxError
+	for i := first + 1; i < last; i++ {
+		var d float64
+		if length == 0 {
+			d = math.Hypot(points[i].X-a.X, points[i].Y-a.Y)
+		} else {
+			d = math.Abs(dx*(a.Y-points[i].Y)-dy*(a.X-points[i].X)) / length
+		}
+		if d > furthestDistance {
+			furthest, furthestDistance = i, d
+		}
+	}
+	if furthest < 0 {
+		return append(spans, [2]int{first, last})
+	}
+	spans = split(points, first, furthest, maxError, spans)
+	return split(points, furthest, last, maxError, spans)
+}
+
+// merge joins consecutive spans whose points together still fit a line within maxError, undoing the splits that
+// the chord approximation made unnecessarily.
+func merge(points []geometry.Point, spans [][2]int, maxError float64) [][2]int {
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if fit(points[last[0]:span[1]+1]).maxError <= maxError {
+			last[1] = span[1]
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// assignBreakpoints keeps each point shared by consecutive spans only in the span whose line it is closer to, since
+// the returns rarely fall exactly on a corner.
+func assignBreakpoints(points []geometry.Point, spans [][2]int) [][2]int {
+	for i := 1; i < len(spans); i++ {
+		previous, next := &spans[i-1], &spans[i]
+		k := next[0]
+		if k-previous[0] < 2 || next[1]-k < 2 {
+			continue
+		}
+		pt := points[k]
+		if math.Abs(fit(points[previous[0]:k]).distance(pt)) <= math.Abs(fit(points[k+1:next[1]+1]).distance(pt)) {
+			next[0]++
+		} else {
+			previous[1]--
+		}
+	}
+	return spans
+}
+
+// newSegment fits a segment to points, and reports whether it is long enough to keep.
+func newSegment(points []geometry.Point, conf Config) (Segment, bool) {
+	if len(points) < conf.MinPoints {
+		return Segment{}, false
+	}
+	l := fit(points)
+	segment := Segment{
+		Start:      l.project(points[0]),
+		End:        l.project(points[len(points)-1]),
+		Normal:     l.normal,
+		DistanceMM: l.distance(geometry.Point{}),
+		FitErrorMM: l.rmsError,
+		Points:     len(points),
+	}
+	if segment.DistanceMM < 0 {
+		segment.Normal = geometry.Point{X: -l.normal.X, Y: -l.normal.Y}
+		segment.DistanceMM = -segment.DistanceMM
+	}
+	segment.LengthMM = math.Hypot(segment.End.X-segment.Start.X, segment.End.Y-segment.Start.Y)
+	return segment, segment.LengthMM >= conf.MinLengthMM
+}
+
+// newCorner intersects the lines of two consecutive segments, and reports whether they form a corner.
+func newCorner(a, b Segment, conf Config) (Corner, bool) {
+	// The lines are n·p = -d, since the normals point towards the origin.
+	det := a.Normal.X*b.Normal.Y - a.Normal.Y*b.Normal.X
+	angle := math.Asin(math.Min(math.Abs(det), 1)) * 180 / math.Pi
+	if angle < conf.CornerMinAngleDeg {
+		return Corner{}, false
+	}
+	pt := geometry.Point{
+		X: (-a.DistanceMM*b.Normal.Y + b.DistanceMM*a.Normal.Y) / det,
+		Y: (-b.DistanceMM*a.Normal.X + a.DistanceMM*b.Normal.X) / det,
+	}
+	if math.Hypot(pt.X-a.End.X, pt.Y-a.End.Y) > conf.CornerMaxDistanceMM ||
+		math.Hypot(pt.X-b.Start.X, pt.Y-b.Start.Y) > conf.CornerMaxDistanceMM {
+		return Corner{}, false
+	}
+	return Corner{Point: pt, AngleDeg: angle}, true
+}
+
+// Map returns the segment in a layout suitable for a DoCommand response.
+func (s Segment) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"start_mm":     []interface{}{s.Start.X, s.Start.Y},
+		"end_mm":       []interface{}{s.End.X, s.End.Y},
+		"normal":       []interface{}{s.Normal.X, s.Normal.Y},
+		"distance_mm":  s.DistanceMM,
+		"length_mm":    s.LengthMM,
+		"fit_error_mm": s.FitErrorMM,
+		"points":       float64(s.Points),
+	}
+}
+
+// Map returns the corner in a layout suitable for a DoCommand response.
+func (c Corner) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"point_mm":  []interface{}{c.Point.X, c.Point.Y},
+		"angle_deg": c.AngleDeg,
+		"segments":  []interface{}{float64(c.Segments[0]), float64(c.Segments[1])},
+	}
+}
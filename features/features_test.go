@@ -0,0 +1,108 @@
+package features
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{MaxFitErrorMM: -1}, "distances must be positive"},
+		{Config{MinPoints: -1}, "min_points must be positive"},
+		{Config{MinPoints: 1}, "min_points must be at least 2"},
+		{Config{CornerMinAngleDeg: 100}, "corner_min_angle_deg must be between 0 and 90"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// room returns the returns of a revolution, every degree, inside a box with walls at x = ±halfX and y = ±halfY.
+// Angles with no wall closer than maxMM have no return.
+func room(halfX, halfY, maxMM float64) []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 0.; angle < 360; angle++ {
+		// Invert nodes.Node.Point to find the direction of the ray in the frame of the point cloud.
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		dx, dy := -cos, sin
+		distance := math.Inf(1)
+		if dx != 0 {
+			distance = math.Min(distance, halfX/math.Abs(dx))
+		}
+		if dy != 0 {
+			distance = math.Min(distance, halfY/math.Abs(dy))
+		}
+		if distance <= maxMM {
+			revolution = append(revolution, nodes.Node{Angle: angle, Distance: distance})
+		}
+	}
+	return revolution
+}
+
+func TestExtract(t *testing.T) {
+	t.Run("a closed room has four walls and four corners", func(t *testing.T) {
+		features := Extract(room(2000, 1500, math.Inf(1)), Config{})
+		test.That(t, len(features.Segments), test.ShouldEqual, 4)
+		test.That(t, len(features.Corners), test.ShouldEqual, 4)
+
+		var distances []float64
+		for _, segment := range features.Segments {
+			test.That(t, segment.FitErrorMM, test.ShouldBeLessThan, 1)
+			distances = append(distances, segment.DistanceMM)
+			// The normals point towards the lidar, so the walls are at n·p = -d.
+			for _, pt := range []geometry.Point{segment.Start, segment.End} {
+				test.That(t, segment.Normal.X*pt.X+segment.Normal.Y*pt.Y, test.ShouldAlmostEqual, -segment.DistanceMM, 1e-6)
+			}
+		}
+		for _, d := range distances {
+			test.That(t, math.Min(math.Abs(d-2000), math.Abs(d-1500)), test.ShouldBeLessThan, 1)
+		}
+
+		for _, corner := range features.Corners {
+			test.That(t, corner.AngleDeg, test.ShouldAlmostEqual, 90, 1)
+			test.That(t, math.Abs(corner.Point.X), test.ShouldAlmostEqual, 2000, 1)
+			test.That(t, math.Abs(corner.Point.Y), test.ShouldAlmostEqual, 1500, 1)
+			test.That(t, corner.Segments[0], test.ShouldNotEqual, corner.Segments[1])
+		}
+	})
+
+	t.Run("a single wall", func(t *testing.T) {
+		// Only the wall at y = 1000 is within range.
+		var wall []nodes.Node
+		for _, n := range room(5000, 1000, 1500) {
+			if n.Angle > 40 && n.Angle < 140 {
+				wall = append(wall, n)
+			}
+		}
+		features := Extract(wall, Config{})
+		test.That(t, len(features.Segments), test.ShouldEqual, 1)
+		test.That(t, features.Corners, test.ShouldBeEmpty)
+		segment := features.Segments[0]
+		test.That(t, segment.DistanceMM, test.ShouldAlmostEqual, 1000, 1e-6)
+		test.That(t, segment.Normal.X, test.ShouldAlmostEqual, 0, 1e-9)
+		test.That(t, segment.Normal.Y, test.ShouldAlmostEqual, -1, 1e-9)
+		// The ends of the wall are where it leaves the 1500 mm range.
+		test.That(t, segment.LengthMM, test.ShouldAlmostEqual, 2*math.Sqrt(1500*1500-1000*1000), 50)
+		test.That(t, segment.Points, test.ShouldEqual, len(wall))
+
+		m := segment.Map()
+		test.That(t, m["distance_mm"], test.ShouldAlmostEqual, 1000, 1e-6)
+		test.That(t, m["points"], test.ShouldEqual, float64(len(wall)))
+	})
+
+	t.Run("short and sparse returns are not segments", func(t *testing.T) {
+		clutter := []nodes.Node{{Angle: 10, Distance: 1000}, {Angle: 11, Distance: 1000}, {Angle: 12, Distance: 1000}}
+		test.That(t, Extract(clutter, Config{}).Segments, test.ShouldBeEmpty)
+		test.That(t, Extract(nil, Config{}).Segments, test.ShouldBeEmpty)
+	})
+}
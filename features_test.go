@@ -0,0 +1,56 @@
+package rplidar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/features"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestGetFeatures(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getFeaturesCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "features are not configured")
+
+	conf := &Config{Features: &features.Config{CornerMinAngleDeg: 120}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid features")
+
+	conf.Features.CornerMinAngleDeg = 0
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	rp.setupFeatures(conf)
+
+	_, err = rp.DoCommand(ctx, map[string]interface{}{getFeaturesCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "no revolution has been captured yet")
+
+	// Two walls meeting at a corner 1 m along the +y axis and 1 m along the -x axis, which node angle 0 points to.
+	var scanned []nodes.Node
+	for angle := 1.; angle < 90; angle++ {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: math.Min(1000/cos, 1000/sin)})
+	}
+	rp.processRevolution(scanned, time.Now(), 0)
+
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getFeaturesCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(resp["segments"].([]interface{})), test.ShouldEqual, 2)
+	corners := resp["corners"].([]interface{})
+	test.That(t, len(corners), test.ShouldEqual, 1)
+	corner := corners[0].(map[string]interface{})
+	point := corner["point_mm"].([]interface{})
+	test.That(t, point[0], test.ShouldAlmostEqual, -1000, 1)
+	test.That(t, point[1], test.ShouldAlmostEqual, 1000, 1)
+	test.That(t, corner["segments"], test.ShouldResemble, []interface{}{0., 1.})
+}
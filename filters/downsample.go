@@ -0,0 +1,88 @@
+package filters
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+// DownsampleConfig describes how to reduce the number of points sent to bandwidth-constrained clients. Each stage
+// that is set is applied in the order angular bins, grid cells, then max points.
+type DownsampleConfig struct {
+	// AngularBinDeg keeps the closest point in each angular bin of this width, in degrees.
+	AngularBinDeg float64 `json:"angular_bin_deg,omitempty"`
+	// GridCellMM keeps the closest point in each square grid cell of this size, in millimeters.
+	GridCellMM float64 `json:"grid_cell_mm,omitempty"`
+	// MaxPoints caps the number of points, keeping an evenly spaced subset.
+	MaxPoints int `json:"max_points,omitempty"`
+}
+
+// Validate checks that the downsampling attributes are valid.
+func (conf *DownsampleConfig) Validate() error {
+	if conf.AngularBinDeg < 0 || conf.AngularBinDeg > 360 {
+		return errors.New("angular_bin_deg must be between 0 and 360")
+	}
+	if conf.GridCellMM < 0 {
+		return errors.New("grid_cell_mm must be positive")
+	}
+	if conf.MaxPoints < 0 {
+		return errors.New("max_points must be positive")
+	}
+	return nil
+}
+
+// DownsampleFilter reduces the number of points in a revolution. It keeps no state between revolutions.
+type DownsampleFilter struct {
+	conf DownsampleConfig
+}
+
+// NewDownsampleFilter creates a DownsampleFilter from the given config.
+func NewDownsampleFilter(conf DownsampleConfig) *DownsampleFilter {
+	return &DownsampleFilter{conf: conf}
+}
+
+// Filter returns the downsampled revolution, still ordered by ascending angle.
+func (f *DownsampleFilter) Filter(revolution []nodes.Node) []nodes.Node {
+	if f.conf.AngularBinDeg > 0 {
+		revolution = keepClosest(revolution, func(node nodes.Node) [2]int64 {
+			return [2]int64{int64(node.Angle / f.conf.AngularBinDeg), 0}
+		})
+	}
+	if f.conf.GridCellMM > 0 {
+		revolution = keepClosest(revolution, func(node nodes.Node) [2]int64 {
+			pt := node.Point()
+			return [2]int64{int64(math.Floor(pt.X / f.conf.GridCellMM)), int64(math.Floor(pt.Y / f.conf.GridCellMM))}
+		})
+	}
+	if f.conf.MaxPoints > 0 && len(revolution) > f.conf.MaxPoints {
+		stride := float64(len(revolution)) / float64(f.conf.MaxPoints)
+		sampled := make([]nodes.Node, 0, f.conf.MaxPoints)
+		for i := 0; i < f.conf.MaxPoints; i++ {
+			sampled = append(sampled, revolution[int(float64(i)*stride)])
+		}
+		revolution = sampled
+	}
+	return revolution
+}
+
+// keepClosest keeps the closest node for each key, preserving the order of the kept nodes. Keeping the closest
+// return rather than an average makes sure obstacles are never pushed further away by downsampling.
+func keepClosest(revolution []nodes.Node, key func(nodes.Node) [2]int64) []nodes.Node {
+	closest := make(map[[2]int64]int, len(revolution))
+	for i, node := range revolution {
+		k := key(node)
+		if j, ok := closest[k]; !ok || node.Distance < revolution[j].Distance {
+			closest[k] = i
+		}
+	}
+
+	kept := make([]nodes.Node, 0, len(closest))
+	for i, node := range revolution {
+		if closest[key(node)] == i {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,59 @@
+package filters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestDownsampleConfigValidate(t *testing.T) {
+	t.Run("empty config is valid", func(t *testing.T) {
+		conf := DownsampleConfig{}
+		test.That(t, conf.Validate(), test.ShouldBeNil)
+	})
+
+	t.Run("negative grid cell", func(t *testing.T) {
+		conf := DownsampleConfig{GridCellMM: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "grid_cell_mm must be positive")
+	})
+
+	t.Run("negative max points", func(t *testing.T) {
+		conf := DownsampleConfig{MaxPoints: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "max_points must be positive")
+	})
+}
+
+func TestDownsampleFilter(t *testing.T) {
+	revolution := []nodes.Node{
+		{Angle: 0.1, Distance: 1000},
+		{Angle: 0.5, Distance: 900},
+		{Angle: 1.2, Distance: 2000},
+		{Angle: 1.8, Distance: 1990},
+		{Angle: 90, Distance: 3000},
+	}
+
+	t.Run("empty config keeps every point", func(t *testing.T) {
+		test.That(t, NewDownsampleFilter(DownsampleConfig{}).Filter(revolution), test.ShouldResemble, revolution)
+	})
+
+	t.Run("angular bins keep the closest point", func(t *testing.T) {
+		filtered := NewDownsampleFilter(DownsampleConfig{AngularBinDeg: 1}).Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{revolution[1], revolution[3], revolution[4]})
+	})
+
+	t.Run("grid cells keep the closest point", func(t *testing.T) {
+		filtered := NewDownsampleFilter(DownsampleConfig{GridCellMM: 500}).Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{revolution[1], revolution[3], revolution[4]})
+	})
+
+	t.Run("max points keeps an evenly spaced subset", func(t *testing.T) {
+		filtered := NewDownsampleFilter(DownsampleConfig{MaxPoints: 2}).Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{revolution[0], revolution[2]})
+	})
+}
@@ -0,0 +1,23 @@
+// Package filters implements filters that operate on a single revolution of RPLiDAR measurement nodes.
+// Filters are independent of the SWIG driver so they can be tested and reused on recorded data.
+package filters
+
+import "go.viam.com/rplidar/nodes"
+
+// Filter processes a single revolution of nodes, sorted by ascending angle, and returns the nodes that should be kept.
+// The returned nodes must also be sorted by ascending angle. Filters may keep state between revolutions and are not
+// safe for concurrent use.
+type Filter interface {
+	Filter(revolution []nodes.Node) []nodes.Node
+}
+
+// Chain applies a series of filters in order.
+type Chain []Filter
+
+// Filter runs the revolution through every filter in the chain.
+func (c Chain) Filter(revolution []nodes.Node) []nodes.Node {
+	for _, f := range c {
+		revolution = f.Filter(revolution)
+	}
+	return revolution
+}
@@ -0,0 +1,54 @@
+package filters
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestChain(t *testing.T) {
+	revolution := []nodes.Node{{Angle: 0, Distance: 1000}, {Angle: 1, Distance: 1000}, {Angle: 2, Distance: 1000}}
+	test.That(t, Chain(nil).Filter(revolution), test.ShouldResemble, revolution)
+
+	dropFirst := filterFunc(func(revolution []nodes.Node) []nodes.Node { return revolution[1:] })
+	test.That(t, Chain{dropFirst, dropFirst}.Filter(revolution), test.ShouldResemble, revolution[2:])
+}
+
+type filterFunc func([]nodes.Node) []nodes.Node
+
+func (f filterFunc) Filter(revolution []nodes.Node) []nodes.Node {
+	return f(revolution)
+}
+
+// benchmarkRevolution is a revolution of 8192 nodes, as produced by an S1 in boost mode.
+func benchmarkRevolution() []nodes.Node {
+	revolution := make([]nodes.Node, 8192)
+	for i := range revolution {
+		angle := 360 * float64(i) / float64(len(revolution))
+		revolution[i] = nodes.Node{Angle: angle, Distance: 2000 + 500*math.Sin(3*angle*math.Pi/180), Quality: 47}
+	}
+	return revolution
+}
+
+func BenchmarkFilters(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		filter Filter
+	}{
+		{"shadow", NewShadowFilter(ShadowConfig{})},
+		{"outlier", NewOutlierFilter(OutlierConfig{})},
+		{"median", NewMedianFilter(MedianConfig{})},
+		{"downsample", NewDownsampleFilter(DownsampleConfig{GridCellMM: 50})},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			revolution := benchmarkRevolution()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bm.filter.Filter(revolution)
+			}
+		})
+	}
+}
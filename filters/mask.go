@@ -0,0 +1,95 @@
+package filters
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	// MaskFrameSensor expresses the mask polygon in the lidar's own frame.
+	MaskFrameSensor = "sensor"
+	// MaskFrameBase expresses the mask polygon in the robot's base frame, using the lidar's mounting pose.
+	MaskFrameBase = "base"
+)
+
+// MaskConfig describes how to configure the self-body polygon mask.
+type MaskConfig struct {
+	// Frame is the frame the polygon is expressed in, either "sensor" (the default) or "base".
+	Frame string `json:"frame,omitempty"`
+	// PolygonMM lists the [x, y] vertices of the polygon in millimeters.
+	PolygonMM [][2]float64 `json:"polygon_mm"`
+}
+
+// Validate checks that the mask attributes are valid.
+func (conf *MaskConfig) Validate() error {
+	switch conf.Frame {
+	case "", MaskFrameSensor, MaskFrameBase:
+	default:
+		return errors.Errorf("frame must be %q or %q", MaskFrameSensor, MaskFrameBase)
+	}
+	return geometry.PolygonFromConfig(conf.PolygonMM).Validate()
+}
+
+// MaskFilter drops every point that lands inside a polygon, such as the robot's own chassis, bumpers and mast.
+type MaskFilter struct {
+	polygon geometry.Polygon
+	// toMaskFrame maps a point in the lidar's frame into the frame of the polygon.
+	toMaskFrame geometry.Pose
+
+	mutex  sync.Mutex
+	masked []geometry.Point
+}
+
+// NewMaskFilter creates a MaskFilter from the given config. The mounting pose of the lidar in the base frame is only
+// used when the polygon is expressed in the base frame.
+func NewMaskFilter(conf MaskConfig, mounting geometry.Pose) *MaskFilter {
+	f := &MaskFilter{polygon: geometry.PolygonFromConfig(conf.PolygonMM)}
+	if conf.Frame == MaskFrameBase {
+		f.toMaskFrame = mounting
+	}
+	return f
+}
+
+// Filter returns the revolution without the points inside the polygon.
+func (f *MaskFilter) Filter(revolution []nodes.Node) []nodes.Node {
+	filtered := make([]nodes.Node, 0, len(revolution))
+	var masked []geometry.Point
+	for _, node := range revolution {
+		if pt, inside := f.contains(node); inside {
+			masked = append(masked, pt)
+			continue
+		}
+		filtered = append(filtered, node)
+	}
+
+	f.mutex.Lock()
+	f.masked = masked
+	f.mutex.Unlock()
+
+	return filtered
+}
+
+// Contains reports whether the node lands inside the polygon. Unlike Filter, it does not change the points returned
+// by Masked, so it can mask partial revolutions alongside whole ones.
+func (f *MaskFilter) Contains(node nodes.Node) bool {
+	_, inside := f.contains(node)
+	return inside
+}
+
+// contains returns the node in the frame of the polygon, and whether it lands inside it.
+func (f *MaskFilter) contains(node nodes.Node) (geometry.Point, bool) {
+	pt := f.toMaskFrame.Transform(node.Point())
+	return pt, f.polygon.Contains(pt)
+}
+
+// Masked returns the points removed from the most recent revolution, in the frame of the polygon. It is safe to call
+// concurrently with Filter.
+func (f *MaskFilter) Masked() []geometry.Point {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.masked
+}
@@ -0,0 +1,63 @@
+package filters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestMaskConfigValidate(t *testing.T) {
+	square := [][2]float64{{-100, -100}, {100, -100}, {100, 100}, {-100, 100}}
+
+	t.Run("valid config", func(t *testing.T) {
+		conf := MaskConfig{Frame: MaskFrameBase, PolygonMM: square}
+		test.That(t, conf.Validate(), test.ShouldBeNil)
+	})
+
+	t.Run("unknown frame", func(t *testing.T) {
+		conf := MaskConfig{Frame: "world", PolygonMM: square}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, `frame must be "sensor" or "base"`)
+	})
+
+	t.Run("too few vertices", func(t *testing.T) {
+		conf := MaskConfig{PolygonMM: square[:2]}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "polygon must have at least 3 vertices")
+	})
+}
+
+func TestMaskFilter(t *testing.T) {
+	// A box covering the region behind the lidar, where a node at 0 degrees lands.
+	box := [][2]float64{{-300, -50}, {-100, -50}, {-100, 50}, {-300, 50}}
+	revolution := []nodes.Node{
+		{Angle: 0, Distance: 200},
+		{Angle: 0.5, Distance: 1000},
+		{Angle: 90, Distance: 200},
+	}
+
+	t.Run("sensor frame", func(t *testing.T) {
+		f := NewMaskFilter(MaskConfig{PolygonMM: box}, geometry.Pose{X: 1000})
+		filtered := f.Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, revolution[1:])
+
+		masked := f.Masked()
+		test.That(t, len(masked), test.ShouldEqual, 1)
+		test.That(t, masked[0].X, test.ShouldAlmostEqual, -200)
+		test.That(t, masked[0].Y, test.ShouldAlmostEqual, 0)
+	})
+
+	t.Run("base frame", func(t *testing.T) {
+		// Mounted 1 m forward of the base, so the 1 m return at 0 degrees lands on the base origin.
+		f := NewMaskFilter(MaskConfig{Frame: MaskFrameBase, PolygonMM: [][2]float64{{-50, -50}, {50, -50}, {50, 50}, {-50, 50}}},
+			geometry.Pose{X: 1000})
+		filtered := f.Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{revolution[0], revolution[2]})
+		test.That(t, len(f.Masked()), test.ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,145 @@
+package filters
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultMedianBinWidthDeg = 1.0
+	defaultMedianWindowSize  = 5
+)
+
+// MedianConfig describes how to configure the angular binning median filter.
+type MedianConfig struct {
+	// BinWidthDeg is the angular width of each bin in degrees.
+	BinWidthDeg float64 `json:"bin_width_deg,omitempty"`
+	// WindowSize is the number of past revolutions the median is taken over.
+	WindowSize int `json:"window_size,omitempty"`
+	// MaxVarianceMM2 is the variance, in mm^2, above which a bin is rejected. Zero disables rejection.
+	MaxVarianceMM2 float64 `json:"max_variance_mm2,omitempty"`
+}
+
+// Validate checks that the median filter attributes are valid.
+func (conf *MedianConfig) Validate() error {
+	if conf.BinWidthDeg < 0 || conf.BinWidthDeg > 360 {
+		return errors.New("bin_width_deg must be between 0 and 360")
+	}
+	if conf.WindowSize < 0 {
+		return errors.New("window_size must be positive")
+	}
+	if conf.MaxVarianceMM2 < 0 {
+		return errors.New("max_variance_mm2 must be positive")
+	}
+	return nil
+}
+
+// MedianFilter bins nodes by angle and replaces each bin with the median range seen in that bin over the last
+// WindowSize revolutions. Bins whose range varies by more than the configured variance are dropped.
+type MedianFilter struct {
+	binWidth    float64
+	windowSize  int
+	maxVariance float64
+
+	// history holds a ring buffer of the last windowSize ranges per bin. NaN marks a revolution without a return.
+	history [][]float64
+	next    int
+}
+
+// NewMedianFilter creates a MedianFilter from the given config, applying defaults for unset attributes.
+func NewMedianFilter(conf MedianConfig) *MedianFilter {
+	if conf.BinWidthDeg == 0 {
+		conf.BinWidthDeg = defaultMedianBinWidthDeg
+	}
+	if conf.WindowSize == 0 {
+		conf.WindowSize = defaultMedianWindowSize
+	}
+
+	numBins := int(math.Ceil(360 / conf.BinWidthDeg))
+	history := make([][]float64, numBins)
+	for i := range history {
+		history[i] = make([]float64, conf.WindowSize)
+		for j := range history[i] {
+			history[i][j] = math.NaN()
+		}
+	}
+
+	return &MedianFilter{
+		binWidth:    conf.BinWidthDeg,
+		windowSize:  conf.WindowSize,
+		maxVariance: conf.MaxVarianceMM2,
+		history:     history,
+	}
+}
+
+// Filter adds the revolution to the history and returns one node per bin that has a return in this revolution.
+func (f *MedianFilter) Filter(revolution []nodes.Node) []nodes.Node {
+	numBins := len(f.history)
+	current := make([][]float64, numBins)
+	quality := make([]uint8, numBins)
+	for _, node := range revolution {
+		bin := int(node.Angle/f.binWidth) % numBins
+		current[bin] = append(current[bin], node.Distance)
+		if node.Quality > quality[bin] {
+			quality[bin] = node.Quality
+		}
+	}
+
+	filtered := make([]nodes.Node, 0, numBins)
+	window := make([]float64, 0, f.windowSize)
+	for bin := 0; bin < numBins; bin++ {
+		f.history[bin][f.next] = math.NaN()
+		if len(current[bin]) == 0 {
+			continue
+		}
+		f.history[bin][f.next] = median(current[bin])
+
+		window = window[:0]
+		for _, distance := range f.history[bin] {
+			if !math.IsNaN(distance) {
+				window = append(window, distance)
+			}
+		}
+		if f.maxVariance > 0 && variance(window) > f.maxVariance {
+			continue
+		}
+
+		filtered = append(filtered, nodes.Node{
+			Angle:    (float64(bin) + 0.5) * f.binWidth,
+			Distance: median(window),
+			Quality:  quality[bin],
+		})
+	}
+	f.next = (f.next + 1) % f.windowSize
+
+	return filtered
+}
+
+// median returns the median of values. The slice is sorted in place.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// variance returns the population variance of values.
+func variance(values []float64) float64 {
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,79 @@
+package filters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestMedianConfigValidate(t *testing.T) {
+	t.Run("empty config is valid", func(t *testing.T) {
+		conf := MedianConfig{}
+		test.That(t, conf.Validate(), test.ShouldBeNil)
+	})
+
+	t.Run("negative bin width", func(t *testing.T) {
+		conf := MedianConfig{BinWidthDeg: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "bin_width_deg must be between 0 and 360")
+	})
+
+	t.Run("negative window size", func(t *testing.T) {
+		conf := MedianConfig{WindowSize: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "window_size must be positive")
+	})
+
+	t.Run("negative variance", func(t *testing.T) {
+		conf := MedianConfig{MaxVarianceMM2: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "max_variance_mm2 must be positive")
+	})
+}
+
+func TestMedianFilter(t *testing.T) {
+	t.Run("bins nodes and takes the median within a revolution", func(t *testing.T) {
+		f := NewMedianFilter(MedianConfig{BinWidthDeg: 10, WindowSize: 3})
+		filtered := f.Filter([]nodes.Node{
+			{Angle: 1, Distance: 100, Quality: 10},
+			{Angle: 2, Distance: 300, Quality: 30},
+			{Angle: 3, Distance: 200, Quality: 20},
+			{Angle: 45, Distance: 500},
+		})
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{
+			{Angle: 5, Distance: 200, Quality: 30},
+			{Angle: 45, Distance: 500},
+		})
+	})
+
+	t.Run("takes the temporal median across revolutions", func(t *testing.T) {
+		f := NewMedianFilter(MedianConfig{BinWidthDeg: 10, WindowSize: 3})
+		f.Filter([]nodes.Node{{Angle: 1, Distance: 100}})
+		f.Filter([]nodes.Node{{Angle: 1, Distance: 5000}})
+		filtered := f.Filter([]nodes.Node{{Angle: 1, Distance: 110}})
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{{Angle: 5, Distance: 110}})
+
+		// The first revolution has now left the window.
+		filtered = f.Filter([]nodes.Node{{Angle: 1, Distance: 6000}})
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{{Angle: 5, Distance: 5000}})
+	})
+
+	t.Run("drops bins without a return in the current revolution", func(t *testing.T) {
+		f := NewMedianFilter(MedianConfig{BinWidthDeg: 10, WindowSize: 3})
+		f.Filter([]nodes.Node{{Angle: 1, Distance: 100}})
+		filtered := f.Filter([]nodes.Node{})
+		test.That(t, filtered, test.ShouldBeEmpty)
+	})
+
+	t.Run("rejects bins with high variance", func(t *testing.T) {
+		f := NewMedianFilter(MedianConfig{BinWidthDeg: 10, WindowSize: 3, MaxVarianceMM2: 100})
+		f.Filter([]nodes.Node{{Angle: 1, Distance: 100}, {Angle: 11, Distance: 100}})
+		filtered := f.Filter([]nodes.Node{{Angle: 1, Distance: 105}, {Angle: 11, Distance: 1000}})
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{{Angle: 5, Distance: 102.5}})
+	})
+}
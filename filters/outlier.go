@@ -0,0 +1,118 @@
+package filters
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultOutlierRadiusMM     = 100.0
+	defaultOutlierMinNeighbors = 2
+)
+
+// OutlierConfig describes how to configure the radius outlier filter.
+type OutlierConfig struct {
+	// Enabled turns the filter on. It defaults to true when the outlier_filter attribute is present.
+	Enabled *bool `json:"enabled,omitempty"`
+	// RadiusMM is the distance, in millimeters, within which other points count as neighbours.
+	RadiusMM float64 `json:"radius_mm,omitempty"`
+	// MinNeighbors is the number of neighbours a point needs to be kept.
+	MinNeighbors int `json:"min_neighbors,omitempty"`
+}
+
+// Validate checks that the outlier filter attributes are valid.
+func (conf *OutlierConfig) Validate() error {
+	if conf.RadiusMM < 0 {
+		return errors.New("radius_mm must be positive")
+	}
+	if conf.MinNeighbors < 0 {
+		return errors.New("min_neighbors must be positive")
+	}
+	return nil
+}
+
+// IsEnabled reports whether the filter should run.
+func (conf *OutlierConfig) IsEnabled() bool {
+	return conf.Enabled == nil || *conf.Enabled
+}
+
+// OutlierFilter removes isolated returns, such as those caused by dust and rain, by dropping every point with fewer
+// than MinNeighbors other points within RadiusMM of it.
+type OutlierFilter struct {
+	radius       float64
+	minNeighbors int
+}
+
+// NewOutlierFilter creates an OutlierFilter from the given config, applying defaults for unset attributes.
+func NewOutlierFilter(conf OutlierConfig) *OutlierFilter {
+	if conf.RadiusMM == 0 {
+		conf.RadiusMM = defaultOutlierRadiusMM
+	}
+	if conf.MinNeighbors == 0 {
+		conf.MinNeighbors = defaultOutlierMinNeighbors
+	}
+	return &OutlierFilter{radius: conf.RadiusMM, minNeighbors: conf.MinNeighbors}
+}
+
+// Filter returns the revolution without its isolated points. Since the revolution is ordered by angle, only the
+// nodes whose angle is close enough to possibly lie within the radius are compared, walking outwards from each node.
+func (f *OutlierFilter) Filter(revolution []nodes.Node) []nodes.Node {
+	n := len(revolution)
+	points := make([]geometry.Point, n)
+	for i, node := range revolution {
+		points[i] = node.Point()
+	}
+
+	filtered := make([]nodes.Node, 0, n)
+	for i, node := range revolution {
+		if f.countNeighbors(revolution, points, i) >= f.minNeighbors {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// countNeighbors counts the points within the radius of point i, stopping once enough have been found.
+func (f *OutlierFilter) countNeighbors(revolution []nodes.Node, points []geometry.Point, i int) int {
+	n := len(revolution)
+
+	// Any point within the radius is at most this many degrees away from point i.
+	maxAngle := 180.0
+	if revolution[i].Distance > f.radius {
+		maxAngle = math.Asin(f.radius/revolution[i].Distance) * 180 / math.Pi
+	}
+
+	count := 0
+	// remaining bounds the walk so that no node is visited twice once both directions wrap around.
+	remaining := n - 1
+	for _, step := range []int{1, -1} {
+		for offset := 1; remaining > 0; offset++ {
+			// Neighbours wrap around the end of the revolution.
+			j := ((i+step*offset)%n + n) % n
+			if angularDistance(revolution[i].Angle, revolution[j].Angle) > maxAngle {
+				break
+			}
+			remaining--
+			if math.Hypot(points[i].X-points[j].X, points[i].Y-points[j].Y) <= f.radius {
+				count++
+				if count >= f.minNeighbors {
+					return count
+				}
+			}
+		}
+	}
+	return count
+}
+
+// angularDistance returns the absolute difference between two angles in degrees, in the range [0, 180].
+func angularDistance(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
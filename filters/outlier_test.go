@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestOutlierConfigValidate(t *testing.T) {
+	t.Run("empty config is valid and enabled", func(t *testing.T) {
+		conf := OutlierConfig{}
+		test.That(t, conf.Validate(), test.ShouldBeNil)
+		test.That(t, conf.IsEnabled(), test.ShouldBeTrue)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		enabled := false
+		conf := OutlierConfig{Enabled: &enabled}
+		test.That(t, conf.IsEnabled(), test.ShouldBeFalse)
+	})
+
+	t.Run("negative radius", func(t *testing.T) {
+		conf := OutlierConfig{RadiusMM: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "radius_mm must be positive")
+	})
+
+	t.Run("negative min neighbors", func(t *testing.T) {
+		conf := OutlierConfig{MinNeighbors: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "min_neighbors must be positive")
+	})
+}
+
+func TestOutlierFilter(t *testing.T) {
+	t.Run("removes isolated points and keeps dense surfaces", func(t *testing.T) {
+		var revolution []nodes.Node
+		for i := 0; i < 10; i++ {
+			revolution = append(revolution, nodes.Node{Angle: float64(i) * 0.5, Distance: 2000})
+		}
+		dust := nodes.Node{Angle: 90, Distance: 500}
+		revolution = append(revolution, dust)
+		for i := 0; i < 10; i++ {
+			revolution = append(revolution, nodes.Node{Angle: 355 + float64(i)*0.5, Distance: 2000})
+		}
+
+		filtered := NewOutlierFilter(OutlierConfig{RadiusMM: 50, MinNeighbors: 2}).Filter(revolution)
+		expected := append([]nodes.Node{}, revolution[:10]...)
+		expected = append(expected, revolution[11:]...)
+		test.That(t, filtered, test.ShouldResemble, expected)
+	})
+
+	t.Run("finds neighbours across the start of the revolution", func(t *testing.T) {
+		revolution := []nodes.Node{{Angle: 0.1, Distance: 2000}, {Angle: 180, Distance: 2000}, {Angle: 359.9, Distance: 2000}}
+		filtered := NewOutlierFilter(OutlierConfig{RadiusMM: 50, MinNeighbors: 1}).Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, []nodes.Node{revolution[0], revolution[2]})
+	})
+
+	t.Run("does not count a neighbour twice", func(t *testing.T) {
+		revolution := []nodes.Node{{Angle: 0, Distance: 10}, {Angle: 180, Distance: 10}}
+		filtered := NewOutlierFilter(OutlierConfig{RadiusMM: 50, MinNeighbors: 2}).Filter(revolution)
+		test.That(t, filtered, test.ShouldBeEmpty)
+	})
+}
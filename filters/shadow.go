@@ -0,0 +1,123 @@
+package filters
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultShadowMinAngleDeg = 10.0
+	defaultShadowMaxAngleDeg = 170.0
+	defaultShadowWindow      = 1
+)
+
+// ShadowConfig describes how to configure the mixed pixel / veiling edge filter.
+type ShadowConfig struct {
+	// MinAngleDeg and MaxAngleDeg bound the angle, in degrees, between the laser ray and the line joining a point to
+	// its neighbour. Pairs outside these bounds lie almost along the ray and are treated as a mixed pixel.
+	MinAngleDeg float64 `json:"min_angle_deg,omitempty"`
+	MaxAngleDeg float64 `json:"max_angle_deg,omitempty"`
+	// Window is the number of neighbours on each side of a point that are checked.
+	Window int `json:"window,omitempty"`
+	// MinRangeJumpMM is the difference in range, in millimeters, a pair must exceed before it is checked.
+	MinRangeJumpMM float64 `json:"min_range_jump_mm,omitempty"`
+}
+
+// Validate checks that the shadow filter attributes are valid.
+func (conf *ShadowConfig) Validate() error {
+	if conf.MinAngleDeg < 0 || conf.MinAngleDeg > 180 {
+		return errors.New("min_angle_deg must be between 0 and 180")
+	}
+	if conf.MaxAngleDeg < 0 || conf.MaxAngleDeg > 180 {
+		return errors.New("max_angle_deg must be between 0 and 180")
+	}
+	if conf.MaxAngleDeg != 0 && conf.MinAngleDeg >= conf.MaxAngleDeg {
+		return errors.New("min_angle_deg must be less than max_angle_deg")
+	}
+	if conf.Window < 0 {
+		return errors.New("window must be positive")
+	}
+	if conf.MinRangeJumpMM < 0 {
+		return errors.New("min_range_jump_mm must be positive")
+	}
+	return nil
+}
+
+// ShadowFilter removes the phantom points a triangulation lidar produces between a foreground edge and the
+// background, using the classic shadow filter approach: when the line joining a point to one of its neighbours is
+// close to parallel with the laser ray, the farther of the two points is dropped.
+type ShadowFilter struct {
+	minAngle       float64
+	maxAngle       float64
+	window         int
+	minRangeJumpMM float64
+}
+
+// NewShadowFilter creates a ShadowFilter from the given config, applying defaults for unset attributes.
+func NewShadowFilter(conf ShadowConfig) *ShadowFilter {
+	if conf.MinAngleDeg == 0 {
+		conf.MinAngleDeg = defaultShadowMinAngleDeg
+	}
+	if conf.MaxAngleDeg == 0 {
+		conf.MaxAngleDeg = defaultShadowMaxAngleDeg
+	}
+	if conf.Window == 0 {
+		conf.Window = defaultShadowWindow
+	}
+
+	return &ShadowFilter{
+		minAngle:       conf.MinAngleDeg * math.Pi / 180,
+		maxAngle:       conf.MaxAngleDeg * math.Pi / 180,
+		window:         conf.Window,
+		minRangeJumpMM: conf.MinRangeJumpMM,
+	}
+}
+
+// Filter returns the revolution without the points identified as mixed pixels.
+func (f *ShadowFilter) Filter(revolution []nodes.Node) []nodes.Node {
+	n := len(revolution)
+	if n < 2 {
+		return revolution
+	}
+	window := f.window
+	if window > n/2 {
+		window = n / 2
+	}
+
+	remove := make([]bool, n)
+	for i := 0; i < n; i++ {
+		for offset := 1; offset <= window; offset++ {
+			// Neighbours wrap around the end of the revolution.
+			j := (i + offset) % n
+			r1, r2 := revolution[i].Distance, revolution[j].Distance
+			if math.Abs(r1-r2) <= f.minRangeJumpMM {
+				continue
+			}
+
+			included := math.Abs(revolution[j].Angle-revolution[i].Angle) * math.Pi / 180
+			if included > math.Pi {
+				included = 2*math.Pi - included
+			}
+
+			angle := math.Abs(math.Atan2(r2*math.Sin(included), r1-r2*math.Cos(included)))
+			if angle < f.minAngle || angle > f.maxAngle {
+				if r1 > r2 {
+					remove[i] = true
+				} else {
+					remove[j] = true
+				}
+			}
+		}
+	}
+
+	filtered := make([]nodes.Node, 0, n)
+	for i, node := range revolution {
+		if !remove[i] {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,73 @@
+package filters
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestShadowConfigValidate(t *testing.T) {
+	t.Run("empty config is valid", func(t *testing.T) {
+		conf := ShadowConfig{}
+		test.That(t, conf.Validate(), test.ShouldBeNil)
+	})
+
+	t.Run("angle out of range", func(t *testing.T) {
+		conf := ShadowConfig{MaxAngleDeg: 200}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "max_angle_deg must be between 0 and 180")
+	})
+
+	t.Run("min angle above max angle", func(t *testing.T) {
+		conf := ShadowConfig{MinAngleDeg: 20, MaxAngleDeg: 10}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "min_angle_deg must be less than max_angle_deg")
+	})
+
+	t.Run("negative window", func(t *testing.T) {
+		conf := ShadowConfig{Window: -1}
+		err := conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "window must be positive")
+	})
+}
+
+// edgeRevolution returns a foreground wall at 1 m, a mixed pixel at 11 degrees and a background wall at 3 m
+// covering the rest of the revolution.
+func edgeRevolution() []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 0; angle <= 10; angle++ {
+		revolution = append(revolution, nodes.Node{Angle: float64(angle), Distance: 1000})
+	}
+	revolution = append(revolution, nodes.Node{Angle: 11, Distance: 2000})
+	for angle := 12; angle < 360; angle++ {
+		revolution = append(revolution, nodes.Node{Angle: float64(angle), Distance: 3000})
+	}
+	return revolution
+}
+
+func TestShadowFilter(t *testing.T) {
+	t.Run("removes the mixed pixel and the shadowed points behind both edges", func(t *testing.T) {
+		revolution := edgeRevolution()
+		filtered := NewShadowFilter(ShadowConfig{}).Filter(revolution)
+
+		expected := append([]nodes.Node{}, revolution[:11]...)
+		expected = append(expected, revolution[13:len(revolution)-1]...)
+		test.That(t, filtered, test.ShouldResemble, expected)
+	})
+
+	t.Run("keeps pairs below the range jump", func(t *testing.T) {
+		revolution := edgeRevolution()
+		filtered := NewShadowFilter(ShadowConfig{MinRangeJumpMM: 5000}).Filter(revolution)
+		test.That(t, filtered, test.ShouldResemble, revolution)
+	})
+
+	t.Run("handles tiny revolutions", func(t *testing.T) {
+		revolution := []nodes.Node{{Angle: 1, Distance: 1000}}
+		test.That(t, NewShadowFilter(ShadowConfig{}).Filter(revolution), test.ShouldResemble, revolution)
+	})
+}
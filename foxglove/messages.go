@@ -0,0 +1,143 @@
+package foxglove
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+
+	"go.viam.com/rplidar/laserscan"
+	"go.viam.com/rplidar/publisher"
+)
+
+// The names of the well-known Foxglove message types published by the bridge.
+const (
+	LaserScanSchemaName  = "foxglove.LaserScan"
+	PointCloudSchemaName = "foxglove.PointCloud"
+)
+
+const (
+	// numericTypeFloat32 is the foxglove.NumericType of a 32 bit float.
+	numericTypeFloat32 = 7
+	// pointStride is the size in bytes of a point: x, y, z and intensity as 32 bit floats.
+	pointStride = 16
+)
+
+// The JSON schemas of the well-known Foxglove message types published by the bridge.
+const (
+	timeSchema = `{"type":"object","properties":{"sec":{"type":"integer"},"nsec":{"type":"integer"}}}`
+	poseSchema = `{"type":"object","properties":{` +
+		`"position":{"type":"object","properties":{"x":{"type":"number"},"y":{"type":"number"},"z":{"type":"number"}}},` +
+		`"orientation":{"type":"object","properties":{"x":{"type":"number"},"y":{"type":"number"},"z":{"type":"number"},` +
+		`"w":{"type":"number"}}}}}`
+	LaserScanSchema = `{"title":"foxglove.LaserScan","type":"object","properties":{` +
+		`"timestamp":` + timeSchema + `,"frame_id":{"type":"string"},"pose":` + poseSchema + `,` +
+		`"start_angle":{"type":"number"},"end_angle":{"type":"number"},` +
+		`"ranges":{"type":"array","items":{"type":"number"}},"intensities":{"type":"array","items":{"type":"number"}}}}`
+	PointCloudSchema = `{"title":"foxglove.PointCloud","type":"object","properties":{` +
+		`"timestamp":` + timeSchema + `,"frame_id":{"type":"string"},"pose":` + poseSchema + `,` +
+		`"point_stride":{"type":"integer"},"fields":{"type":"array","items":{"type":"object","properties":{` +
+		`"name":{"type":"string"},"offset":{"type":"integer"},"type":{"type":"integer"}}}},` +
+		`"data":{"type":"string","contentEncoding":"base64"}}}`
+)
+
+type timestamp struct {
+	Sec  int64 `json:"sec"`
+	Nsec int64 `json:"nsec"`
+}
+
+func newTimestamp(t time.Time) timestamp {
+	return timestamp{Sec: t.Unix(), Nsec: int64(t.Nanosecond())}
+}
+
+type vector3 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+type quaternion struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+	W float64 `json:"w"`
+}
+
+type pose struct {
+	Position    vector3    `json:"position"`
+	Orientation quaternion `json:"orientation"`
+}
+
+// identityPose places a message at the origin of its frame.
+var identityPose = pose{Orientation: quaternion{W: 1}}
+
+type laserScanMessage struct {
+	Timestamp   timestamp `json:"timestamp"`
+	FrameID     string    `json:"frame_id"`
+	Pose        pose      `json:"pose"`
+	StartAngle  float64   `json:"start_angle"`
+	EndAngle    float64   `json:"end_angle"`
+	Ranges      []float64 `json:"ranges"`
+	Intensities []float64 `json:"intensities"`
+}
+
+type packedElementField struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"`
+	Type   int    `json:"type"`
+}
+
+type pointCloudMessage struct {
+	Timestamp   timestamp            `json:"timestamp"`
+	FrameID     string               `json:"frame_id"`
+	Pose        pose                 `json:"pose"`
+	PointStride int                  `json:"point_stride"`
+	Fields      []packedElementField `json:"fields"`
+	// Data is encoded as base64 by encoding/json, as the schema expects.
+	Data []byte `json:"data"`
+}
+
+// EncodeLaserScan encodes a revolution as a foxglove.LaserScan.
+func EncodeLaserScan(rev publisher.Revolution, frameID string, opts laserscan.Options) ([]byte, error) {
+	opts.Timestamp = rev.Timestamp
+	opts.ScanTime = rev.ScanTime
+	scan := laserscan.FromNodes(rev.Nodes, opts)
+
+	return json.Marshal(laserScanMessage{
+		Timestamp:   newTimestamp(rev.Timestamp),
+		FrameID:     frameID,
+		Pose:        identityPose,
+		StartAngle:  scan.AngleMin,
+		EndAngle:    scan.AngleMax,
+		Ranges:      scan.FiniteRanges(),
+		Intensities: scan.Intensities,
+	})
+}
+
+// EncodePointCloud encodes a revolution as a foxglove.PointCloud in meters, in the same frame as the camera's
+// point clouds.
+func EncodePointCloud(rev publisher.Revolution, frameID string) ([]byte, error) {
+	data := make([]byte, len(rev.Nodes)*pointStride)
+	for i, node := range rev.Nodes {
+		pt := node.Point()
+		offset := i * pointStride
+		binary.LittleEndian.PutUint32(data[offset:], math.Float32bits(float32(pt.X/1000)))
+		binary.LittleEndian.PutUint32(data[offset+4:], math.Float32bits(float32(pt.Y/1000)))
+		binary.LittleEndian.PutUint32(data[offset+8:], math.Float32bits(0))
+		binary.LittleEndian.PutUint32(data[offset+12:], math.Float32bits(float32(node.Quality)))
+	}
+
+	return json.Marshal(pointCloudMessage{
+		Timestamp:   newTimestamp(rev.Timestamp),
+		FrameID:     frameID,
+		Pose:        identityPose,
+		PointStride: pointStride,
+		Fields: []packedElementField{
+			{Name: "x", Offset: 0, Type: numericTypeFloat32},
+			{Name: "y", Offset: 4, Type: numericTypeFloat32},
+			{Name: "z", Offset: 8, Type: numericTypeFloat32},
+			{Name: "intensity", Offset: 12, Type: numericTypeFloat32},
+		},
+		Data: data,
+	})
+}
@@ -0,0 +1,351 @@
+// Package foxglove publishes RPLiDAR revolutions over the Foxglove WebSocket protocol, so that they can be viewed live
+// in Foxglove Studio on the local network.
+package foxglove
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"nhooyr.io/websocket"
+
+	"go.viam.com/rplidar/laserscan"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	subprotocol = "foxglove.websocket.v1"
+	// opMessageData is the opcode of a binary message carrying the data of a subscribed channel.
+	opMessageData = 0x01
+
+	laserScanChannelID  = 1
+	pointCloudChannelID = 2
+
+	defaultHost = "127.0.0.1"
+	// clientQueueSize is small since a live viewer only cares about the most recent revolutions.
+	clientQueueSize = 2
+	writeTimeout    = 5 * time.Second
+)
+
+const (
+	// DefaultFrameID is the frame messages are published in when none is configured.
+	DefaultFrameID = "rplidar"
+	// DefaultTopicPrefix is prepended to the /scan and /points topics when no prefix is configured.
+	DefaultTopicPrefix = "/rplidar"
+)
+
+// defaultAllowedOrigins are the hosts of the Foxglove web app.
+var defaultAllowedOrigins = []string{"app.foxglove.dev", "studio.foxglove.dev"}
+
+// Config describes how to configure the Foxglove WebSocket bridge.
+type Config struct {
+	Port int    `json:"port"`
+	Host string `json:"host,omitempty"`
+	// AllowedOrigins are host patterns, such as "*.example.com", of the web pages allowed to connect. Clients that
+	// send no origin, such as the Foxglove desktop app, are always allowed.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// FrameID is the frame the messages are published in.
+	FrameID string `json:"frame_id,omitempty"`
+	// TopicPrefix is prepended to the /scan and /points topics.
+	TopicPrefix string `json:"topic_prefix,omitempty"`
+	// AngleIncrementDeg is the width of the bins of the LaserScan messages, in degrees.
+	AngleIncrementDeg float64 `json:"angle_increment_deg,omitempty"`
+}
+
+// Validate checks that the bridge attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.Port < 1 || conf.Port > 65535 {
+		return errors.New("port must be between 1 and 65535")
+	}
+	if conf.AngleIncrementDeg < 0 {
+		return errors.New("angle_increment_deg must be positive")
+	}
+	return nil
+}
+
+// Address returns the host and port the bridge listens on.
+func (conf *Config) Address() string {
+	host := conf.Host
+	if host == "" {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(conf.Port))
+}
+
+type channel struct {
+	ID         uint32 `json:"id"`
+	Topic      string `json:"topic"`
+	Encoding   string `json:"encoding"`
+	SchemaName string `json:"schemaName"`
+	Schema     string `json:"schema"`
+}
+
+// Server serves the revolutions of a publisher to Foxglove WebSocket clients.
+type Server struct {
+	frameID        string
+	scanOpts       laserscan.Options
+	channels       []channel
+	allowedOrigins []string
+	publisher      *publisher.Publisher
+	logger         logging.Logger
+
+	// clients counts the connected clients. New clients are refused once closing is set, so that clients are never
+	// added while Serve waits for them. closing is under mutex protection.
+	mutex   sync.Mutex
+	closing bool
+	clients sync.WaitGroup
+}
+
+// NewServer creates a Server for the given config. scanOpts supplies the range limits of the lidar for the
+// LaserScan messages.
+func NewServer(conf Config, scanOpts laserscan.Options, pub *publisher.Publisher, logger logging.Logger) *Server {
+	if conf.FrameID == "" {
+		conf.FrameID = DefaultFrameID
+	}
+	if conf.TopicPrefix == "" {
+		conf.TopicPrefix = DefaultTopicPrefix
+	}
+	if conf.AllowedOrigins == nil {
+		conf.AllowedOrigins = defaultAllowedOrigins
+	}
+	scanOpts.AngleIncrementDeg = conf.AngleIncrementDeg
+
+	return &Server{
+		frameID:  conf.FrameID,
+		scanOpts: scanOpts,
+		channels: []channel{
+			{
+				ID:         laserScanChannelID,
+				Topic:      conf.TopicPrefix + "/scan",
+				Encoding:   "json",
+				SchemaName: LaserScanSchemaName,
+				Schema:     LaserScanSchema,
+			},
+			{
+				ID:         pointCloudChannelID,
+				Topic:      conf.TopicPrefix + "/points",
+				Encoding:   "json",
+				SchemaName: PointCloudSchemaName,
+				Schema:     PointCloudSchema,
+			},
+		},
+		allowedOrigins: conf.AllowedOrigins,
+		publisher:      pub,
+		logger:         logger,
+	}
+}
+
+// Serve accepts WebSocket clients on the listener until ctx is done, then waits for every client to disconnect.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: writeTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		//nolint:contextcheck
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			s.logger.Debugf("error shutting down foxglove bridge: %v", err)
+		}
+	}()
+
+	err := httpServer.Serve(listener)
+	s.mutex.Lock()
+	s.closing = true
+	s.mutex.Unlock()
+	s.clients.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// addClient counts a new client, unless the server is shutting down.
+func (s *Server) addClient() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closing {
+		return false
+	}
+	s.clients.Add(1)
+	return true
+}
+
+// ServeHTTP upgrades the request to a Foxglove WebSocket connection and streams revolutions to it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.addClient() {
+		http.Error(w, "the foxglove bridge is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.clients.Done()
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols:   []string{subprotocol},
+		OriginPatterns: s.allowedOrigins,
+	})
+	if err != nil {
+		s.logger.Debugf("could not accept foxglove client: %v", err)
+		return
+	}
+	defer func() {
+		//nolint:errcheck
+		conn.CloseNow()
+	}()
+
+	if err := s.handle(r.Context(), conn); err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.Debugf("foxglove client disconnected: %v", err)
+	}
+}
+
+// handle announces the available channels to a client, then sends every revolution on the channels it subscribes to.
+func (s *Server) handle(ctx context.Context, conn *websocket.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := s.writeJSON(ctx, conn, map[string]interface{}{
+		"op":                 "serverInfo",
+		"name":               "rplidar",
+		"capabilities":       []string{},
+		"supportedEncodings": []string{},
+		"metadata":           map[string]string{},
+		"sessionId":          strconv.FormatInt(time.Now().UnixNano(), 10),
+	}); err != nil {
+		return err
+	}
+	if err := s.writeJSON(ctx, conn, map[string]interface{}{"op": "advertise", "channels": s.channels}); err != nil {
+		return err
+	}
+
+	// Reading stops the connection when the client goes away, which in turn stops the writes below.
+	subs := &subscriptions{byID: map[uint32]uint32{}}
+	go func() {
+		defer cancel()
+		if err := s.readSubscriptions(ctx, conn, subs); err != nil {
+			s.logger.Debugf("stopped reading from foxglove client: %v", err)
+		}
+	}()
+
+	revolutions := s.publisher.Subscribe(clientQueueSize)
+	defer revolutions.Close()
+	for {
+		rev, err := revolutions.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.sendRevolution(ctx, conn, subs, rev); err != nil {
+			return err
+		}
+	}
+}
+
+// subscriptions maps the client's subscription IDs to the channel they subscribe to. This data is under mutex
+// protection.
+type subscriptions struct {
+	mutex sync.Mutex
+	byID  map[uint32]uint32
+}
+
+type clientMessage struct {
+	Op            string `json:"op"`
+	Subscriptions []struct {
+		ID        uint32 `json:"id"`
+		ChannelID uint32 `json:"channelId"`
+	} `json:"subscriptions"`
+	SubscriptionIDs []uint32 `json:"subscriptionIds"`
+}
+
+// readSubscriptions processes subscribe and unsubscribe requests from the client until the connection fails.
+func (s *Server) readSubscriptions(ctx context.Context, conn *websocket.Conn, subs *subscriptions) error {
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if typ != websocket.MessageText {
+			continue
+		}
+
+		var msg clientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			s.logger.Debugf("ignoring invalid foxglove client message: %v", err)
+			continue
+		}
+
+		subs.mutex.Lock()
+		switch msg.Op {
+		case "subscribe":
+			for _, sub := range msg.Subscriptions {
+				subs.byID[sub.ID] = sub.ChannelID
+			}
+		case "unsubscribe":
+			for _, id := range msg.SubscriptionIDs {
+				delete(subs.byID, id)
+			}
+		default:
+		}
+		subs.mutex.Unlock()
+	}
+}
+
+// sendRevolution encodes the revolution once per subscribed channel and sends it on every matching subscription.
+func (s *Server) sendRevolution(ctx context.Context, conn *websocket.Conn, subs *subscriptions, rev publisher.Revolution) error {
+	subs.mutex.Lock()
+	byID := make(map[uint32]uint32, len(subs.byID))
+	for id, channelID := range subs.byID {
+		byID[id] = channelID
+	}
+	subs.mutex.Unlock()
+
+	payloads := map[uint32][]byte{}
+	for id, channelID := range byID {
+		payload, ok := payloads[channelID]
+		if !ok {
+			var err error
+			switch channelID {
+			case laserScanChannelID:
+				payload, err = EncodeLaserScan(rev, s.frameID, s.scanOpts)
+			case pointCloudChannelID:
+				payload, err = EncodePointCloud(rev, s.frameID)
+			default:
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			payloads[channelID] = payload
+		}
+
+		frame := make([]byte, 13+len(payload))
+		frame[0] = opMessageData
+		binary.LittleEndian.PutUint32(frame[1:], id)
+		binary.LittleEndian.PutUint64(frame[5:], uint64(rev.Timestamp.UnixNano()))
+		copy(frame[13:], payload)
+
+		writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+		err := conn.Write(writeCtx, websocket.MessageBinary, frame)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) writeJSON(ctx context.Context, conn *websocket.Conn, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return conn.Write(writeCtx, websocket.MessageText, b)
+}
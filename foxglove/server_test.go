@@ -0,0 +1,146 @@
+package foxglove
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+	"nhooyr.io/websocket"
+
+	"go.viam.com/rplidar/laserscan"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func TestConfigValidate(t *testing.T) {
+	conf := Config{}
+	err := conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "port must be between 1 and 65535")
+
+	conf = Config{Port: 8765, AngleIncrementDeg: -1}
+	err = conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "angle_increment_deg must be positive")
+
+	conf = Config{Port: 8765}
+	test.That(t, conf.Validate(), test.ShouldBeNil)
+	test.That(t, conf.Address(), test.ShouldEqual, "127.0.0.1:8765")
+}
+
+func TestEncodePointCloud(t *testing.T) {
+	rev := publisher.Revolution{Nodes: []nodes.Node{{Angle: 90, Distance: 2000, Quality: 47}}}
+	b, err := EncodePointCloud(rev, "rplidar")
+	test.That(t, err, test.ShouldBeNil)
+
+	var msg pointCloudMessage
+	test.That(t, json.Unmarshal(b, &msg), test.ShouldBeNil)
+	test.That(t, msg.FrameID, test.ShouldEqual, "rplidar")
+	test.That(t, msg.PointStride, test.ShouldEqual, pointStride)
+	test.That(t, len(msg.Data), test.ShouldEqual, pointStride)
+	test.That(t, math.Float32frombits(binary.LittleEndian.Uint32(msg.Data[4:])), test.ShouldAlmostEqual, 2, 1e-6)
+	test.That(t, math.Float32frombits(binary.LittleEndian.Uint32(msg.Data[12:])), test.ShouldEqual, 47)
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+
+	pub := publisher.New()
+	server := NewServer(Config{Port: 1}, laserscan.Options{RangeMaxMM: 12000}, pub, logging.NewTestLogger(t))
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(ctx, listener)
+	}()
+
+	conn, _, err := websocket.Dial(ctx, "ws://"+listener.Addr().String(), &websocket.DialOptions{
+		Subprotocols: []string{subprotocol},
+	})
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.CloseNow()
+	test.That(t, conn.Subprotocol(), test.ShouldEqual, subprotocol)
+
+	readJSON := func() map[string]interface{} {
+		typ, data, err := conn.Read(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, typ, test.ShouldEqual, websocket.MessageText)
+		var msg map[string]interface{}
+		test.That(t, json.Unmarshal(data, &msg), test.ShouldBeNil)
+		return msg
+	}
+	test.That(t, readJSON()["op"], test.ShouldEqual, "serverInfo")
+	advertise := readJSON()
+	test.That(t, advertise["op"], test.ShouldEqual, "advertise")
+	channels := advertise["channels"].([]interface{})
+	test.That(t, len(channels), test.ShouldEqual, 2)
+	test.That(t, channels[0].(map[string]interface{})["topic"], test.ShouldEqual, "/rplidar/scan")
+	test.That(t, channels[0].(map[string]interface{})["schemaName"], test.ShouldEqual, "foxglove.LaserScan")
+
+	err = conn.Write(ctx, websocket.MessageText, []byte(`{"op":"subscribe","subscriptions":[{"id":7,"channelId":1}]}`))
+	test.That(t, err, test.ShouldBeNil)
+
+	// The subscription is processed asynchronously, so keep publishing until a message arrives.
+	frames := make(chan []byte, 1)
+	go func() {
+		_, data, err := conn.Read(ctx)
+		if err == nil {
+			frames <- data
+		}
+	}()
+	capturedAt := time.Unix(100, 0)
+	var frame []byte
+	for frame == nil {
+		pub.Publish(publisher.Revolution{Timestamp: capturedAt, Nodes: []nodes.Node{{Angle: 180, Distance: 1000}}})
+		select {
+		case frame = <-frames:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	test.That(t, frame[0], test.ShouldEqual, opMessageData)
+	test.That(t, binary.LittleEndian.Uint32(frame[1:]), test.ShouldEqual, 7)
+	test.That(t, binary.LittleEndian.Uint64(frame[5:]), test.ShouldEqual, uint64(capturedAt.UnixNano()))
+
+	var scan laserScanMessage
+	test.That(t, json.Unmarshal(frame[13:], &scan), test.ShouldBeNil)
+	test.That(t, scan.Timestamp, test.ShouldResemble, timestamp{Sec: 100})
+	test.That(t, len(scan.Ranges), test.ShouldEqual, 360)
+	test.That(t, scan.Ranges[180], test.ShouldAlmostEqual, 1)
+
+	t.Run("web pages need an allowed origin", func(t *testing.T) {
+		for origin, allowed := range map[string]bool{
+			"https://app.foxglove.dev":           true,
+			"https://evil.example.com":           false,
+			"http://" + listener.Addr().String(): true,
+		} {
+			conn, _, err := websocket.Dial(ctx, "ws://"+listener.Addr().String(), &websocket.DialOptions{
+				Subprotocols: []string{subprotocol},
+				HTTPHeader:   http.Header{"Origin": []string{origin}},
+			})
+			test.That(t, err == nil, test.ShouldEqual, allowed)
+			if err == nil {
+				conn.CloseNow()
+			}
+		}
+	})
+
+	cancel()
+	test.That(t, <-served, test.ShouldBeNil)
+
+	t.Run("clients are refused once the server has shut down", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		test.That(t, recorder.Code, test.ShouldEqual, http.StatusServiceUnavailable)
+	})
+}
@@ -0,0 +1,62 @@
+package gen
+
+// Unlike the rest of this package, this file is not generated by SWIG. It copies a whole array of measurement
+// nodes into Go memory with a single cgo call, instead of one call per node and field through the SWIG accessors.
+
+// #cgo CFLAGS: -I${SRCDIR}/third_party/rplidar_sdk-release-v1.12.0/sdk/sdk/include
+// #include <stddef.h>
+// #include <stdint.h>
+// #include "rptypes.h"
+// #include "rplidar_cmd.h"
+//
+// // measurement_node_hq has the natural, unpacked layout of the MeasurementNodeHq Go struct.
+// typedef struct {
+//     uint16_t angle_z_q14;
+//     uint32_t dist_mm_q2;
+//     uint8_t  quality;
+//     uint8_t  flag;
+// } measurement_node_hq;
+//
+// static void copy_measurement_nodes_hq(uintptr_t src, measurement_node_hq *dst, size_t count) {
+//     const rplidar_response_measurement_node_hq_t *nodes = (const rplidar_response_measurement_node_hq_t *)src;
+//     for (size_t i = 0; i < count; i++) {
+//         dst[i].angle_z_q14 = nodes[i].angle_z_q14;
+//         dst[i].dist_mm_q2 = nodes[i].dist_mm_q2;
+//         dst[i].quality = nodes[i].quality;
+//         dst[i].flag = nodes[i].flag;
+//     }
+// }
+import "C"
+
+import (
+	"unsafe"
+)
+
+// MeasurementNodeHq is a plain Go copy of an rplidar_response_measurement_node_hq_t.
+type MeasurementNodeHq struct {
+	AngleZQ14 uint16
+	DistMMQ2  uint32
+	Quality   uint8
+	Flag      uint8
+}
+
+// The Go and C layouts must match for the copy to be valid. Either array length below is negative, failing the
+// build, if the sizes differ.
+var (
+	_ [unsafe.Sizeof(MeasurementNodeHq{}) - C.sizeof_measurement_node_hq]byte
+	_ [C.sizeof_measurement_node_hq - unsafe.Sizeof(MeasurementNodeHq{})]byte
+)
+
+// CopyMeasurementNodesHq copies the first count nodes of an array created with New_measurementNodeHqArray into dst,
+// growing it if needed, and returns the copied nodes.
+func CopyMeasurementNodesHq(src Rplidar_response_measurement_node_hq_t, count int, dst []MeasurementNodeHq) []MeasurementNodeHq {
+	if count <= 0 {
+		return dst[:0]
+	}
+	if cap(dst) < count {
+		dst = make([]MeasurementNodeHq, count)
+	}
+	dst = dst[:count]
+	C.copy_measurement_nodes_hq(C.uintptr_t(src.Swigcptr()), (*C.measurement_node_hq)(unsafe.Pointer(&dst[0])), C.size_t(count))
+	return dst
+}
@@ -0,0 +1,72 @@
+package gen
+
+import (
+	"encoding/binary"
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"go.viam.com/test"
+)
+
+// packedNodes stands in for an array created with New_measurementNodeHqArray, pointing at Go memory laid out like
+// the packed C struct.
+type packedNodes struct {
+	Rplidar_response_measurement_node_hq_t
+	ptr uintptr
+}
+
+func (n packedNodes) Swigcptr() uintptr {
+	return n.ptr
+}
+
+func packNodes(nodes []MeasurementNodeHq) []byte {
+	const packedSize = 8
+	buf := make([]byte, len(nodes)*packedSize)
+	for i, node := range nodes {
+		b := buf[i*packedSize:]
+		binary.LittleEndian.PutUint16(b, node.AngleZQ14)
+		binary.LittleEndian.PutUint32(b[2:], node.DistMMQ2)
+		b[6] = node.Quality
+		b[7] = node.Flag
+	}
+	return buf
+}
+
+func TestCopyMeasurementNodesHq(t *testing.T) {
+	expected := []MeasurementNodeHq{
+		{AngleZQ14: 0, DistMMQ2: 4000, Quality: 47, Flag: 1},
+		{AngleZQ14: 16384, DistMMQ2: 0, Quality: 0, Flag: 0},
+		{AngleZQ14: 65535, DistMMQ2: 0xFFFFFFFF, Quality: 255, Flag: 2},
+	}
+	buf := packNodes(expected)
+	var pinner runtime.Pinner
+	pinner.Pin(&buf[0])
+	defer pinner.Unpin()
+	src := packedNodes{ptr: uintptr(unsafe.Pointer(&buf[0]))}
+
+	copied := CopyMeasurementNodesHq(src, len(expected), nil)
+	test.That(t, copied, test.ShouldResemble, expected)
+
+	// The destination is reused when it has enough capacity.
+	dst := make([]MeasurementNodeHq, 0, 8)
+	copied = CopyMeasurementNodesHq(src, 2, dst)
+	test.That(t, copied, test.ShouldResemble, expected[:2])
+	test.That(t, &copied[0], test.ShouldEqual, &dst[:1][0])
+
+	test.That(t, CopyMeasurementNodesHq(src, 0, dst), test.ShouldBeEmpty)
+}
+
+func BenchmarkCopyMeasurementNodesHq(b *testing.B) {
+	buf := packNodes(make([]MeasurementNodeHq, 8192))
+	var pinner runtime.Pinner
+	pinner.Pin(&buf[0])
+	defer pinner.Unpin()
+	src := packedNodes{ptr: uintptr(unsafe.Pointer(&buf[0]))}
+
+	dst := make([]MeasurementNodeHq, 8192)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = CopyMeasurementNodesHq(src, 8192, dst)
+	}
+}
@@ -2,6 +2,7 @@
 
 %include <stdint.i>
 %include <carrays.i>
+%include <std_vector.i>
 %array_functions(uint8_t, byteArray);
 
 %{
@@ -18,4 +19,5 @@
 %include "./third_party/rplidar_sdk-release-v1.12.0/sdk/sdk/include/rplidar_driver.h"
 
 %array_functions(rplidar_response_measurement_node_hq_t, measurementNodeHqArray)
+%template(RplidarScanModeVector) std::vector<rp::standalone::rplidar::RplidarScanMode>;
 
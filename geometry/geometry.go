@@ -0,0 +1,79 @@
+// Package geometry provides the planar geometry used to reason about RPLiDAR returns, such as polygons and the
+// mounting pose of the lidar on a robot. All distances are in millimeters.
+package geometry
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Point is a point in the plane of the lidar.
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Polygon is a simple polygon described by its vertices, in order.
+type Polygon []Point
+
+// PolygonFromConfig converts a list of [x, y] pairs into a Polygon.
+func PolygonFromConfig(vertices [][2]float64) Polygon {
+	polygon := make(Polygon, 0, len(vertices))
+	for _, v := range vertices {
+		polygon = append(polygon, Point{X: v[0], Y: v[1]})
+	}
+	return polygon
+}
+
+// Validate checks that the polygon has enough vertices to enclose an area.
+func (p Polygon) Validate() error {
+	if len(p) < 3 {
+		return errors.New("polygon must have at least 3 vertices")
+	}
+	return nil
+}
+
+// Contains reports whether pt lies inside the polygon, using the even-odd rule.
+func (p Polygon) Contains(pt Point) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.Y > pt.Y) != (b.Y > pt.Y) && pt.X < (b.X-a.X)*(pt.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Pose is the planar pose of the lidar relative to another frame, typically the robot's base.
+type Pose struct {
+	X        float64 `json:"x_mm"`
+	Y        float64 `json:"y_mm"`
+	ThetaDeg float64 `json:"theta_deg"`
+}
+
+// Transform maps a point from the lidar's frame into the frame the pose is expressed in.
+func (p Pose) Transform(pt Point) Point {
+	sin, cos := math.Sincos(p.ThetaDeg * math.Pi / 180)
+	return Point{
+		X: cos*pt.X - sin*pt.Y + p.X,
+		Y: sin*pt.X + cos*pt.Y + p.Y,
+	}
+}
+
+// Compose returns the pose of a frame given by q relative to p, relative to the frame p is expressed in.
+func (p Pose) Compose(q Pose) Pose {
+	pt := p.Transform(Point{X: q.X, Y: q.Y})
+	return Pose{X: pt.X, Y: pt.Y, ThetaDeg: p.ThetaDeg + q.ThetaDeg}
+}
+
+// Inverse returns the pose of the frame p is expressed in, relative to the frame given by p.
+func (p Pose) Inverse() Pose {
+	sin, cos := math.Sincos(p.ThetaDeg * math.Pi / 180)
+	return Pose{
+		X:        -cos*p.X - sin*p.Y,
+		Y:        sin*p.X - cos*p.Y,
+		ThetaDeg: -p.ThetaDeg,
+	}
+}
@@ -0,0 +1,57 @@
+package geometry
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestPolygon(t *testing.T) {
+	square := PolygonFromConfig([][2]float64{{-100, -100}, {100, -100}, {100, 100}, {-100, 100}})
+
+	t.Run("validate", func(t *testing.T) {
+		test.That(t, square.Validate(), test.ShouldBeNil)
+
+		err := square[:2].Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "polygon must have at least 3 vertices")
+	})
+
+	t.Run("contains", func(t *testing.T) {
+		test.That(t, square.Contains(Point{X: 0, Y: 0}), test.ShouldBeTrue)
+		test.That(t, square.Contains(Point{X: 99, Y: -99}), test.ShouldBeTrue)
+		test.That(t, square.Contains(Point{X: 101, Y: 0}), test.ShouldBeFalse)
+		test.That(t, square.Contains(Point{X: 0, Y: -150}), test.ShouldBeFalse)
+	})
+}
+
+func TestPoseTransform(t *testing.T) {
+	pose := Pose{X: 100, Y: 50, ThetaDeg: 90}
+	pt := pose.Transform(Point{X: 10, Y: 0})
+	test.That(t, pt.X, test.ShouldAlmostEqual, 100)
+	test.That(t, pt.Y, test.ShouldAlmostEqual, 60)
+}
+
+func TestPoseCompose(t *testing.T) {
+	pose := Pose{X: 100, Y: 50, ThetaDeg: 90}.Compose(Pose{X: 10, Y: 0, ThetaDeg: 45})
+	test.That(t, pose.X, test.ShouldAlmostEqual, 100)
+	test.That(t, pose.Y, test.ShouldAlmostEqual, 60)
+	test.That(t, pose.ThetaDeg, test.ShouldAlmostEqual, 135)
+
+	pt := Pose{X: 100, Y: 50, ThetaDeg: 90}.Transform(Pose{X: 10, Y: 0, ThetaDeg: 45}.Transform(Point{X: 5, Y: 5}))
+	composed := pose.Transform(Point{X: 5, Y: 5})
+	test.That(t, composed.X, test.ShouldAlmostEqual, pt.X)
+	test.That(t, composed.Y, test.ShouldAlmostEqual, pt.Y)
+}
+
+func TestPoseInverse(t *testing.T) {
+	pose := Pose{X: 100, Y: 50, ThetaDeg: 30}
+	identity := pose.Compose(pose.Inverse())
+	test.That(t, identity.X, test.ShouldAlmostEqual, 0)
+	test.That(t, identity.Y, test.ShouldAlmostEqual, 0)
+	test.That(t, identity.ThetaDeg, test.ShouldAlmostEqual, 0)
+
+	pt := pose.Inverse().Transform(pose.Transform(Point{X: 5, Y: 7}))
+	test.That(t, pt.X, test.ShouldAlmostEqual, 5)
+	test.That(t, pt.Y, test.ShouldAlmostEqual, 7)
+}
@@ -5,6 +5,7 @@ go 1.25.9
 require (
 	github.com/edaniels/golinters v0.0.5-0.20220906153528-641155550742
 	github.com/golang/geo v0.0.0-20230421003525-6adc56603217
+	github.com/kellydunn/golang-geo v0.7.0
 	github.com/mitchellh/go-ps v1.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/polyfloyd/go-errorlint v1.8.0
@@ -12,6 +13,7 @@ require (
 	go.viam.com/test v1.2.4
 	go.viam.com/utils v0.6.6
 	golang.org/x/tools v0.44.0
+	nhooyr.io/websocket v1.8.17
 )
 
 require (
@@ -114,7 +116,6 @@ require (
 	github.com/improbable-eng/grpc-web v0.15.0 // indirect
 	github.com/jedib0t/go-pretty/v6 v6.6.6 // indirect
 	github.com/jhump/protoreflect v1.17.0 // indirect
-	github.com/kellydunn/golang-geo v0.7.0 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/kylelemons/go-gypsy v1.0.0 // indirect
 	github.com/lestrrat-go/backoff/v2 v2.0.8 // indirect
@@ -227,5 +228,6 @@ require (
 	gorgonia.org/vecf32 v0.9.0 // indirect
 	gorgonia.org/vecf64 v0.9.0 // indirect
 	howett.net/plist v1.0.1 // indirect
-	nhooyr.io/websocket v1.8.17 // indirect
+	periph.io/x/conn/v3 v3.7.0 // indirect
+	periph.io/x/host/v3 v3.8.1-0.20230331112814-9f0d9f7d76db // indirect
 )
@@ -0,0 +1,229 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"image"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/vision/classification"
+	"go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+
+	viz "go.viam.com/rdk/vision"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+// gridBox returns the bounding box of nodes in the pixels of the occupancy grid image as [x0, y0, x1, y1], for
+// DoCommand responses. It returns false when the occupancy grid is not configured or the nodes are outside of it.
+func (rp *rplidar) gridBox(ns []nodes.Node) ([]interface{}, bool) {
+	if rp.occupancyGrid == nil {
+		return nil, false
+	}
+	points := make([]geometry.Point, 0, len(ns))
+	for _, n := range ns {
+		points = append(points, n.Point())
+	}
+	box, ok := rp.occupancyGrid.PixelBox(points)
+	if !ok {
+		return nil, false
+	}
+	return []interface{}{float64(box.Min.X), float64(box.Min.Y), float64(box.Max.X), float64(box.Max.Y)}, true
+}
+
+// gridObjectsService reports objects found by an rplidar camera as object point clouds, and as detections on the
+// camera's occupancy grid image. The camera returns the objects from a DoCommand, each with its points_mm and, when
+// the occupancy grid is configured, its grid_box_px.
+type gridObjectsService struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	cameraName string
+	camera     camera.Camera
+	// command is the DoCommand returning the objects under listKey.
+	command string
+	listKey string
+	// describe returns the label and the detection score of an object.
+	describe func(object map[string]interface{}) (string, float64)
+}
+
+func newGridObjectsService(
+	deps resource.Dependencies,
+	c resource.Config,
+	cameraName, command, listKey string,
+	describe func(map[string]interface{}) (string, float64),
+) (*gridObjectsService, error) {
+	cam, err := camera.FromProvider(deps, cameraName)
+	if err != nil {
+		return nil, err
+	}
+	return &gridObjectsService{
+		Named:      c.ResourceName().AsNamed(),
+		cameraName: cameraName,
+		camera:     cam,
+		command:    command,
+		listKey:    listKey,
+		describe:   describe,
+	}, nil
+}
+
+// objects returns the objects of the most recent revolution of the camera.
+func (s *gridObjectsService) objects(ctx context.Context, cameraName string) (map[string]interface{}, []map[string]interface{}, error) {
+	if cameraName != "" && cameraName != s.cameraName {
+		return nil, nil, errors.Errorf("camera %q is not the configured camera %q", cameraName, s.cameraName)
+	}
+	resp, err := s.camera.DoCommand(ctx, map[string]interface{}{s.command: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	list, _ := resp[s.listKey].([]interface{})
+	out := make([]map[string]interface{}, 0, len(list))
+	for _, object := range list {
+		m, ok := object.(map[string]interface{})
+		if !ok {
+			return nil, nil, errors.Errorf("camera %q returned malformed %s", s.cameraName, s.listKey)
+		}
+		out = append(out, m)
+	}
+	return resp, out, nil
+}
+
+// DetectionsFromCamera returns a detection for each object, bounding it on the camera's occupancy grid image.
+func (s *gridObjectsService) DetectionsFromCamera(
+	ctx context.Context,
+	cameraName string,
+	_ map[string]interface{},
+) ([]objectdetection.Detection, error) {
+	resp, list, err := s.objects(ctx, cameraName)
+	if err != nil {
+		return nil, err
+	}
+	width, ok := resp["grid_width_px"].(float64)
+	if !ok {
+		return nil, errors.Errorf("detections need the occupancy_grid of camera %q", s.cameraName)
+	}
+	bounds := image.Rect(0, 0, int(width), int(width))
+
+	detections := make([]objectdetection.Detection, 0, len(list))
+	for _, object := range list {
+		box, ok := object["grid_box_px"].([]interface{})
+		if !ok || len(box) != 4 {
+			// The object is outside of the grid.
+			continue
+		}
+		var corners [4]int
+		for i, v := range box {
+			f, _ := v.(float64)
+			corners[i] = int(f)
+		}
+		label, score := s.describe(object)
+		detections = append(detections, objectdetection.NewDetection(
+			bounds, image.Rect(corners[0], corners[1], corners[2], corners[3]), score, label))
+	}
+	return detections, nil
+}
+
+// GetObjectPointClouds returns the returns of each object, with its axis-aligned bounding box.
+func (s *gridObjectsService) GetObjectPointClouds(
+	ctx context.Context,
+	cameraName string,
+	_ map[string]interface{},
+) ([]*viz.Object, error) {
+	_, list, err := s.objects(ctx, cameraName)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*viz.Object, 0, len(list))
+	for _, object := range list {
+		points, _ := object["points_mm"].([]interface{})
+		pc := pointcloud.NewBasicPointCloud(len(points))
+		for _, point := range points {
+			xy, _ := point.([]interface{})
+			if len(xy) != 2 {
+				return nil, errors.Errorf("camera %q returned malformed %s", s.cameraName, s.listKey)
+			}
+			x, _ := xy[0].(float64)
+			y, _ := xy[1].(float64)
+			if err := pc.Set(r3.Vector{X: x, Y: y}, pointcloud.NewBasicData()); err != nil {
+				return nil, err
+			}
+		}
+		label, _ := s.describe(object)
+		vizObject, err := viz.NewObjectWithLabel(pc, label, nil)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, vizObject)
+	}
+	return objects, nil
+}
+
+// GetProperties reports that detections and object point clouds are supported.
+func (s *gridObjectsService) GetProperties(context.Context, map[string]interface{}) (*vision.Properties, error) {
+	return &vision.Properties{DetectionSupported: true, ObjectPCDsSupported: true, DefaultCamera: &s.cameraName}, nil
+}
+
+// CaptureAllFromCamera returns the occupancy grid image of the camera with the detections and objects that are
+// requested.
+func (s *gridObjectsService) CaptureAllFromCamera(
+	ctx context.Context,
+	cameraName string,
+	opts viscapture.CaptureOptions,
+	extra map[string]interface{},
+) (viscapture.VisCapture, error) {
+	var capture viscapture.VisCapture
+	if opts.ReturnImage {
+		images, _, err := s.camera.Images(ctx, []string{occupancyGridSource}, nil)
+		if err != nil {
+			return capture, err
+		}
+		if len(images) > 0 {
+			capture.Image = &images[0]
+		}
+	}
+	if opts.ReturnDetections {
+		detections, err := s.DetectionsFromCamera(ctx, cameraName, extra)
+		if err != nil {
+			return capture, err
+		}
+		capture.Detections = detections
+	}
+	if opts.ReturnObject {
+		objects, err := s.GetObjectPointClouds(ctx, cameraName, extra)
+		if err != nil {
+			return capture, err
+		}
+		capture.Objects = objects
+	}
+	return capture, nil
+}
+
+// Detections is not supported, since the objects are found in revolutions rather than images.
+func (s *gridObjectsService) Detections(
+	context.Context, *camera.NamedImage, map[string]interface{},
+) ([]objectdetection.Detection, error) {
+	return nil, errors.New("detections from an image are not supported, use DetectionsFromCamera")
+}
+
+// ClassificationsFromCamera is not supported.
+func (s *gridObjectsService) ClassificationsFromCamera(
+	context.Context, string, int, map[string]interface{},
+) (classification.Classifications, error) {
+	return nil, errors.New("classifications are not supported")
+}
+
+// Classifications is not supported.
+func (s *gridObjectsService) Classifications(
+	context.Context, *camera.NamedImage, int, map[string]interface{},
+) (classification.Classifications, error) {
+	return nil, errors.New("classifications are not supported")
+}
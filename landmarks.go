@@ -0,0 +1,128 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+
+	"go.viam.com/rplidar/landmarks"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	getLandmarksCommand = "get_landmarks"
+
+	// landmarkLabel labels the detections and objects of the landmarks service.
+	landmarkLabel = "landmark"
+)
+
+// LandmarksModel is the model of the vision service that reports the retroreflective landmarks seen by an RPLiDAR
+// camera.
+var LandmarksModel = resource.NewModel("viam", "lidar", "rplidar-landmarks")
+
+func init() {
+	resource.RegisterService(vision.API, LandmarksModel, resource.Registration[vision.Service, *LandmarksConfig]{
+		Constructor: newLandmarksService,
+	})
+}
+
+// landmarkDetector holds the landmarks of the most recent revolution.
+type landmarkDetector struct {
+	conf landmarks.Config
+
+	mutex     sync.Mutex
+	latest    []landmarks.Landmark
+	timestamp time.Time
+}
+
+// setupLandmarks enables landmark detection when configured.
+func (rp *rplidar) setupLandmarks(conf *Config) {
+	if conf.Landmarks == nil {
+		return
+	}
+	rp.landmarks = &landmarkDetector{conf: *conf.Landmarks}
+}
+
+// detectLandmarks finds the retroreflective landmarks of a filtered revolution.
+func (rp *rplidar) detectLandmarks(scanned []nodes.Node, now time.Time) {
+	d := rp.landmarks
+	if d == nil {
+		return
+	}
+	detected := landmarks.Detect(scanned, d.conf)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.latest = detected
+	d.timestamp = now
+}
+
+// getLandmarks returns the landmarks of the most recent revolution, in the frame of the point cloud. When the
+// occupancy grid is configured, each landmark also has its bounding box in the pixels of the grid image.
+func (rp *rplidar) getLandmarks(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	d := rp.landmarks
+	if d == nil {
+		return nil, errors.New("landmarks are not configured")
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.timestamp.IsZero() {
+		return nil, errors.New("no revolution has been captured yet")
+	}
+	out := make([]interface{}, 0, len(d.latest))
+	for _, landmark := range d.latest {
+		m := landmark.Map()
+		if box, ok := rp.gridBox(landmark.Nodes); ok {
+			m["grid_box_px"] = box
+		}
+		out = append(out, m)
+	}
+	resp := map[string]interface{}{
+		"timestamp": d.timestamp.Format(time.RFC3339Nano),
+		"landmarks": out,
+	}
+	if rp.occupancyGrid != nil {
+		resp["grid_width_px"] = float64(rp.occupancyGrid.Width())
+	}
+	return resp, nil
+}
+
+// LandmarksConfig describes how to configure the landmarks vision service.
+type LandmarksConfig struct {
+	// Camera is the name of the rplidar camera whose landmarks are reported.
+	Camera string `json:"camera"`
+}
+
+// Validate checks that the camera is set and depends on it.
+func (conf *LandmarksConfig) Validate(path string) ([]string, []string, error) {
+	if conf.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	return []string{conf.Camera}, nil, nil
+}
+
+// newLandmarksService reports the landmarks detected by an rplidar camera. Detections are scored by the mean
+// quality of the landmark's returns.
+func newLandmarksService(
+	_ context.Context,
+	deps resource.Dependencies,
+	c resource.Config,
+	_ logging.Logger,
+) (vision.Service, error) {
+	conf, err := resource.NativeConfig[*LandmarksConfig](c)
+	if err != nil {
+		return nil, err
+	}
+	return newGridObjectsService(deps, c, conf.Camera, getLandmarksCommand, "landmarks",
+		func(landmark map[string]interface{}) (string, float64) {
+			quality, _ := landmark["mean_quality"].(float64)
+			return landmarkLabel, quality / 255
+		})
+}
@@ -0,0 +1,178 @@
+// Package landmarks detects retroreflective landmarks, such as reflective tape or poles, in a revolution of RPLiDAR
+// measurement nodes. Retroreflectors return far more light than other surfaces, which time-of-flight models such as
+// the S1 report in the quality of each node.
+package landmarks
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/clusters"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultMinQuality = 200
+	defaultMinWidthMM = 10.
+	defaultMaxWidthMM = 200.
+	defaultMinPoints  = 2
+	defaultMaxGapMM   = 30.
+)
+
+// Config describes which returns form a landmark. Distances are in millimeters.
+type Config struct {
+	// MinQuality is the lowest quality of a return from a retroreflector, from 0 to 255.
+	MinQuality int `json:"min_quality,omitempty"`
+	// MinWidthMM and MaxWidthMM bound the width of a landmark, from its first to its last return.
+	MinWidthMM float64 `json:"min_width_mm,omitempty"`
+	MaxWidthMM float64 `json:"max_width_mm,omitempty"`
+	MinPoints  int     `json:"min_points,omitempty"`
+	// MaxGapMM is the largest gap between consecutive returns of a landmark at short range. The gap allowed grows
+	// with range, as for clustering.
+	MaxGapMM float64 `json:"max_gap_mm,omitempty"`
+}
+
+// Validate checks that the landmark attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.MinQuality < 0 || conf.MinQuality > 255 {
+		return errors.New("min_quality must be between 0 and 255")
+	}
+	if conf.MinWidthMM < 0 || conf.MaxWidthMM < 0 || conf.MaxGapMM < 0 {
+		return errors.New("distances must be positive")
+	}
+	withDefaults := conf.withDefaults()
+	if withDefaults.MinWidthMM > withDefaults.MaxWidthMM {
+		return errors.New("min_width_mm must not be greater than max_width_mm")
+	}
+	if conf.MinPoints < 0 {
+		return errors.New("min_points must be positive")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.MinQuality == 0 {
+		conf.MinQuality = defaultMinQuality
+	}
+	if conf.MinWidthMM == 0 {
+		conf.MinWidthMM = defaultMinWidthMM
+	}
+	if conf.MaxWidthMM == 0 {
+		conf.MaxWidthMM = defaultMaxWidthMM
+	}
+	if conf.MinPoints == 0 {
+		conf.MinPoints = defaultMinPoints
+	}
+	if conf.MaxGapMM == 0 {
+		conf.MaxGapMM = defaultMaxGapMM
+	}
+	return conf
+}
+
+// Landmark is a run of consecutive high intensity returns, in the frame of the point cloud.
+type Landmark struct {
+	// Center is the centroid of the returns.
+	Center geometry.Point
+	// DistanceMM and BearingDeg locate the center from the lidar, with the bearing counter-clockwise from the x axis.
+	DistanceMM float64
+	BearingDeg float64
+	// WidthMM is the distance between the first and last returns.
+	WidthMM float64
+	// MeanQuality is the mean quality of the returns, from 0 to 255.
+	MeanQuality float64
+	Nodes       []nodes.Node
+}
+
+// Detect returns the landmarks of a revolution sorted by ascending angle. Nodes without a return must already be
+// removed.
+func Detect(revolution []nodes.Node, conf Config) []Landmark {
+	conf = conf.withDefaults()
+
+	// Split the revolution into runs of bright returns at every dim return.
+	var runs [][]nodes.Node
+	start := -1
+	for i, n := range revolution {
+		bright := int(n.Quality) >= conf.MinQuality
+		switch {
+		case bright && start < 0:
+			start = i
+		case !bright && start >= 0:
+			runs = append(runs, revolution[start:i])
+			start = -1
+		}
+	}
+	if start >= 0 {
+		last := revolution[start:]
+		if len(runs) > 0 && int(revolution[0].Quality) >= conf.MinQuality {
+			// The revolution starts and ends on the same landmark, so the last run continues into the first.
+			last = append(append([]nodes.Node{}, last...), runs[0]...)
+			runs = runs[1:]
+		}
+		runs = append(runs, last)
+	}
+
+	// Split the runs wherever consecutive returns are too far apart to be on the same landmark.
+	gapConf := clusters.Config{GapMM: conf.MaxGapMM}
+	var landmarks []Landmark
+	for _, run := range runs {
+		first := 0
+		for i := 1; i <= len(run); i++ {
+			if i < len(run) && gapConf.Connected(run[i-1], run[i]) {
+				continue
+			}
+			if landmark, ok := newLandmark(run[first:i], conf); ok {
+				landmarks = append(landmarks, landmark)
+			}
+			first = i
+		}
+	}
+	return landmarks
+}
+
+// newLandmark measures a run of bright returns, and reports whether it has enough returns and its width is within
+// bounds.
+func newLandmark(run []nodes.Node, conf Config) (Landmark, bool) {
+	if len(run) < conf.MinPoints {
+		return Landmark{}, false
+	}
+	first, last := run[0].Point(), run[len(run)-1].Point()
+	landmark := Landmark{
+		WidthMM: math.Hypot(last.X-first.X, last.Y-first.Y),
+		Nodes:   run,
+	}
+	if landmark.WidthMM < conf.MinWidthMM || landmark.WidthMM > conf.MaxWidthMM {
+		return Landmark{}, false
+	}
+
+	var quality int
+	n := float64(len(run))
+	for _, node := range run {
+		pt := node.Point()
+		landmark.Center.X += pt.X / n
+		landmark.Center.Y += pt.Y / n
+		quality += int(node.Quality)
+	}
+	landmark.MeanQuality = float64(quality) / n
+	landmark.DistanceMM = math.Hypot(landmark.Center.X, landmark.Center.Y)
+	landmark.BearingDeg = math.Atan2(landmark.Center.Y, landmark.Center.X) * 180 / math.Pi
+	return landmark, true
+}
+
+// Map returns the landmark in a layout suitable for a DoCommand response, including the position of every return.
+func (l Landmark) Map() map[string]interface{} {
+	points := make([]interface{}, 0, len(l.Nodes))
+	for _, n := range l.Nodes {
+		pt := n.Point()
+		points = append(points, []interface{}{pt.X, pt.Y})
+	}
+	return map[string]interface{}{
+		"center_mm":    []interface{}{l.Center.X, l.Center.Y},
+		"distance_mm":  l.DistanceMM,
+		"bearing_deg":  l.BearingDeg,
+		"width_mm":     l.WidthMM,
+		"mean_quality": l.MeanQuality,
+		"points_mm":    points,
+	}
+}
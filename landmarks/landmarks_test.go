@@ -0,0 +1,91 @@
+package landmarks
+
+import (
+	"math"
+	"testing"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{MinQuality: 300}, "min_quality must be between 0 and 255"},
+		{Config{MaxGapMM: -1}, "distances must be positive"},
+		{Config{MinWidthMM: 300}, "min_width_mm must not be greater than max_width_mm"},
+		{Config{MinPoints: -1}, "min_points must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// scan returns a revolution every quarter degree at a constant distance, with the quality given by bright for each
+// angle.
+func scan(distance float64, bright func(angle float64) bool) []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 0.; angle < 360; angle += 0.25 {
+		n := nodes.Node{Angle: angle, Distance: distance, Quality: 60}
+		if bright(angle) {
+			n.Quality = 250
+		}
+		revolution = append(revolution, n)
+	}
+	return revolution
+}
+
+func TestDetect(t *testing.T) {
+	t.Run("bright runs within the size bounds", func(t *testing.T) {
+		// At 2 m, a quarter degree is about 8.7 mm. The reflector at 90 degrees is about 70 mm wide, and the one at
+		// 180 degrees about 500 mm wide.
+		revolution := scan(2000, func(angle float64) bool {
+			return (angle >= 89 && angle <= 91) || (angle >= 172 && angle <= 186.5) || angle == 270
+		})
+		landmarks := Detect(revolution, Config{})
+		test.That(t, len(landmarks), test.ShouldEqual, 1)
+
+		landmark := landmarks[0]
+		test.That(t, len(landmark.Nodes), test.ShouldEqual, 9)
+		test.That(t, landmark.WidthMM, test.ShouldAlmostEqual, 2*2000*math.Sin(math.Pi/180), 0.1)
+		test.That(t, landmark.Center.X, test.ShouldAlmostEqual, 0, 1e-6)
+		test.That(t, landmark.Center.Y, test.ShouldAlmostEqual, 2000, 1)
+		test.That(t, landmark.BearingDeg, test.ShouldAlmostEqual, 90, 1e-6)
+		test.That(t, landmark.MeanQuality, test.ShouldEqual, 250)
+
+		m := landmark.Map()
+		test.That(t, m["width_mm"], test.ShouldAlmostEqual, landmark.WidthMM)
+		test.That(t, len(m["points_mm"].([]interface{})), test.ShouldEqual, 9)
+	})
+
+	t.Run("a landmark across the start of the revolution", func(t *testing.T) {
+		revolution := scan(2000, func(angle float64) bool { return angle <= 1 || angle >= 359 })
+		landmarks := Detect(revolution, Config{})
+		test.That(t, len(landmarks), test.ShouldEqual, 1)
+		test.That(t, len(landmarks[0].Nodes), test.ShouldEqual, 9)
+		test.That(t, landmarks[0].Nodes[0].Angle, test.ShouldEqual, 359)
+		test.That(t, math.Abs(landmarks[0].BearingDeg), test.ShouldAlmostEqual, 180, 1e-6)
+	})
+
+	t.Run("runs are split at range jumps", func(t *testing.T) {
+		revolution := scan(2000, func(angle float64) bool { return angle >= 89 && angle <= 91 })
+		for i := range revolution {
+			if revolution[i].Angle > 90 {
+				revolution[i].Distance = 3000
+			}
+		}
+		landmarks := Detect(revolution, Config{MinWidthMM: 20})
+		test.That(t, len(landmarks), test.ShouldEqual, 2)
+		test.That(t, landmarks[1].DistanceMM, test.ShouldAlmostEqual, 3000, 1)
+	})
+
+	t.Run("nothing bright", func(t *testing.T) {
+		test.That(t, Detect(scan(2000, func(float64) bool { return false }), Config{}), test.ShouldBeEmpty)
+		test.That(t, Detect(nil, Config{}), test.ShouldBeEmpty)
+	})
+}
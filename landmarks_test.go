@@ -0,0 +1,133 @@
+package rplidar
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/landmarks"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/occupancy"
+)
+
+// reflectorRevolution returns a revolution of a circular room with a strip of retroreflective tape at 90 degrees.
+func reflectorRevolution() []nodes.Node {
+	var scanned []nodes.Node
+	for angle := 0.; angle < 360; angle += 0.5 {
+		n := nodes.Node{Angle: angle, Distance: 1000, Quality: 60}
+		if angle >= 89 && angle <= 91 {
+			n.Quality = 250
+		}
+		scanned = append(scanned, n)
+	}
+	return scanned
+}
+
+func TestGetLandmarks(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getLandmarksCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "landmarks are not configured")
+
+	conf := &Config{Landmarks: &landmarks.Config{MinPoints: -1}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid landmarks")
+
+	conf.Landmarks = &landmarks.Config{}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	rp.setupLandmarks(conf)
+
+	_, err = rp.DoCommand(ctx, map[string]interface{}{getLandmarksCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "no revolution has been captured yet")
+
+	rp.processRevolution(reflectorRevolution(), time.Now(), 0)
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getLandmarksCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp, test.ShouldNotContainKey, "grid_width_px")
+	list := resp["landmarks"].([]interface{})
+	test.That(t, len(list), test.ShouldEqual, 1)
+	landmark := list[0].(map[string]interface{})
+	test.That(t, landmark["bearing_deg"], test.ShouldAlmostEqual, 90, 1e-6)
+	test.That(t, landmark["mean_quality"], test.ShouldEqual, 250.)
+	test.That(t, landmark, test.ShouldNotContainKey, "grid_box_px")
+}
+
+func TestLandmarks(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	_, _, err := (&LandmarksConfig{}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	deps, _, err := (&LandmarksConfig{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+
+	rp := &rplidar{Named: camera.Named("lidar").AsNamed(), cache: &dataCache{}, logger: logger}
+	rp.setupLandmarks(&Config{Landmarks: &landmarks.Config{}})
+	svc, err := newLandmarksService(ctx, resource.Dependencies{camera.Named("lidar"): rp}, resource.Config{
+		Name:                "landmarks",
+		API:                 vision.API,
+		ConvertedAttributes: &LandmarksConfig{Camera: "lidar"},
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	rp.processRevolution(reflectorRevolution(), time.Now(), 0)
+
+	objects, err := svc.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldEqual, 1)
+	test.That(t, objects[0].Size(), test.ShouldEqual, 5)
+	test.That(t, objects[0].Geometry.Label(), test.ShouldEqual, landmarkLabel)
+
+	_, err = svc.GetObjectPointClouds(ctx, "other", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// Detections are drawn on the occupancy grid, so they need it.
+	_, err = svc.DetectionsFromCamera(ctx, "", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "occupancy_grid")
+
+	rp.setupOccupancyGrid(&Config{OccupancyGrid: &occupancy.Config{ResolutionMM: 100, SizeMM: 4000}})
+	rp.processRevolution(reflectorRevolution(), time.Now(), 0)
+
+	detections, err := svc.DetectionsFromCamera(ctx, "lidar", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(detections), test.ShouldEqual, 1)
+	// The tape is 1 m from the lidar along +y, straddling rows 29 and 30 of the grid, which are rows 10 and 9 of the
+	// image.
+	test.That(t, *detections[0].BoundingBox(), test.ShouldResemble, image.Rect(19, 9, 21, 11))
+	test.That(t, detections[0].Score(), test.ShouldAlmostEqual, 250./255)
+	test.That(t, detections[0].Label(), test.ShouldEqual, landmarkLabel)
+
+	capture, err := svc.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{
+		ReturnImage: true, ReturnDetections: true, ReturnObject: true,
+	}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, capture.Image, test.ShouldNotBeNil)
+	test.That(t, capture.Image.SourceName, test.ShouldEqual, occupancyGridSource)
+	test.That(t, len(capture.Detections), test.ShouldEqual, 1)
+	test.That(t, len(capture.Objects), test.ShouldEqual, 1)
+
+	props, err := svc.GetProperties(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.DetectionSupported, test.ShouldBeTrue)
+	test.That(t, props.ClassificationSupported, test.ShouldBeFalse)
+
+	_, err = svc.Detections(ctx, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = svc.ClassificationsFromCamera(ctx, "", 1, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,125 @@
+// Package laserscan converts RPLiDAR revolutions into the polar layout of the ROS sensor_msgs/LaserScan message.
+package laserscan
+
+import (
+	"math"
+	"time"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+// DefaultAngleIncrementDeg is the width of each bin when none is given.
+const DefaultAngleIncrementDeg = 1.0
+
+// LaserScan is a single revolution laid out like a ROS sensor_msgs/LaserScan. Angles are in radians, counter-clockwise
+// in the frame of the camera's point clouds, and ranges are in meters. Bins without a return hold +Inf, as in ROS
+// REP 117, which is above RangeMax and so read as no return by LaserScan consumers.
+type LaserScan struct {
+	Timestamp      time.Time
+	AngleMin       float64
+	AngleMax       float64
+	AngleIncrement float64
+	TimeIncrement  float64
+	ScanTime       float64
+	RangeMin       float64
+	RangeMax       float64
+	Ranges         []float64
+	Intensities    []float64
+}
+
+// Options describes how a revolution is binned.
+type Options struct {
+	// AngleIncrementDeg is the width of each bin in degrees.
+	AngleIncrementDeg float64
+	// RangeMinMM and RangeMaxMM are the range limits of the lidar in millimeters.
+	RangeMinMM float64
+	RangeMaxMM float64
+	// Timestamp is the time the revolution was captured and ScanTime how long it took.
+	Timestamp time.Time
+	ScanTime  time.Duration
+}
+
+// FromNodes bins a revolution into fixed angular bins covering the full circle, keeping the closest return in
+// each bin.
+func FromNodes(revolution []nodes.Node, opts Options) LaserScan {
+	if opts.AngleIncrementDeg <= 0 {
+		opts.AngleIncrementDeg = DefaultAngleIncrementDeg
+	}
+	increment := opts.AngleIncrementDeg * math.Pi / 180
+	numBins := int(math.Ceil(2 * math.Pi / increment))
+
+	scan := LaserScan{
+		Timestamp:      opts.Timestamp,
+		AngleMin:       -math.Pi,
+		AngleMax:       -math.Pi + float64(numBins-1)*increment,
+		AngleIncrement: increment,
+		ScanTime:       opts.ScanTime.Seconds(),
+		RangeMin:       opts.RangeMinMM / 1000,
+		RangeMax:       opts.RangeMaxMM / 1000,
+		Ranges:         make([]float64, numBins),
+		Intensities:    make([]float64, numBins),
+	}
+	for i := range scan.Ranges {
+		scan.Ranges[i] = math.Inf(1)
+	}
+	if len(revolution) > 0 {
+		scan.TimeIncrement = scan.ScanTime / float64(len(revolution))
+	}
+
+	for _, node := range revolution {
+		bin := int((Angle(node) - scan.AngleMin) / increment)
+		if bin < 0 || bin >= numBins {
+			continue
+		}
+		r := node.Distance / 1000
+		if r < scan.Ranges[bin] {
+			scan.Ranges[bin] = r
+			scan.Intensities[bin] = float64(node.Quality)
+		}
+	}
+	return scan
+}
+
+// Angle returns the counter-clockwise angle of the node in radians, in the range [-pi, pi), in the frame of the
+// camera's point clouds. The RPLiDAR reports angles clockwise and the point clouds flip the x axis, so a node at
+// heading theta lies at pi - theta.
+func Angle(node nodes.Node) float64 {
+	angle := math.Pi - node.Angle*math.Pi/180
+	if angle >= math.Pi {
+		angle -= 2 * math.Pi
+	}
+	return angle
+}
+
+// FiniteRanges returns the ranges with the bins without a return set to the largest float32 rather than +Inf, for
+// encodings such as JSON that cannot represent infinity. That is still above any RangeMax.
+func (scan LaserScan) FiniteRanges() []float64 {
+	ranges := make([]float64, len(scan.Ranges))
+	for i, r := range scan.Ranges {
+		ranges[i] = math.Min(r, math.MaxFloat32)
+	}
+	return ranges
+}
+
+// Map returns the scan as a map suitable for a DoCommand response, using the ROS field names.
+func (scan LaserScan) Map() map[string]interface{} {
+	ranges := make([]interface{}, len(scan.Ranges))
+	intensities := make([]interface{}, len(scan.Intensities))
+	for i := range scan.Ranges {
+		ranges[i] = scan.Ranges[i]
+		intensities[i] = scan.Intensities[i]
+	}
+
+	return map[string]interface{}{
+		"timestamp":       scan.Timestamp.Format(time.RFC3339Nano),
+		"angle_min":       scan.AngleMin,
+		"angle_max":       scan.AngleMax,
+		"angle_increment": scan.AngleIncrement,
+		"time_increment":  scan.TimeIncrement,
+		"scan_time":       scan.ScanTime,
+		"range_min":       scan.RangeMin,
+		"range_max":       scan.RangeMax,
+		"ranges":          ranges,
+		"intensities":     intensities,
+	}
+}
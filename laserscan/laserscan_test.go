@@ -0,0 +1,66 @@
+package laserscan
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestAngle(t *testing.T) {
+	test.That(t, Angle(nodes.Node{Angle: 0}), test.ShouldAlmostEqual, -math.Pi)
+	test.That(t, Angle(nodes.Node{Angle: 90}), test.ShouldAlmostEqual, math.Pi/2)
+	test.That(t, Angle(nodes.Node{Angle: 180}), test.ShouldAlmostEqual, 0)
+	test.That(t, Angle(nodes.Node{Angle: 270}), test.ShouldAlmostEqual, -math.Pi/2)
+
+	// The angle must match the direction of the point in the point cloud frame.
+	node := nodes.Node{Angle: 30, Distance: 1000}
+	pt := node.Point()
+	test.That(t, math.Atan2(pt.Y, pt.X), test.ShouldAlmostEqual, Angle(node))
+}
+
+func TestFromNodes(t *testing.T) {
+	timestamp := time.Unix(100, 0)
+	revolution := []nodes.Node{
+		{Angle: 90.3, Distance: 2000, Quality: 10},
+		{Angle: 90.6, Distance: 1500, Quality: 20},
+		{Angle: 180, Distance: 1000, Quality: 30},
+		{Angle: 270, Distance: 3000, Quality: 40},
+	}
+	scan := FromNodes(revolution, Options{
+		AngleIncrementDeg: 1,
+		RangeMinMM:        150,
+		RangeMaxMM:        12000,
+		Timestamp:         timestamp,
+		ScanTime:          100 * time.Millisecond,
+	})
+
+	test.That(t, len(scan.Ranges), test.ShouldEqual, 360)
+	test.That(t, len(scan.Intensities), test.ShouldEqual, 360)
+	test.That(t, scan.AngleMin, test.ShouldAlmostEqual, -math.Pi)
+	test.That(t, scan.AngleIncrement, test.ShouldAlmostEqual, math.Pi/180)
+	test.That(t, scan.AngleMax, test.ShouldAlmostEqual, math.Pi-math.Pi/180)
+	test.That(t, scan.ScanTime, test.ShouldAlmostEqual, 0.1)
+	test.That(t, scan.TimeIncrement, test.ShouldAlmostEqual, 0.025)
+	test.That(t, scan.RangeMin, test.ShouldAlmostEqual, 0.15)
+	test.That(t, scan.RangeMax, test.ShouldAlmostEqual, 12)
+
+	// Heading 180 maps to angle 0, which is the middle bin.
+	test.That(t, scan.Ranges[180], test.ShouldAlmostEqual, 1)
+	test.That(t, scan.Intensities[180], test.ShouldAlmostEqual, 30)
+	// Headings 90.3 and 90.6 fall in the bin just below angle pi/2 and the closest return wins.
+	test.That(t, scan.Ranges[269], test.ShouldAlmostEqual, 1.5)
+	test.That(t, scan.Intensities[269], test.ShouldAlmostEqual, 20)
+	test.That(t, scan.Ranges[90], test.ShouldAlmostEqual, 3)
+	test.That(t, math.IsInf(scan.Ranges[0], 1), test.ShouldBeTrue)
+	test.That(t, scan.Ranges[0], test.ShouldBeGreaterThan, scan.RangeMax)
+	test.That(t, scan.FiniteRanges()[0], test.ShouldEqual, math.MaxFloat32)
+	test.That(t, scan.FiniteRanges()[180], test.ShouldAlmostEqual, 1)
+
+	m := scan.Map()
+	test.That(t, m["angle_increment"], test.ShouldAlmostEqual, math.Pi/180)
+	test.That(t, len(m["ranges"].([]interface{})), test.ShouldEqual, 360)
+}
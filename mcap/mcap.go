@@ -0,0 +1,175 @@
+// Package mcap writes MCAP files (https://mcap.dev), the log format used by Foxglove and most robotics perception
+// tooling. Only the subset needed to record RPLiDAR revolutions is implemented: files are written without chunks or
+// a summary section, which every MCAP reader supports by scanning the data section.
+package mcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Magic starts and ends every MCAP file.
+var Magic = []byte{0x89, 'M', 'C', 'A', 'P', '0', '\r', '\n'}
+
+// The opcodes of the records written by Writer.
+const (
+	OpHeader  = 0x01
+	OpFooter  = 0x02
+	OpSchema  = 0x03
+	OpChannel = 0x04
+	OpMessage = 0x05
+	OpDataEnd = 0x0F
+)
+
+// ErrClosed is returned when writing to a Writer that has been closed.
+var ErrClosed = errors.New("mcap writer closed")
+
+// Writer writes an unchunked MCAP file. It is not safe for concurrent use.
+type Writer struct {
+	out       io.Writer
+	buf       bytes.Buffer
+	schemas   uint16
+	channels  uint16
+	sequences map[uint16]uint32
+	closed    bool
+}
+
+// NewWriter writes the magic and header of an MCAP file with the given profile, e.g. "ros1" or "" for none, and
+// library name.
+func NewWriter(out io.Writer, profile, library string) (*Writer, error) {
+	w := &Writer{out: out, sequences: map[uint16]uint32{}}
+	if _, err := out.Write(Magic); err != nil {
+		return nil, err
+	}
+
+	w.putString(profile)
+	w.putString(library)
+	if err := w.flushRecord(OpHeader); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// AddSchema writes a schema record and returns its ID. encoding is the schema encoding, e.g. "jsonschema".
+func (w *Writer) AddSchema(name, encoding string, data []byte) (uint16, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	w.schemas++
+	w.putUint16(w.schemas)
+	w.putString(name)
+	w.putString(encoding)
+	w.putBytes(data)
+	if err := w.flushRecord(OpSchema); err != nil {
+		return 0, err
+	}
+	return w.schemas, nil
+}
+
+// AddChannel writes a channel record for the topic and returns its ID. messageEncoding is the encoding of the
+// messages on the channel, e.g. "json".
+func (w *Writer) AddChannel(schemaID uint16, topic, messageEncoding string) (uint16, error) {
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	w.channels++
+	w.putUint16(w.channels)
+	w.putUint16(schemaID)
+	w.putString(topic)
+	w.putString(messageEncoding)
+	// No metadata.
+	w.putUint32(0)
+	if err := w.flushRecord(OpChannel); err != nil {
+		return 0, err
+	}
+	return w.channels, nil
+}
+
+// WriteMessage writes a message on the channel, logged and published at the given time.
+func (w *Writer) WriteMessage(channelID uint16, t time.Time, data []byte) error {
+	if w.closed {
+		return ErrClosed
+	}
+
+	sequence := w.sequences[channelID]
+	w.sequences[channelID] = sequence + 1
+
+	w.putUint16(channelID)
+	w.putUint32(sequence)
+	w.putUint64(uint64(t.UnixNano()))
+	w.putUint64(uint64(t.UnixNano()))
+	w.buf.Write(data)
+	return w.flushRecord(OpMessage)
+}
+
+// Close ends the data section and writes the footer and trailing magic. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	// A data section CRC of zero means it was not computed.
+	w.putUint32(0)
+	if err := w.flushRecord(OpDataEnd); err != nil {
+		return err
+	}
+
+	// Without a summary section the summary start, summary offset start and summary CRC are all zero.
+	w.putUint64(0)
+	w.putUint64(0)
+	w.putUint32(0)
+	if err := w.flushRecord(OpFooter); err != nil {
+		return err
+	}
+	_, err := w.out.Write(Magic)
+	return err
+}
+
+// flushRecord writes the buffered record content prefixed by its opcode and length.
+func (w *Writer) flushRecord(op byte) error {
+	defer w.buf.Reset()
+
+	var prefix [9]byte
+	prefix[0] = op
+	binary.LittleEndian.PutUint64(prefix[1:], uint64(w.buf.Len()))
+	if _, err := w.out.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.out.Write(w.buf.Bytes())
+	return err
+}
+
+func (w *Writer) putUint16(v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *Writer) putUint32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *Writer) putUint64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *Writer) putString(s string) {
+	w.putUint32(uint32(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *Writer) putBytes(b []byte) {
+	w.putUint32(uint32(len(b)))
+	w.buf.Write(b)
+}
@@ -0,0 +1,85 @@
+package mcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+type record struct {
+	op      byte
+	content []byte
+}
+
+// readRecords splits an MCAP file into its records, checking the leading and trailing magic.
+func readRecords(t *testing.T, data []byte) []record {
+	t.Helper()
+	test.That(t, data[:len(Magic)], test.ShouldResemble, Magic)
+	test.That(t, data[len(data)-len(Magic):], test.ShouldResemble, Magic)
+
+	var records []record
+	rest := data[len(Magic) : len(data)-len(Magic)]
+	for len(rest) > 0 {
+		test.That(t, len(rest), test.ShouldBeGreaterThanOrEqualTo, 9)
+		length := binary.LittleEndian.Uint64(rest[1:])
+		test.That(t, uint64(len(rest)-9), test.ShouldBeGreaterThanOrEqualTo, length)
+		records = append(records, record{op: rest[0], content: rest[9 : 9+length]})
+		rest = rest[9+length:]
+	}
+	return records
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "", "rplidar")
+	test.That(t, err, test.ShouldBeNil)
+
+	schemaID, err := w.AddSchema("foxglove.LaserScan", "jsonschema", []byte(`{}`))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, schemaID, test.ShouldEqual, 1)
+
+	channelID, err := w.AddChannel(schemaID, "/rplidar/scan", "json")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, channelID, test.ShouldEqual, 1)
+
+	logTime := time.Unix(1700000000, 500)
+	test.That(t, w.WriteMessage(channelID, logTime, []byte(`{"a":1}`)), test.ShouldBeNil)
+	test.That(t, w.WriteMessage(channelID, logTime.Add(time.Second), []byte(`{"a":2}`)), test.ShouldBeNil)
+	test.That(t, w.Close(), test.ShouldBeNil)
+	test.That(t, w.Close(), test.ShouldBeNil)
+
+	_, err = w.AddChannel(schemaID, "/rplidar/points", "json")
+	test.That(t, err, test.ShouldBeError, ErrClosed)
+	test.That(t, w.WriteMessage(channelID, logTime, nil), test.ShouldBeError, ErrClosed)
+
+	records := readRecords(t, buf.Bytes())
+	ops := make([]byte, len(records))
+	for i, r := range records {
+		ops[i] = r.op
+	}
+	test.That(t, ops, test.ShouldResemble, []byte{OpHeader, OpSchema, OpChannel, OpMessage, OpMessage, OpDataEnd, OpFooter})
+
+	header := records[0].content
+	test.That(t, binary.LittleEndian.Uint32(header), test.ShouldEqual, 0)
+	test.That(t, string(header[8:]), test.ShouldEqual, "rplidar")
+
+	channel := records[2].content
+	test.That(t, binary.LittleEndian.Uint16(channel), test.ShouldEqual, channelID)
+	test.That(t, binary.LittleEndian.Uint16(channel[2:]), test.ShouldEqual, schemaID)
+	topicLength := binary.LittleEndian.Uint32(channel[4:])
+	test.That(t, string(channel[8:8+topicLength]), test.ShouldEqual, "/rplidar/scan")
+
+	for i, r := range records[3:5] {
+		test.That(t, binary.LittleEndian.Uint16(r.content), test.ShouldEqual, channelID)
+		test.That(t, binary.LittleEndian.Uint32(r.content[2:]), test.ShouldEqual, i)
+		expectedTime := uint64(logTime.Add(time.Duration(i) * time.Second).UnixNano())
+		test.That(t, binary.LittleEndian.Uint64(r.content[6:]), test.ShouldEqual, expectedTime)
+		test.That(t, binary.LittleEndian.Uint64(r.content[14:]), test.ShouldEqual, expectedTime)
+	}
+	test.That(t, string(records[4].content[22:]), test.ShouldEqual, `{"a":2}`)
+
+	test.That(t, records[6].content, test.ShouldResemble, make([]byte, 20))
+}
@@ -8,8 +8,11 @@ import (
 	"go.viam.com/rplidar"
 
 	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/module"
+	"go.viam.com/rdk/services/vision"
 
 	"go.viam.com/utils"
 )
@@ -54,6 +57,36 @@ func mainWithArgs(ctx context.Context, args []string, logger logging.Logger) err
 		return err
 	}
 
+	// Add the sensor reporting the rplidar's protection zones
+	err = rpModule.AddModelFromRegistry(ctx, sensor.API, rplidar.ZonesModel)
+	if err != nil {
+		return err
+	}
+
+	// Add the vision service segmenting the rplidar's revolutions into obstacles
+	err = rpModule.AddModelFromRegistry(ctx, vision.API, rplidar.ClusteringModel)
+	if err != nil {
+		return err
+	}
+
+	// Add the vision service reporting the rplidar's retroreflective landmarks
+	err = rpModule.AddModelFromRegistry(ctx, vision.API, rplidar.LandmarksModel)
+	if err != nil {
+		return err
+	}
+
+	// Add the vision service reporting the objects tracked by the rplidar
+	err = rpModule.AddModelFromRegistry(ctx, vision.API, rplidar.TracksModel)
+	if err != nil {
+		return err
+	}
+
+	// Add the movement sensor estimating the rplidar's motion from its revolutions
+	err = rpModule.AddModelFromRegistry(ctx, movementsensor.API, rplidar.OdometryModel)
+	if err != nil {
+		return err
+	}
+
 	// Start the module
 	err = rpModule.Start(ctx)
 	defer rpModule.Close(ctx)
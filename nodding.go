@@ -0,0 +1,285 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/motor"
+	"go.viam.com/rdk/components/servo"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/utils"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/sweep"
+)
+
+const (
+	getSweepCommand = "get_sweep"
+
+	noddingAxisX = "x"
+	noddingAxisY = "y"
+
+	// tiltPollInterval is how often the tilt of the mount is read, several times per revolution so that the tilt of
+	// each node can be interpolated.
+	tiltPollInterval = 10 * time.Millisecond
+	// tiltReadTimeout bounds each read of the tilt, so that an unreachable dependency does not hold up the next one.
+	tiltReadTimeout = time.Second
+)
+
+// NoddingConfig describes a mount that tilts the rplidar's scan plane, driven by a servo or a motor, so that the
+// revolutions over a sweep of the mount form a 3D point cloud.
+type NoddingConfig struct {
+	// Servo is the name of a servo whose angle is the tilt of the mount, for a mount nodding back and forth.
+	Servo string `json:"servo,omitempty"`
+	// Motor is the name of a motor whose position in revolutions is the tilt of the mount, for a mount rotating
+	// continuously.
+	Motor string `json:"motor,omitempty"`
+	// Axis is the axis of the point cloud frame that the scan plane tilts about, "y" (the default) or "x".
+	Axis string `json:"axis,omitempty"`
+	// LevelDeg is the angle of the servo or motor at which the scan plane is level.
+	LevelDeg float64 `json:"level_deg,omitempty"`
+	// Reversed is set when increasing the angle of the servo or motor lowers the positive side of the scan plane.
+	Reversed bool `json:"reversed,omitempty"`
+	// MaxRevolutions ends a sweep after this many revolutions, in case the mount stops moving.
+	MaxRevolutions int `json:"max_revolutions,omitempty"`
+}
+
+// Validate checks that exactly one of the servo and the motor is set.
+func (conf *NoddingConfig) Validate() error {
+	if (conf.Servo == "") == (conf.Motor == "") {
+		return errors.New("exactly one of servo and motor must be set")
+	}
+	if conf.Axis != "" && conf.Axis != noddingAxisX && conf.Axis != noddingAxisY {
+		return errors.Errorf("axis must be %q or %q", noddingAxisX, noddingAxisY)
+	}
+	if conf.MaxRevolutions < 0 {
+		return errors.New("max_revolutions must be positive")
+	}
+	return nil
+}
+
+// dependency returns the name of the servo or motor driving the mount.
+func (conf *NoddingConfig) dependency() string {
+	if conf.Servo != "" {
+		return conf.Servo
+	}
+	return conf.Motor
+}
+
+// tiltReader reads the angle of the servo or motor driving the mount, in degrees.
+type tiltReader func(ctx context.Context) (float64, error)
+
+// noddingMount tracks the tilt of the mount and accumulates revolutions into sweeps.
+type noddingMount struct {
+	read     tiltReader
+	axis     string
+	levelDeg float64
+	sign     float64
+	history  *sweep.History
+	// accumulator is only used by the goroutine processing revolutions.
+	accumulator *sweep.Accumulator
+
+	mutex              sync.Mutex
+	latest             sweep.Sweep
+	cloud              pointcloud.PointCloud
+	sweeps             uint64
+	revolutionsSkipped uint64
+	readErrors         uint64
+	lastReadErr        error
+}
+
+func newNoddingMount(conf *NoddingConfig, read tiltReader) *noddingMount {
+	m := &noddingMount{
+		read:        read,
+		axis:        conf.Axis,
+		levelDeg:    conf.LevelDeg,
+		sign:        1,
+		history:     sweep.NewHistory(),
+		accumulator: sweep.NewAccumulator(conf.MaxRevolutions),
+	}
+	if m.axis == "" {
+		m.axis = noddingAxisY
+	}
+	if conf.Reversed {
+		m.sign = -1
+	}
+	return m
+}
+
+// setupNodding looks up the servo or motor driving the mount and starts the goroutine that reads its tilt.
+func (rp *rplidar) setupNodding(ctx context.Context, conf *Config, deps resource.Dependencies) error {
+	if conf.Nodding == nil {
+		return nil
+	}
+	var read tiltReader
+	if conf.Nodding.Servo != "" {
+		s, err := servo.FromProvider(deps, conf.Nodding.Servo)
+		if err != nil {
+			return errors.Wrap(err, "could not get the nodding servo")
+		}
+		read = func(ctx context.Context) (float64, error) {
+			deg, err := s.Position(ctx, nil)
+			return float64(deg), err
+		}
+	} else {
+		m, err := motor.FromProvider(deps, conf.Nodding.Motor)
+		if err != nil {
+			return errors.Wrap(err, "could not get the nodding motor")
+		}
+		read = func(ctx context.Context) (float64, error) {
+			revolutions, err := m.Position(ctx, nil)
+			return revolutions * 360, err
+		}
+	}
+	rp.nodding = newNoddingMount(conf.Nodding, read)
+
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.tiltLoop(ctx)
+	}()
+	return nil
+}
+
+// tiltLoop reads the tilt of the mount until ctx is done.
+func (rp *rplidar) tiltLoop(ctx context.Context) {
+	m := rp.nodding
+	ticker := time.NewTicker(tiltPollInterval)
+	defer ticker.Stop()
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, tiltReadTimeout)
+		deg, err := m.read(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.mutex.Lock()
+			m.readErrors++
+			if m.lastReadErr == nil || m.lastReadErr.Error() != err.Error() {
+				rp.logger.Warnf("nodding: failed to read the tilt of the mount: %v", err)
+			}
+			m.lastReadErr = err
+			m.mutex.Unlock()
+		} else {
+			m.history.Add(sweep.Sample{Time: time.Now(), TiltDeg: m.sign * (deg - m.levelDeg)})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// accumulateSweep adds a filtered revolution to the current sweep, with the tilt of each node interpolated from
+// the tilt readings during the revolution. It replaces the point cloud of the last sweep when the revolution
+// completes one.
+func (rp *rplidar) accumulateSweep(scanned []nodes.Node, now time.Time, scanTime time.Duration) {
+	m := rp.nodding
+	if m == nil {
+		return
+	}
+	// The first revolution has no scan time, so the time of its nodes is not known.
+	if scanTime <= 0 {
+		return
+	}
+	tilts, err := m.history.Tilts(scanned, now, scanTime)
+	if err != nil {
+		m.mutex.Lock()
+		m.revolutionsSkipped++
+		m.mutex.Unlock()
+		return
+	}
+
+	completed, ok := m.accumulator.Add(scanned, tilts, now.Add(-scanTime), now)
+	if !ok {
+		return
+	}
+	cloud, err := pointCloudFromSweep(completed, m.axis)
+	if err != nil {
+		rp.logger.Debugf("issue getting sweep pointcloud to cache: %v", err)
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.latest = completed
+	m.cloud = cloud
+	m.sweeps++
+}
+
+// pointCloud returns the point cloud of the last completed sweep.
+func (m *noddingMount) pointCloud() (pointcloud.PointCloud, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.cloud == nil {
+		return nil, errors.New("no sweep has been completed yet")
+	}
+	return m.cloud, nil
+}
+
+// pointCloudFromSweep creates a 3D point cloud from the nodes of a sweep and their tilts.
+func pointCloudFromSweep(s sweep.Sweep, axis string) (pointcloud.PointCloud, error) {
+	pc := pointcloud.NewBasicPointCloud(len(s.Points))
+	for _, p := range s.Points {
+		pos, d := tiltedPointFrom(utils.DegToRad(p.Node.Angle), utils.DegToRad(p.TiltDeg), p.Node.Distance/1000, axis, p.Node.Quality)
+		if err := pc.Set(pos, d); err != nil {
+			return nil, err
+		}
+	}
+	return pc, nil
+}
+
+// tiltedPointFrom returns the point in millimeters at the given yaw and distance in meters, with the scan plane
+// tilted about an axis of the point cloud frame. The pitch of pointFrom raises each beam along its own direction,
+// which traces a cone; tilting the mount instead rotates the whole plane, so returns along the axis do not move.
+// A positive tilt raises the positive side of the plane.
+func tiltedPointFrom(yaw, tilt, distance float64, axis string, reflectivity uint8) (r3.Vector, pointcloud.Data) {
+	pos, d := pointFrom(yaw, 0, distance, reflectivity)
+	sinTilt, cosTilt := math.Sincos(tilt)
+	if axis == noddingAxisX {
+		return pointcloud.NewVector(pos.X, pos.Y*cosTilt, pos.Y*sinTilt), d
+	}
+	return pointcloud.NewVector(pos.X*cosTilt, pos.Y, pos.X*sinTilt), d
+}
+
+// getSweep returns the current tilt of the mount and a summary of the last completed sweep.
+func (rp *rplidar) getSweep(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	m := rp.nodding
+	if m == nil {
+		return nil, errors.New("nodding is not configured")
+	}
+
+	out := map[string]interface{}{}
+	if sample, ok := m.history.Latest(); ok {
+		out["tilt_deg"] = sample.TiltDeg
+		out["tilt_timestamp"] = sample.Time.Format(time.RFC3339Nano)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	out["sweeps"] = float64(m.sweeps)
+	out["revolutions_skipped"] = float64(m.revolutionsSkipped)
+	out["read_errors"] = float64(m.readErrors)
+	if m.lastReadErr != nil {
+		out["last_read_error"] = m.lastReadErr.Error()
+	}
+	if m.sweeps > 0 {
+		out["last_sweep"] = map[string]interface{}{
+			"start":        m.latest.Start.Format(time.RFC3339Nano),
+			"end":          m.latest.End.Format(time.RFC3339Nano),
+			"revolutions":  float64(m.latest.Revolutions),
+			"points":       float64(len(m.latest.Points)),
+			"min_tilt_deg": m.latest.MinTiltDeg,
+			"max_tilt_deg": m.latest.MaxTiltDeg,
+		}
+	}
+	return out, nil
+}
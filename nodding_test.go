@@ -0,0 +1,175 @@
+package rplidar
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/r3"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/sweep"
+)
+
+func TestNoddingConfigValidate(t *testing.T) {
+	conf := NoddingConfig{Servo: "tilt", Axis: "x", MaxRevolutions: 50}
+	test.That(t, conf.Validate(), test.ShouldBeNil)
+
+	cfg := Config{Nodding: &conf}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"tilt"})
+
+	for _, tc := range []struct {
+		conf NoddingConfig
+		err  string
+	}{
+		{NoddingConfig{}, "exactly one of servo and motor must be set"},
+		{NoddingConfig{Servo: "tilt", Motor: "spin"}, "exactly one of servo and motor must be set"},
+		{NoddingConfig{Motor: "spin", Axis: "z"}, `axis must be "x" or "y"`},
+		{NoddingConfig{Motor: "spin", MaxRevolutions: -1}, "max_revolutions must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+func TestTiltedPointFrom(t *testing.T) {
+	// Node angle 0 lands on -x, and node angle 90 on +y.
+	pos, _ := tiltedPointFrom(0, utils.DegToRad(30), 2, noddingAxisY, 255)
+	test.That(t, pos.X, test.ShouldAlmostEqual, -2000*math.Cos(math.Pi/6))
+	test.That(t, pos.Y, test.ShouldAlmostEqual, 0)
+	test.That(t, pos.Z, test.ShouldAlmostEqual, -1000)
+
+	// Returns along the tilt axis do not move.
+	pos, _ = tiltedPointFrom(math.Pi/2, utils.DegToRad(30), 2, noddingAxisY, 255)
+	test.That(t, pos.X, test.ShouldAlmostEqual, 0)
+	test.That(t, pos.Y, test.ShouldAlmostEqual, 2000)
+	test.That(t, pos.Z, test.ShouldAlmostEqual, 0)
+
+	pos, _ = tiltedPointFrom(math.Pi/2, utils.DegToRad(30), 2, noddingAxisX, 255)
+	test.That(t, pos.X, test.ShouldAlmostEqual, 0)
+	test.That(t, pos.Y, test.ShouldAlmostEqual, 2000*math.Cos(math.Pi/6))
+	test.That(t, pos.Z, test.ShouldAlmostEqual, 1000)
+
+	// Without tilt, the point is the same as on a level mount.
+	for yawDeg := 0.; yawDeg < 360; yawDeg += 15 {
+		level, _ := pointFrom(utils.DegToRad(yawDeg), 0, 2, 255)
+		tilted, _ := tiltedPointFrom(utils.DegToRad(yawDeg), 0, 2, noddingAxisY, 255)
+		test.That(t, tilted.Sub(level).Norm(), test.ShouldAlmostEqual, 0)
+	}
+}
+
+func TestNodding(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getSweepCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "nodding is not configured")
+
+	// The servo is level at 90 degrees and nods 10 degrees either way, a degree per 10 ms.
+	rp.nodding = newNoddingMount(&NoddingConfig{Servo: "tilt", LevelDeg: 90}, nil)
+	base := time.Unix(100, 0)
+	servoDeg := func(t time.Duration) float64 {
+		phase := math.Mod(float64(t/(10*time.Millisecond)), 40)
+		if phase < 20 {
+			return 80 + phase
+		}
+		return 120 - phase
+	}
+	for elapsed := time.Duration(0); elapsed <= time.Second; elapsed += 10 * time.Millisecond {
+		rp.nodding.history.Add(sweep.Sample{Time: base.Add(elapsed), TiltDeg: servoDeg(elapsed) - 90})
+	}
+
+	_, err = rp.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "no sweep has been completed yet")
+
+	// A wall 2 m away along -x, seen by a node every degree within 45 degrees of node angle 0.
+	var scanned []nodes.Node
+	for angle := -45.; angle <= 45; angle++ {
+		scanned = append(scanned, nodes.Node{Angle: math.Mod(angle+360, 360), Distance: 2000 / math.Cos(utils.DegToRad(angle))})
+	}
+	scanned = append(scanned[45:], scanned[:45]...)
+
+	// Revolutions take 50 ms. The first one has no scan time and the upswing ends after the fifth.
+	rp.processRevolution(scanned, base.Add(50*time.Millisecond), 0)
+	for elapsed := 100 * time.Millisecond; elapsed <= 250*time.Millisecond; elapsed += 50 * time.Millisecond {
+		rp.processRevolution(scanned, base.Add(elapsed), 50*time.Millisecond)
+	}
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getSweepCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["sweeps"], test.ShouldEqual, 1.)
+	test.That(t, resp["tilt_deg"], test.ShouldEqual, 10.)
+	lastSweep := resp["last_sweep"].(map[string]interface{})
+	test.That(t, lastSweep["revolutions"], test.ShouldEqual, 4.)
+	test.That(t, lastSweep["points"], test.ShouldEqual, 4.*91)
+	test.That(t, lastSweep["min_tilt_deg"], test.ShouldAlmostEqual, -5)
+	test.That(t, lastSweep["max_tilt_deg"], test.ShouldAlmostEqual, 10)
+
+	pc, err := rp.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 4*91)
+	// The wall is vertical: tilting the plane moves its returns up and down the wall, and towards the lidar as it
+	// is seen at an angle.
+	var minZ, maxZ float64
+	pc.Iterate(0, 0, func(p r3.Vector, _ pointcloud.Data) bool {
+		test.That(t, p.X, test.ShouldBeLessThan, 0)
+		minZ, maxZ = math.Min(minZ, p.Z), math.Max(maxZ, p.Z)
+		return true
+	})
+	test.That(t, minZ, test.ShouldBeLessThan, -300)
+	test.That(t, maxZ, test.ShouldBeGreaterThan, 100)
+
+	// Revolutions without tilt readings are skipped.
+	rp.processRevolution(scanned, base.Add(time.Hour), 50*time.Millisecond)
+	rp.processRevolution(scanned, base.Add(time.Hour+50*time.Millisecond), 50*time.Millisecond)
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{getSweepCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["revolutions_skipped"], test.ShouldEqual, 2.)
+}
+
+func TestTiltLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reads atomic.Int32
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+	rp.nodding = newNoddingMount(&NoddingConfig{Motor: "spin", LevelDeg: 90, Reversed: true}, func(context.Context) (float64, error) {
+		if reads.Add(1) == 1 {
+			return 0, errors.New("not ready")
+		}
+		return 0.5 * 360, nil
+	})
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.tiltLoop(ctx)
+	}()
+
+	var sample sweep.Sample
+	waitFor(t, func() bool {
+		var ok bool
+		sample, ok = rp.nodding.history.Latest()
+		return ok
+	})
+	test.That(t, sample.TiltDeg, test.ShouldEqual, -90)
+
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getSweepCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["read_errors"], test.ShouldEqual, 1.)
+	test.That(t, resp["last_read_error"], test.ShouldEqual, "not ready")
+	test.That(t, resp, test.ShouldNotContainKey, "last_sweep")
+
+	cancel()
+	rp.cacheBackgroundWorkers.Wait()
+}
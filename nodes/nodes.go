@@ -0,0 +1,44 @@
+// Package nodes provides a driver independent representation of the measurement nodes returned by an RPLiDAR.
+package nodes
+
+import (
+	"math"
+
+	"go.viam.com/rplidar/geometry"
+)
+
+const (
+	// angleScaleQ14 converts a q14 fixed point angle_z value into degrees.
+	angleScaleQ14 = 90.0 / (1 << 14)
+	// distanceScaleQ2 converts a q2 fixed point distance value into millimeters.
+	distanceScaleQ2 = 1.0 / 4
+)
+
+// Node is a single measurement taken by the RPLiDAR.
+type Node struct {
+	// Angle is the heading of the measurement in degrees, in the range [0, 360).
+	Angle float64
+	// Distance is the measured range in millimeters. A distance of zero marks an invalid measurement.
+	Distance float64
+	// Quality is the signal strength reported for the measurement.
+	Quality uint8
+	// Flag holds the measurement flags, including the sync bit that marks the start of a new revolution.
+	Flag uint8
+}
+
+// FromHQ converts the fixed point fields of an rplidar_response_measurement_node_hq_t into a Node.
+func FromHQ(angleZQ14 uint16, distMMQ2 uint32, quality, flag uint8) Node {
+	return Node{
+		Angle:    float64(angleZQ14) * angleScaleQ14,
+		Distance: float64(distMMQ2) * distanceScaleQ2,
+		Quality:  quality,
+		Flag:     flag,
+	}
+}
+
+// Point returns the position of the node in millimeters in the lidar's frame, matching the point clouds returned by
+// the camera. The x axis is flipped so that node angles, which the RPLiDAR reports clockwise, map onto the plane.
+func (n Node) Point() geometry.Point {
+	sin, cos := math.Sincos(n.Angle * math.Pi / 180)
+	return geometry.Point{X: -n.Distance * cos, Y: n.Distance * sin}
+}
@@ -0,0 +1,22 @@
+package nodes
+
+import (
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestFromHQ(t *testing.T) {
+	node := FromHQ(1<<14, 4000, 47, 1)
+	test.That(t, node, test.ShouldResemble, Node{Angle: 90, Distance: 1000, Quality: 47, Flag: 1})
+}
+
+func TestPoint(t *testing.T) {
+	pt := Node{Angle: 0, Distance: 1000}.Point()
+	test.That(t, pt.X, test.ShouldAlmostEqual, -1000)
+	test.That(t, pt.Y, test.ShouldAlmostEqual, 0)
+
+	pt = Node{Angle: 90, Distance: 1000}.Point()
+	test.That(t, pt.X, test.ShouldAlmostEqual, 0)
+	test.That(t, pt.Y, test.ShouldAlmostEqual, 1000)
+}
@@ -0,0 +1,198 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/utils"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/occupancy"
+)
+
+const (
+	getOccupancyGridCommand = "get_occupancy_grid"
+
+	// occupancyGridSource is the source name of the occupancy grid image returned by Images.
+	occupancyGridSource = "occupancy_grid"
+
+	// basePollInterval is how often the pose of the base is read, several times per revolution so that the grid is
+	// placed with a recent pose.
+	basePollInterval = 20 * time.Millisecond
+	// baseReadTimeout bounds each read of the pose, so that an unreachable dependency does not hold up the next one.
+	baseReadTimeout = time.Second
+)
+
+// poseReader reads the planar pose of the robot's base in a fixed frame.
+type poseReader func(ctx context.Context) (geometry.Pose, error)
+
+// baseMotion follows the pose of the robot's base, so that the occupancy grid can roll with the lidar.
+type baseMotion struct {
+	read     poseReader
+	mounting geometry.Pose
+
+	mutex sync.Mutex
+	// start is the pose of the lidar at the first reading, which is the frame of the grid.
+	start *geometry.Pose
+	// lidar is the pose of the lidar at the most recent reading, relative to start.
+	lidar       geometry.Pose
+	lastReadErr error
+}
+
+// update records a reading of the pose of the base.
+func (m *baseMotion) update(base geometry.Pose) {
+	lidar := base.Compose(m.mounting)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.start == nil {
+		m.start = &lidar
+	}
+	m.lidar = m.start.Inverse().Compose(lidar)
+}
+
+// pose returns the pose of the lidar in the frame of the grid.
+func (m *baseMotion) pose() geometry.Pose {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lidar
+}
+
+// movementSensorPose returns a poseReader of a movement sensor's position and orientation. The position is measured
+// east and north of the first reading, and the heading is the yaw of the orientation.
+func movementSensorPose(sensor movementsensor.MovementSensor) poseReader {
+	var origin *geo.Point
+	return func(ctx context.Context) (geometry.Pose, error) {
+		position, _, err := sensor.Position(ctx, nil)
+		if err != nil {
+			return geometry.Pose{}, err
+		}
+		orientation, err := sensor.Orientation(ctx, nil)
+		if err != nil {
+			return geometry.Pose{}, err
+		}
+		if origin == nil {
+			origin = position
+		}
+		distanceMM := origin.GreatCircleDistance(position) * 1e6
+		sin, cos := math.Sincos(origin.BearingTo(position) * math.Pi / 180)
+		return geometry.Pose{
+			X:        distanceMM * sin,
+			Y:        distanceMM * cos,
+			ThetaDeg: orientation.EulerAngles().Yaw * 180 / math.Pi,
+		}, nil
+	}
+}
+
+// setupOccupancyGrid creates the occupancy grid when configured.
+func (rp *rplidar) setupOccupancyGrid(conf *Config) {
+	if conf.OccupancyGrid == nil {
+		return
+	}
+	rp.occupancyGrid = occupancy.NewGrid(*conf.OccupancyGrid)
+}
+
+// setupBaseMotion looks up the movement sensor of the occupancy grid, when configured, and starts the goroutine that
+// reads the pose of the base.
+func (rp *rplidar) setupBaseMotion(ctx context.Context, conf *Config, deps resource.Dependencies) error {
+	if conf.OccupancyGrid == nil || conf.OccupancyGrid.MovementSensor == "" {
+		return nil
+	}
+	sensor, err := movementsensor.FromProvider(deps, conf.OccupancyGrid.MovementSensor)
+	if err != nil {
+		return errors.Wrap(err, "could not get the occupancy grid movement sensor")
+	}
+	rp.baseMotion = &baseMotion{read: movementSensorPose(sensor)}
+	if conf.Mounting != nil {
+		rp.baseMotion.mounting = *conf.Mounting
+	}
+
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.baseMotionLoop(ctx)
+	}()
+	return nil
+}
+
+// baseMotionLoop reads the pose of the base until ctx is done.
+func (rp *rplidar) baseMotionLoop(ctx context.Context) {
+	m := rp.baseMotion
+	ticker := time.NewTicker(basePollInterval)
+	defer ticker.Stop()
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, baseReadTimeout)
+		base, err := m.read(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.mutex.Lock()
+			if m.lastReadErr == nil || m.lastReadErr.Error() != err.Error() {
+				rp.logger.Warnf("occupancy grid: failed to read the pose of the base: %v", err)
+			}
+			m.lastReadErr = err
+			m.mutex.Unlock()
+		} else {
+			m.update(base)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateOccupancyGrid traces a filtered revolution into the occupancy grid, placed with the most recent pose of the
+// lidar when the motion of the base is followed.
+func (rp *rplidar) updateOccupancyGrid(scanned []nodes.Node, now time.Time) {
+	if rp.occupancyGrid == nil {
+		return
+	}
+	var pose geometry.Pose
+	if rp.baseMotion != nil {
+		pose = rp.baseMotion.pose()
+	}
+	rp.occupancyGrid.Update(scanned, pose, now)
+}
+
+// occupancyGridImages returns the occupancy grid as a grayscale image, unless filterSourceNames excludes it.
+func (rp *rplidar) occupancyGridImages(filterSourceNames []string) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	if len(filterSourceNames) > 0 {
+		found := false
+		for _, name := range filterSourceNames {
+			found = found || name == occupancyGridSource
+		}
+		if !found {
+			return nil, resource.ResponseMetadata{}, errors.Errorf("unknown source names %v, the only source is %q",
+				filterSourceNames, occupancyGridSource)
+		}
+	}
+
+	snapshot := rp.occupancyGrid.Snapshot()
+	img, err := camera.NamedImageFromImage(snapshot.Image(), occupancyGridSource, utils.MimeTypePNG, data.Annotations{})
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{img}, resource.ResponseMetadata{CapturedAt: snapshot.Timestamp}, nil
+}
+
+// getOccupancyGrid returns the cells of the occupancy grid in the layout of a ROS nav_msgs/OccupancyGrid.
+func (rp *rplidar) getOccupancyGrid(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	if rp.occupancyGrid == nil {
+		return nil, errors.New("occupancy_grid is not configured")
+	}
+	return rp.occupancyGrid.Snapshot().Map(), nil
+}
@@ -0,0 +1,337 @@
+// Package occupancy maintains a local occupancy grid centered on an RPLiDAR, updated from every revolution by
+// tracing the ray to each return. Cells hold the log-odds of being occupied, so that evidence accumulates across
+// revolutions, and decay back to unknown so that the grid follows a changing scene. When the pose of the lidar is
+// known, the grid rolls with it: the cells stay fixed in the frame of the pose, and the grid is shifted by whole
+// cells to stay centered on the lidar.
+package occupancy
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultResolutionMM = 50.
+	defaultSizeMM       = 10000.
+	defaultDecay        = 0.05
+
+	// maxCells bounds the width of the grid, in cells.
+	maxCells = 2000
+
+	// hitLogOdds and missLogOdds are added to a cell for a return in it and for a ray through it.
+	hitLogOdds  = 0.85
+	missLogOdds = -0.4
+	// maxLogOdds clamps the log-odds, so that a cell can change state within a few revolutions.
+	maxLogOdds = 5
+	// unknownLogOdds is the magnitude of the log-odds below which a cell is reported as unknown.
+	unknownLogOdds = 0.2
+)
+
+// Config describes the size and behaviour of the grid. Distances are in millimeters.
+type Config struct {
+	ResolutionMM float64 `json:"resolution_mm,omitempty"`
+	// SizeMM is the width and height of the grid, which is centered on the lidar.
+	SizeMM float64 `json:"size_mm,omitempty"`
+	// Decay is the fraction of the evidence in every cell that is forgotten at each revolution.
+	Decay float64 `json:"decay,omitempty"`
+	// MovementSensor is the name of a movement sensor reporting the pose of the robot's base, which the camera uses
+	// to follow the motion of the lidar. The grid is fixed to the lidar when it is empty.
+	MovementSensor string `json:"movement_sensor,omitempty"`
+}
+
+// Validate checks that the occupancy grid attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.ResolutionMM < 0 {
+		return errors.New("resolution_mm must be positive")
+	}
+	if conf.SizeMM < 0 {
+		return errors.New("size_mm must be positive")
+	}
+	if conf.Decay < 0 || conf.Decay >= 1 {
+		return errors.New("decay must be between 0 and 1")
+	}
+	withDefaults := conf.withDefaults()
+	if withDefaults.cells() > maxCells {
+		return errors.Errorf("size_mm must be at most %d times resolution_mm", maxCells)
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.ResolutionMM == 0 {
+		conf.ResolutionMM = defaultResolutionMM
+	}
+	if conf.SizeMM == 0 {
+		conf.SizeMM = defaultSizeMM
+	}
+	if conf.Decay == 0 {
+		conf.Decay = defaultDecay
+	}
+	return conf
+}
+
+// cells returns the width of the grid in cells.
+func (conf Config) cells() int {
+	return int(math.Ceil(conf.SizeMM / conf.ResolutionMM))
+}
+
+// Grid is a square occupancy grid centered on the lidar. Its frame is the frame of the poses given to Update, which
+// is the frame of the point cloud while the lidar does not move. It is safe for concurrent use.
+type Grid struct {
+	resolutionMM float64
+	decay        float32
+	width        int
+
+	mutex sync.Mutex
+	// logOdds holds the cells row by row, starting from the row with the lowest y and the cell with the lowest x.
+	logOdds []float32
+	// shiftCol and shiftRow are how many cells the grid has been shifted along x and y to follow the lidar.
+	shiftCol, shiftRow int
+	// pose is the pose of the lidar at the most recent update.
+	pose      geometry.Pose
+	timestamp time.Time
+	// marks records the observation of every cell during an update, so that each cell is updated once per revolution
+	// however many rays cross it.
+	marks []int8
+	// shifted receives the cells while the grid is shifted.
+	shifted []float32
+}
+
+// NewGrid creates a grid of unknown cells from a valid config.
+func NewGrid(conf Config) *Grid {
+	conf = conf.withDefaults()
+	width := conf.cells()
+	return &Grid{
+		resolutionMM: conf.ResolutionMM,
+		decay:        float32(conf.Decay),
+		width:        width,
+		logOdds:      make([]float32, width*width),
+		marks:        make([]int8, width*width),
+		shifted:      make([]float32, width*width),
+	}
+}
+
+// originMM returns the corner of the first cell, with the lowest x and y.
+func (g *Grid) originMM() (float64, float64) {
+	half := float64(g.width) * g.resolutionMM / 2
+	return float64(g.shiftCol)*g.resolutionMM - half, float64(g.shiftRow)*g.resolutionMM - half
+}
+
+// cell returns the column and row of the cell holding a point in the frame of the grid.
+func (g *Grid) cell(x, y float64) (int, int) {
+	originX, originY := g.originMM()
+	return int(math.Floor((x - originX) / g.resolutionMM)), int(math.Floor((y - originY) / g.resolutionMM))
+}
+
+func (g *Grid) contains(col, row int) bool {
+	return col >= 0 && col < g.width && row >= 0 && row < g.width
+}
+
+// Update shifts the grid to the lidar at pose and decays every cell, then traces the ray from the lidar to every
+// return of a revolution captured at timestamp. The cells a ray crosses become more likely free, and the cell of its
+// return more likely occupied.
+func (g *Grid) Update(revolution []nodes.Node, pose geometry.Pose, timestamp time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.follow(pose)
+	const miss, hit = 1, 2
+	for i := range g.marks {
+		g.marks[i] = 0
+	}
+	originCol, originRow := g.cell(pose.X, pose.Y)
+	for _, n := range revolution {
+		pt := pose.Transform(n.Point())
+		col, row := g.cell(pt.X, pt.Y)
+		g.trace(originCol, originRow, col, row, func(i int) {
+			if g.marks[i] == 0 {
+				g.marks[i] = miss
+			}
+		})
+		if g.contains(col, row) {
+			g.marks[row*g.width+col] = hit
+		}
+	}
+
+	keep := 1 - g.decay
+	for i, mark := range g.marks {
+		l := g.logOdds[i] * keep
+		switch mark {
+		case miss:
+			l += missLogOdds
+		case hit:
+			l += hitLogOdds
+		}
+		g.logOdds[i] = float32(math.Max(-maxLogOdds, math.Min(maxLogOdds, float64(l))))
+	}
+	g.timestamp = timestamp
+}
+
+// follow shifts the grid by whole cells to center it on the lidar at pose. The cells keep their place in the frame of
+// the grid, so that evidence is not blurred by resampling; cells leaving the grid are forgotten and cells entering it
+// are unknown.
+func (g *Grid) follow(pose geometry.Pose) {
+	g.pose = pose
+	shiftCol, shiftRow := int(math.Round(pose.X/g.resolutionMM)), int(math.Round(pose.Y/g.resolutionMM))
+	dCol, dRow := shiftCol-g.shiftCol, shiftRow-g.shiftRow
+	if dCol == 0 && dRow == 0 {
+		return
+	}
+	for row := 0; row < g.width; row++ {
+		for col := 0; col < g.width; col++ {
+			var l float32
+			if g.contains(col+dCol, row+dRow) {
+				l = g.logOdds[(row+dRow)*g.width+col+dCol]
+			}
+			g.shifted[row*g.width+col] = l
+		}
+	}
+	g.logOdds, g.shifted = g.shifted, g.logOdds
+	g.shiftCol, g.shiftRow = shiftCol, shiftRow
+}
+
+// trace calls visit with the index of every cell from (col0, row0) up to, but excluding, (col1, row1), stopping at
+// the edge of the grid.
+func (g *Grid) trace(col0, row0, col1, row1 int, visit func(int)) {
+	dx, dy := abs(col1-col0), -abs(row1-row0)
+	stepX, stepY := sign(col1-col0), sign(row1-row0)
+	err := dx + dy
+	for col, row := col0, row0; col != col1 || row != row1; {
+		if !g.contains(col, row) {
+			return
+		}
+		visit(row*g.width + col)
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			col += stepX
+		} else {
+			err += dx
+			row += stepY
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// Width returns the width and height of the grid, in cells.
+func (g *Grid) Width() int {
+	return g.width
+}
+
+// PixelBox returns the pixels of the grid image covering points in the frame of the point cloud of the most recent
+// update, clipped to the image, and whether any of them is inside it.
+func (g *Grid) PixelBox(points []geometry.Point) (image.Rectangle, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	var box image.Rectangle
+	for i, pt := range points {
+		pt = g.pose.Transform(pt)
+		col, row := g.cell(pt.X, pt.Y)
+		// Rows are flipped in the image, so that +y is up.
+		pixel := image.Rect(col, g.width-1-row, col+1, g.width-row)
+		if i == 0 {
+			box = pixel
+		} else {
+			box = box.Union(pixel)
+		}
+	}
+	box = box.Intersect(image.Rect(0, 0, g.width, g.width))
+	return box, !box.Empty()
+}
+
+// Snapshot is a copy of the grid in the layout of a ROS nav_msgs/OccupancyGrid.
+type Snapshot struct {
+	ResolutionMM float64
+	// Width is the number of cells in each row and column.
+	Width int
+	// OriginMM is the position of the corner of the first cell, with the lowest x and y.
+	OriginMM [2]float64
+	// Pose is the pose of the lidar in the frame of the grid at the most recent update.
+	Pose geometry.Pose
+	// Cells are row by row from OriginMM, each the probability of being occupied from 0 to 100, or -1 when unknown.
+	Cells     []int8
+	Timestamp time.Time
+}
+
+// Snapshot returns a copy of the grid.
+func (g *Grid) Snapshot() Snapshot {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	originX, originY := g.originMM()
+	s := Snapshot{
+		ResolutionMM: g.resolutionMM,
+		Width:        g.width,
+		OriginMM:     [2]float64{originX, originY},
+		Pose:         g.pose,
+		Cells:        make([]int8, len(g.logOdds)),
+		Timestamp:    g.timestamp,
+	}
+	for i, l := range g.logOdds {
+		if math.Abs(float64(l)) < unknownLogOdds {
+			s.Cells[i] = -1
+			continue
+		}
+		s.Cells[i] = int8(math.Round(100 / (1 + math.Exp(-float64(l)))))
+	}
+	return s
+}
+
+// Image renders the snapshot as a grayscale image with +y up, in the convention of ROS maps: occupied cells are
+// black, free cells white and unknown cells gray.
+func (s Snapshot) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, s.Width, s.Width))
+	for row := 0; row < s.Width; row++ {
+		for col := 0; col < s.Width; col++ {
+			value := uint8(205)
+			if cell := s.Cells[row*s.Width+col]; cell >= 0 {
+				value = uint8(255 - int(cell)*255/100)
+			}
+			img.SetGray(col, s.Width-1-row, color.Gray{Y: value})
+		}
+	}
+	return img
+}
+
+// Map returns the snapshot in a layout suitable for a DoCommand response.
+func (s Snapshot) Map() map[string]interface{} {
+	cells := make([]interface{}, len(s.Cells))
+	for i, cell := range s.Cells {
+		cells[i] = float64(cell)
+	}
+	pose := map[string]interface{}{"x_mm": s.Pose.X, "y_mm": s.Pose.Y, "theta_deg": s.Pose.ThetaDeg}
+	return map[string]interface{}{
+		"timestamp":     s.Timestamp.Format(time.RFC3339Nano),
+		"resolution_mm": s.ResolutionMM,
+		"width":         float64(s.Width),
+		"height":        float64(s.Width),
+		"origin_mm":     []interface{}{s.OriginMM[0], s.OriginMM[1]},
+		"pose":          pose,
+		"cells":         cells,
+	}
+}
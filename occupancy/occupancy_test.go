@@ -0,0 +1,155 @@
+package occupancy
+
+import (
+	"image"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{ResolutionMM: -1}, "resolution_mm must be positive"},
+		{Config{SizeMM: -1}, "size_mm must be positive"},
+		{Config{Decay: 1}, "decay must be between 0 and 1"},
+		{Config{ResolutionMM: 1, SizeMM: 10000}, "size_mm must be at most 2000 times resolution_mm"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// wall returns the returns of a wall parallel to the x axis at y, between 45 and 135 degrees.
+func wall(y float64) []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 45.; angle <= 135; angle += 0.5 {
+		revolution = append(revolution, nodes.Node{Angle: angle, Distance: y / math.Sin(angle*math.Pi/180)})
+	}
+	return revolution
+}
+
+// cellAt returns the value of the cell holding a point.
+func cellAt(s Snapshot, x, y float64) int8 {
+	col := int(math.Floor((x - s.OriginMM[0]) / s.ResolutionMM))
+	row := int(math.Floor((y - s.OriginMM[1]) / s.ResolutionMM))
+	return s.Cells[row*s.Width+col]
+}
+
+func TestGrid(t *testing.T) {
+	g := NewGrid(Config{ResolutionMM: 50, SizeMM: 4000})
+	now := time.Unix(100, 0)
+
+	s := g.Snapshot()
+	test.That(t, s.Width, test.ShouldEqual, 80)
+	test.That(t, s.OriginMM, test.ShouldResemble, [2]float64{-2000, -2000})
+	test.That(t, cellAt(s, 0, 1025), test.ShouldEqual, -1)
+
+	for i := 0; i < 5; i++ {
+		g.Update(wall(1025), geometry.Pose{}, now)
+	}
+	s = g.Snapshot()
+	test.That(t, s.Timestamp, test.ShouldEqual, now)
+	test.That(t, cellAt(s, 0, 1025), test.ShouldBeGreaterThan, 80)
+	test.That(t, cellAt(s, 500, 1025), test.ShouldBeGreaterThan, 80)
+	test.That(t, cellAt(s, 0, 500), test.ShouldBeLessThan, 20)
+	test.That(t, cellAt(s, 0, 500), test.ShouldBeGreaterThanOrEqualTo, 0)
+	// Behind the wall and behind the lidar nothing was seen.
+	test.That(t, cellAt(s, 0, 1500), test.ShouldEqual, -1)
+	test.That(t, cellAt(s, 0, -1000), test.ShouldEqual, -1)
+
+	img := s.Image()
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 80)
+	// +y is up in the image, so the wall is in the upper half.
+	test.That(t, img.GrayAt(40, 80-1-60).Y, test.ShouldBeLessThan, 50)
+	test.That(t, img.GrayAt(40, 80-1-50).Y, test.ShouldBeGreaterThan, 200)
+	test.That(t, img.GrayAt(40, 80-1-10).Y, test.ShouldEqual, 205)
+
+	m := s.Map()
+	test.That(t, m["width"], test.ShouldEqual, 80.)
+	test.That(t, len(m["cells"].([]interface{})), test.ShouldEqual, 80*80)
+
+	t.Run("returns beyond the grid clear the cells up to its edge", func(t *testing.T) {
+		g.Update(wall(5000), geometry.Pose{}, now)
+		s := g.Snapshot()
+		test.That(t, cellAt(s, 0, 1500), test.ShouldBeLessThan, 50)
+	})
+
+	t.Run("cells decay back to unknown", func(t *testing.T) {
+		for i := 0; i < 200; i++ {
+			g.Update(nil, geometry.Pose{}, now)
+		}
+		s := g.Snapshot()
+		test.That(t, cellAt(s, 0, 1025), test.ShouldEqual, -1)
+		test.That(t, cellAt(s, 0, 500), test.ShouldEqual, -1)
+	})
+}
+
+// nodeAt returns the return of a point in the frame of the point cloud.
+func nodeAt(x, y float64) nodes.Node {
+	angle := math.Atan2(y, -x) * 180 / math.Pi
+	if angle < 0 {
+		angle += 360
+	}
+	return nodes.Node{Angle: angle, Distance: math.Hypot(x, y)}
+}
+
+func TestRollingGrid(t *testing.T) {
+	g := NewGrid(Config{ResolutionMM: 50, SizeMM: 4000})
+	now := time.Unix(100, 0)
+	for i := 0; i < 5; i++ {
+		g.Update(wall(1025), geometry.Pose{}, now)
+	}
+
+	// Half a meter forward and turned a quarter turn to the left, the wall is ahead of the lidar along its x axis.
+	moved := geometry.Pose{Y: 500, ThetaDeg: 90}
+	var turned []nodes.Node
+	for y := -500.; y <= 500; y += 10 {
+		turned = append(turned, nodeAt(525, y))
+	}
+	for i := 0; i < 5; i++ {
+		g.Update(turned, moved, now)
+	}
+
+	s := g.Snapshot()
+	test.That(t, s.OriginMM, test.ShouldResemble, [2]float64{-2000, -1500})
+	test.That(t, s.Pose, test.ShouldResemble, moved)
+	test.That(t, s.Map()["pose"], test.ShouldResemble, map[string]interface{}{"x_mm": 0., "y_mm": 500., "theta_deg": 90.})
+	// Both views of the wall land on the same cells, and the space between the lidar and the wall stays free.
+	test.That(t, cellAt(s, 0, 1025), test.ShouldBeGreaterThan, 90)
+	test.That(t, cellAt(s, -400, 1025), test.ShouldBeGreaterThan, 80)
+	test.That(t, cellAt(s, 0, 800), test.ShouldBeLessThan, 20)
+	test.That(t, cellAt(s, 0, 800), test.ShouldBeGreaterThanOrEqualTo, 0)
+	// The rows behind the lidar that left the grid were forgotten, and those entering it are unknown.
+	test.That(t, cellAt(s, 0, 2400), test.ShouldEqual, -1)
+
+	// Points of the point cloud are placed with the pose of the most recent update.
+	box, ok := g.PixelBox([]geometry.Point{{X: 525, Y: 0}})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, box, test.ShouldResemble, image.Rect(40, 80-1-50, 41, 80-50))
+}
+
+func TestPixelBox(t *testing.T) {
+	g := NewGrid(Config{ResolutionMM: 100, SizeMM: 4000})
+	test.That(t, g.Width(), test.ShouldEqual, 40)
+
+	box, ok := g.PixelBox([]geometry.Point{{X: 50, Y: 1050}, {X: 250, Y: 1150}})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, box, test.ShouldResemble, image.Rect(20, 8, 23, 10))
+
+	box, ok = g.PixelBox([]geometry.Point{{X: 1950, Y: 0}, {X: 2500, Y: 0}})
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, box, test.ShouldResemble, image.Rect(39, 19, 40, 20))
+
+	_, ok = g.PixelBox([]geometry.Point{{X: 5000, Y: 0}})
+	test.That(t, ok, test.ShouldBeFalse)
+}
@@ -0,0 +1,138 @@
+package rplidar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	geo "github.com/kellydunn/golang-geo"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/occupancy"
+)
+
+func TestOccupancyGrid(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getOccupancyGridCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "occupancy_grid is not configured")
+
+	conf := &Config{OccupancyGrid: &occupancy.Config{Decay: 2}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid occupancy_grid")
+
+	conf.OccupancyGrid = &occupancy.Config{ResolutionMM: 100, SizeMM: 4000}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	rp.setupOccupancyGrid(conf)
+
+	now := time.Unix(100, 0)
+	rp.processRevolution([]nodes.Node{{Angle: 91, Distance: 1050}}, now, 0)
+
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getOccupancyGridCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["width"], test.ShouldEqual, 40.)
+	test.That(t, resp["origin_mm"], test.ShouldResemble, []interface{}{-2000., -2000.})
+	cells := resp["cells"].([]interface{})
+	// The return is in row 30 and column 20, and the ray from the lidar crosses the column below it.
+	test.That(t, cells[30*40+20], test.ShouldBeGreaterThan, 50.)
+	test.That(t, cells[25*40+20], test.ShouldBeLessThan, 50.)
+	test.That(t, cells[10*40+20], test.ShouldEqual, -1.)
+
+	images, metadata, err := rp.Images(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(images), test.ShouldEqual, 1)
+	test.That(t, images[0].SourceName, test.ShouldEqual, occupancyGridSource)
+	test.That(t, metadata.CapturedAt, test.ShouldEqual, now)
+	img, err := images[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds().Dx(), test.ShouldEqual, 40)
+
+	_, _, err = rp.Images(ctx, []string{occupancyGridSource}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	_, _, err = rp.Images(ctx, []string{"color"}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	props, err := rp.Properties(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, props.SupportsPCD, test.ShouldBeTrue)
+	test.That(t, props.MimeTypes, test.ShouldResemble, []string{utils.MimeTypePNG})
+}
+
+func TestBaseMotion(t *testing.T) {
+	ctx := context.Background()
+
+	conf := &Config{OccupancyGrid: &occupancy.Config{MovementSensor: "odometry"}}
+	deps, _, err := conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"odometry"})
+
+	t.Run("the pose of the lidar is relative to its first pose", func(t *testing.T) {
+		m := &baseMotion{mounting: geometry.Pose{X: 100}}
+		m.update(geometry.Pose{X: 500, Y: 500, ThetaDeg: 90})
+		test.That(t, m.pose(), test.ShouldResemble, geometry.Pose{})
+
+		// Turning the base in place a quarter turn to the right swings the lidar, 100 mm ahead of it, back and to the
+		// right of where it started.
+		m.update(geometry.Pose{X: 500, Y: 500})
+		pose := m.pose()
+		test.That(t, pose.X, test.ShouldAlmostEqual, -100)
+		test.That(t, pose.Y, test.ShouldAlmostEqual, -100)
+		test.That(t, pose.ThetaDeg, test.ShouldAlmostEqual, -90)
+	})
+
+	t.Run("movement sensor readings", func(t *testing.T) {
+		sensor := inject.NewMovementSensor("odometry")
+		position := geo.NewPoint(0, 0)
+		sensor.PositionFunc = func(context.Context, map[string]interface{}) (*geo.Point, float64, error) {
+			return position, 0, nil
+		}
+		sensor.OrientationFunc = func(context.Context, map[string]interface{}) (spatialmath.Orientation, error) {
+			return &spatialmath.EulerAngles{Yaw: math.Pi / 2}, nil
+		}
+		read := movementSensorPose(sensor)
+
+		pose, err := read(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pose.X, test.ShouldAlmostEqual, 0)
+		test.That(t, pose.Y, test.ShouldAlmostEqual, 0)
+		test.That(t, pose.ThetaDeg, test.ShouldAlmostEqual, 90)
+
+		// One meter to the east of the first reading.
+		position = position.PointAtDistanceAndBearing(0.001, 90)
+		pose, err = read(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pose.X, test.ShouldAlmostEqual, 1000, 1)
+		test.That(t, pose.Y, test.ShouldAlmostEqual, 0, 1)
+	})
+
+	t.Run("the grid follows the lidar", func(t *testing.T) {
+		rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+		rp.setupOccupancyGrid(&Config{OccupancyGrid: &occupancy.Config{ResolutionMM: 100, SizeMM: 4000}})
+		rp.baseMotion = &baseMotion{}
+		rp.baseMotion.update(geometry.Pose{})
+		rp.baseMotion.update(geometry.Pose{Y: 1000, ThetaDeg: 90})
+
+		rp.processRevolution([]nodes.Node{{Angle: 0, Distance: 1050}}, time.Unix(100, 0), 0)
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getOccupancyGridCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["origin_mm"], test.ShouldResemble, []interface{}{-2000., -1000.})
+		test.That(t, resp["pose"], test.ShouldResemble, map[string]interface{}{"x_mm": 0., "y_mm": 1000., "theta_deg": 90.})
+		// The x axis of the lidar points along +y of the grid, so the return at 0 degrees, on the lidar's -x axis, lands
+		// at y = -50 mm in row 9, and its ray clears the rows up to the lidar in row 20.
+		cells := resp["cells"].([]interface{})
+		test.That(t, cells[9*40+20], test.ShouldBeGreaterThan, 50.)
+		test.That(t, cells[15*40+20], test.ShouldBeLessThan, 50.)
+		test.That(t, cells[5*40+20], test.ShouldEqual, -1.)
+	})
+}
@@ -0,0 +1,278 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/r3"
+	geo "github.com/kellydunn/golang-geo"
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/odometry"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	getOdometryCommand   = "get_odometry"
+	resetOdometryCommand = "reset_odometry"
+
+	// odometryPollInterval is how often the camera is polled for new revolutions. Revolutions are queued on the
+	// camera in between, so it only bounds the latency of the estimate.
+	odometryPollInterval = 20 * time.Millisecond
+	odometryQueueSize    = 10
+)
+
+// OdometryModel is the model of the movement sensor that estimates the motion of an RPLiDAR camera by matching
+// consecutive revolutions.
+var OdometryModel = resource.NewModel("viam", "lidar", "rplidar-odometry")
+
+func init() {
+	resource.RegisterComponent(
+		movementsensor.API,
+		OdometryModel,
+		resource.Registration[movementsensor.MovementSensor, *OdometryConfig]{Constructor: newOdometrySensor},
+	)
+}
+
+// OdometryConfig describes how to configure the odometry movement sensor.
+type OdometryConfig struct {
+	// Camera is the name of the rplidar camera whose revolutions are matched.
+	Camera              string  `json:"camera"`
+	MaxIterations       int     `json:"max_iterations,omitempty"`
+	MaxCorrespondenceMM float64 `json:"max_correspondence_mm,omitempty"`
+	MinMatchRatio       float64 `json:"min_match_ratio,omitempty"`
+	MaxPoints           int     `json:"max_points,omitempty"`
+}
+
+func (conf *OdometryConfig) odometry() odometry.Config {
+	return odometry.Config{
+		MaxIterations:       conf.MaxIterations,
+		MaxCorrespondenceMM: conf.MaxCorrespondenceMM,
+		MinMatchRatio:       conf.MinMatchRatio,
+		MaxPoints:           conf.MaxPoints,
+	}
+}
+
+// Validate checks that the camera is set and depends on it.
+func (conf *OdometryConfig) Validate(path string) ([]string, []string, error) {
+	if conf.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	odometryConf := conf.odometry()
+	if err := odometryConf.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return []string{conf.Camera}, nil, nil
+}
+
+// odometrySensor estimates the planar motion of an rplidar camera from its revolutions, which it receives through a
+// DoCommand subscription so that it works whether or not the camera runs in the same module.
+type odometrySensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+
+	// camera is only used through DoCommand.
+	camera  resource.Resource
+	tracker *odometry.Tracker
+
+	cancelFunc func()
+	workers    sync.WaitGroup
+	logger     logging.Logger
+}
+
+func newOdometrySensor(
+	_ context.Context,
+	deps resource.Dependencies,
+	c resource.Config,
+	logger logging.Logger,
+) (movementsensor.MovementSensor, error) {
+	conf, err := resource.NativeConfig[*OdometryConfig](c)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := resource.FromProvider[resource.Resource](deps, camera.Named(conf.Camera))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &odometrySensor{
+		Named:      c.ResourceName().AsNamed(),
+		camera:     cam,
+		tracker:    odometry.NewTracker(conf.odometry()),
+		cancelFunc: cancel,
+		logger:     logger,
+	}
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		s.trackLoop(ctx)
+	}()
+	return s, nil
+}
+
+// trackLoop feeds every revolution of the camera to the tracker until ctx is done. The subscription is created again
+// whenever polling fails, such as when the camera is rebuilt.
+func (s *odometrySensor) trackLoop(ctx context.Context) {
+	ticker := time.NewTicker(odometryPollInterval)
+	defer ticker.Stop()
+
+	var subscriptionID interface{}
+	defer func() {
+		if subscriptionID == nil {
+			return
+		}
+		unsubscribeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if _, err := s.camera.DoCommand(unsubscribeCtx, map[string]interface{}{
+			unsubscribeCommand: map[string]interface{}{"subscription_id": subscriptionID},
+		}); err != nil {
+			s.logger.Debugf("issue unsubscribing from the camera: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if subscriptionID == nil {
+			resp, err := s.camera.DoCommand(ctx, map[string]interface{}{
+				subscribeCommand: map[string]interface{}{"queue_size": float64(odometryQueueSize)},
+			})
+			if err != nil {
+				s.logger.Debugf("issue subscribing to the camera: %v", err)
+				continue
+			}
+			subscriptionID = resp["subscription_id"]
+		}
+
+		resp, err := s.camera.DoCommand(ctx, map[string]interface{}{
+			getRevolutionsCommand: map[string]interface{}{"subscription_id": subscriptionID},
+		})
+		if err != nil {
+			s.logger.Debugf("issue getting revolutions from the camera: %v", err)
+			subscriptionID = nil
+			continue
+		}
+		revolutions, _ := resp["revolutions"].([]interface{})
+		for _, revolution := range revolutions {
+			revMap, _ := revolution.(map[string]interface{})
+			rev, err := publisher.RevolutionFromMap(revMap)
+			if err != nil {
+				s.logger.Debugf("issue decoding revolution: %v", err)
+				continue
+			}
+			points := make([]geometry.Point, 0, len(rev.Nodes))
+			for _, n := range rev.Nodes {
+				points = append(points, n.Point())
+			}
+			if err := s.tracker.Update(points, rev.Timestamp); err != nil {
+				s.logger.Debugf("could not match revolution: %v", err)
+			}
+		}
+	}
+}
+
+// Position returns the position of the lidar relative to where it started, as a point at that distance and bearing
+// from latitude and longitude 0, taking the y axis of the starting frame as north.
+func (s *odometrySensor) Position(context.Context, map[string]interface{}) (*geo.Point, float64, error) {
+	pose := s.tracker.State().Pose
+	distanceKM := math.Hypot(pose.X, pose.Y) / 1e6
+	bearing := math.Atan2(pose.X, pose.Y) * 180 / math.Pi
+	return geo.NewPoint(0, 0).PointAtDistanceAndBearing(distanceKM, bearing), 0, nil
+}
+
+// LinearVelocity returns the velocity of the lidar in its own frame, in meters per second.
+func (s *odometrySensor) LinearVelocity(context.Context, map[string]interface{}) (r3.Vector, error) {
+	velocity := s.tracker.State().Velocity
+	return r3.Vector{X: velocity.X / 1000, Y: velocity.Y / 1000}, nil
+}
+
+// AngularVelocity returns the rate of turn of the lidar, in degrees per second.
+func (s *odometrySensor) AngularVelocity(context.Context, map[string]interface{}) (spatialmath.AngularVelocity, error) {
+	return spatialmath.AngularVelocity{Z: s.tracker.State().Velocity.ThetaDeg}, nil
+}
+
+// Orientation returns the heading of the lidar relative to its starting heading.
+func (s *odometrySensor) Orientation(context.Context, map[string]interface{}) (spatialmath.Orientation, error) {
+	return &spatialmath.EulerAngles{Yaw: s.tracker.State().Pose.ThetaDeg * math.Pi / 180}, nil
+}
+
+// LinearAcceleration is not supported.
+func (s *odometrySensor) LinearAcceleration(context.Context, map[string]interface{}) (r3.Vector, error) {
+	return r3.Vector{}, movementsensor.ErrMethodUnimplementedLinearAcceleration
+}
+
+// CompassHeading is not supported, since the heading is only known relative to the starting heading.
+func (s *odometrySensor) CompassHeading(context.Context, map[string]interface{}) (float64, error) {
+	return 0, movementsensor.ErrMethodUnimplementedCompassHeading
+}
+
+// Properties reports the supported measurements.
+func (s *odometrySensor) Properties(context.Context, map[string]interface{}) (*movementsensor.Properties, error) {
+	return &movementsensor.Properties{
+		PositionSupported:        true,
+		OrientationSupported:     true,
+		LinearVelocitySupported:  true,
+		AngularVelocitySupported: true,
+	}, nil
+}
+
+// Accuracy is not estimated.
+func (s *odometrySensor) Accuracy(context.Context, map[string]interface{}) (*movementsensor.Accuracy, error) {
+	return movementsensor.UnimplementedOptionalAccuracies(), nil
+}
+
+// Readings returns the supported measurements.
+func (s *odometrySensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	return movementsensor.DefaultAPIReadings(ctx, s, extra)
+}
+
+// DoCommand returns the planar estimate with {"get_odometry": true}, and moves the pose back to the origin with
+// {"reset_odometry": true}.
+func (s *odometrySensor) DoCommand(_ context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, ok := cmd[resetOdometryCommand]; ok {
+		s.tracker.Reset()
+		return map[string]interface{}{}, nil
+	}
+	if _, ok := cmd[getOdometryCommand]; !ok {
+		return nil, errors.Errorf("unknown command, supported commands are %v", []string{getOdometryCommand, resetOdometryCommand})
+	}
+
+	state := s.tracker.State()
+	out := map[string]interface{}{
+		"x_mm":                 state.Pose.X,
+		"y_mm":                 state.Pose.Y,
+		"theta_deg":            state.Pose.ThetaDeg,
+		"velocity_x_mm_s":      state.Velocity.X,
+		"velocity_y_mm_s":      state.Velocity.Y,
+		"angular_velocity_deg": state.Velocity.ThetaDeg,
+		"revolutions_matched":  float64(state.Revolutions),
+		"match_failures":       float64(state.Failures),
+		"rms_error_mm":         state.LastMatch.RMSErrorMM,
+		"match_ratio":          state.LastMatch.MatchRatio,
+	}
+	if !state.Timestamp.IsZero() {
+		out["timestamp"] = state.Timestamp.Format(time.RFC3339Nano)
+	}
+	return out, nil
+}
+
+// Close stops tracking and removes the subscription from the camera.
+func (s *odometrySensor) Close(context.Context) error {
+	s.cancelFunc()
+	s.workers.Wait()
+	return nil
+}
@@ -0,0 +1,313 @@
+// Package odometry estimates the planar motion of an RPLiDAR by matching consecutive revolutions with the iterative
+// closest point algorithm, using point-to-line distances. All distances are in millimeters.
+package odometry
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+)
+
+const (
+	defaultMaxIterations       = 30
+	defaultMaxCorrespondenceMM = 300.
+	defaultMinMatchRatio       = 0.3
+	defaultMaxPoints           = 720
+
+	// convergenceMM and convergenceDeg are the changes of the estimate below which the iterations stop.
+	convergenceMM  = 0.01
+	convergenceDeg = 0.001
+)
+
+// Config describes how revolutions are matched.
+type Config struct {
+	MaxIterations int `json:"max_iterations,omitempty"`
+	// MaxCorrespondenceMM is the largest distance between matched points. It bounds the motion between revolutions
+	// that can be recovered when the motion is not steady.
+	MaxCorrespondenceMM float64 `json:"max_correspondence_mm,omitempty"`
+	// MinMatchRatio is the fraction of the points of a revolution that must be matched for the match to be used.
+	MinMatchRatio float64 `json:"min_match_ratio,omitempty"`
+	// MaxPoints is the number of points of a revolution that are matched. Denser revolutions are subsampled.
+	MaxPoints int `json:"max_points,omitempty"`
+}
+
+// Validate checks that the odometry attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.MaxIterations < 0 {
+		return errors.New("max_iterations must be positive")
+	}
+	if conf.MaxCorrespondenceMM < 0 {
+		return errors.New("max_correspondence_mm must be positive")
+	}
+	if conf.MinMatchRatio < 0 || conf.MinMatchRatio > 1 {
+		return errors.New("min_match_ratio must be between 0 and 1")
+	}
+	if conf.MaxPoints < 0 {
+		return errors.New("max_points must be positive")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.MaxIterations == 0 {
+		conf.MaxIterations = defaultMaxIterations
+	}
+	if conf.MaxCorrespondenceMM == 0 {
+		conf.MaxCorrespondenceMM = defaultMaxCorrespondenceMM
+	}
+	if conf.MinMatchRatio == 0 {
+		conf.MinMatchRatio = defaultMinMatchRatio
+	}
+	if conf.MaxPoints == 0 {
+		conf.MaxPoints = defaultMaxPoints
+	}
+	return conf
+}
+
+// Match is the result of matching a revolution against a reference revolution.
+type Match struct {
+	// Transform maps points of the revolution into the frame of the reference, i.e. it is the pose of the lidar
+	// when it captured the revolution, relative to its pose when it captured the reference.
+	Transform  geometry.Pose
+	Iterations int
+	// RMSErrorMM is the root mean square distance between matched points.
+	RMSErrorMM float64
+	// MatchRatio is the fraction of the points of the revolution that were matched.
+	MatchRatio float64
+}
+
+// grid indexes the points of a reference revolution in square cells, so that the points near a position are found
+// without a full search.
+type grid struct {
+	cellMM float64
+	points []geometry.Point
+	// normals are the unit normals of the reference at each point, estimated from its neighbors in angle order.
+	normals []geometry.Point
+	cells   map[[2]int][]int
+}
+
+func newGrid(points []geometry.Point, cellMM float64) *grid {
+	g := &grid{
+		cellMM:  cellMM,
+		points:  points,
+		normals: make([]geometry.Point, len(points)),
+		cells:   make(map[[2]int][]int, len(points)),
+	}
+	for i, pt := range points {
+		previous, next := points[(i+len(points)-1)%len(points)], points[(i+1)%len(points)]
+		// Neighbors across a range jump belong to another surface, so the point's own side is used instead.
+		if math.Hypot(previous.X-pt.X, previous.Y-pt.Y) > cellMM {
+			previous = pt
+		}
+		if math.Hypot(next.X-pt.X, next.Y-pt.Y) > cellMM {
+			next = pt
+		}
+		if dx, dy := next.X-previous.X, next.Y-previous.Y; dx != 0 || dy != 0 {
+			length := math.Hypot(dx, dy)
+			g.normals[i] = geometry.Point{X: -dy / length, Y: dx / length}
+		}
+
+		cell := g.cell(pt)
+		g.cells[cell] = append(g.cells[cell], i)
+	}
+	return g
+}
+
+func (g *grid) cell(pt geometry.Point) [2]int {
+	return [2]int{int(math.Floor(pt.X / g.cellMM)), int(math.Floor(pt.Y / g.cellMM))}
+}
+
+// nearest returns the index of the reference point closest to pt, if one is within the cell size.
+func (g *grid) nearest(pt geometry.Point) (int, float64, bool) {
+	center := g.cell(pt)
+	nearest, nearestDistance := -1, g.cellMM
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			for _, i := range g.cells[[2]int{center[0] + dx, center[1] + dy}] {
+				if d := math.Hypot(g.points[i].X-pt.X, g.points[i].Y-pt.Y); d <= nearestDistance {
+					nearest, nearestDistance = i, d
+				}
+			}
+		}
+	}
+	return nearest, nearestDistance, nearest >= 0
+}
+
+// subsample returns at most max points, evenly spaced through points.
+func subsample(points []geometry.Point, max int) []geometry.Point {
+	if len(points) <= max {
+		return points
+	}
+	sampled := make([]geometry.Point, 0, max)
+	for i := 0; i < max; i++ {
+		sampled = append(sampled, points[i*len(points)/max])
+	}
+	return sampled
+}
+
+// MatchRevolutions finds the transform that best maps the points of a revolution onto the surfaces of a reference
+// revolution, starting from a guess. Both revolutions must be in angle order. Points are matched to the line through
+// their closest reference point, rather than to the point itself, so that the sparse returns of a wall do not hold
+// the match back. It fails when too few points can be matched, such as in an open space or after a sudden motion
+// larger than the correspondence distance.
+func MatchRevolutions(reference, revolution []geometry.Point, guess geometry.Pose, conf Config) (Match, error) {
+	conf = conf.withDefaults()
+	revolution = subsample(revolution, conf.MaxPoints)
+	if len(reference) == 0 || len(revolution) == 0 {
+		return Match{}, errors.New("cannot match empty revolutions")
+	}
+	index := newGrid(reference, conf.MaxCorrespondenceMM)
+
+	match := Match{Transform: guess}
+	for match.Iterations < conf.MaxIterations {
+		match.Iterations++
+
+		// Accumulate the normal equations of the point-to-line distances, linearized for a small motion of the
+		// transformed points: a translation (x, y) and a rotation theta about the origin of the reference.
+		var ata [3][3]float64
+		var atb [3]float64
+		var matched int
+		var sumSquares float64
+		for _, pt := range revolution {
+			moved := match.Transform.Transform(pt)
+			i, _, ok := index.nearest(moved)
+			if !ok || index.normals[i] == (geometry.Point{}) {
+				continue
+			}
+			n := index.normals[i]
+			residual := (moved.X-index.points[i].X)*n.X + (moved.Y-index.points[i].Y)*n.Y
+			row := [3]float64{n.X, n.Y, moved.X*n.Y - moved.Y*n.X}
+			for r := 0; r < 3; r++ {
+				for c := 0; c < 3; c++ {
+					ata[r][c] += row[r] * row[c]
+				}
+				atb[r] -= row[r] * residual
+			}
+			matched++
+			sumSquares += residual * residual
+		}
+		match.MatchRatio = float64(matched) / float64(len(revolution))
+		if matched < 3 || match.MatchRatio < conf.MinMatchRatio {
+			return match, errors.Errorf("only %.0f%% of the points could be matched", match.MatchRatio*100)
+		}
+		match.RMSErrorMM = math.Sqrt(sumSquares / float64(matched))
+
+		step := solve(ata, atb)
+		delta := geometry.Pose{X: step[0], Y: step[1], ThetaDeg: step[2] * 180 / math.Pi}
+		match.Transform = delta.Compose(match.Transform)
+		if math.Hypot(delta.X, delta.Y) < convergenceMM && math.Abs(delta.ThetaDeg) < convergenceDeg {
+			break
+		}
+	}
+	return match, nil
+}
+
+// solve solves the 3x3 normal equations a x = b. A small damping keeps directions the revolution does not
+// constrain, such as along a corridor, at zero instead of failing.
+func solve(a [3][3]float64, b [3]float64) [3]float64 {
+	damping := 1e-9 * (a[0][0] + a[1][1] + a[2][2])
+	for i := 0; i < 3; i++ {
+		a[i][i] += damping
+	}
+	det := a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) -
+		a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) +
+		a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+	if det == 0 {
+		return [3]float64{}
+	}
+	var x [3]float64
+	for col := 0; col < 3; col++ {
+		// Cramer's rule: replace the column with b.
+		m := a
+		for row := 0; row < 3; row++ {
+			m[row][col] = b[row]
+		}
+		x[col] = (m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+			m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+			m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])) / det
+	}
+	return x
+}
+
+// State is the estimated motion of the lidar since the tracker started.
+type State struct {
+	// Pose is the pose of the lidar in the frame of the first revolution.
+	Pose geometry.Pose
+	// Velocity is in millimeters and degrees per second, in the frame of the lidar.
+	Velocity  geometry.Pose
+	Timestamp time.Time
+	LastMatch Match
+	// Revolutions is the number of revolutions matched, and Failures the number that could not be matched.
+	Revolutions uint64
+	Failures    uint64
+}
+
+// Tracker integrates the motion between consecutive revolutions into a pose. It is safe for concurrent use.
+type Tracker struct {
+	conf Config
+
+	mutex     sync.Mutex
+	state     State
+	reference []geometry.Point
+}
+
+// NewTracker creates a tracker at the origin, which must be given a valid config.
+func NewTracker(conf Config) *Tracker {
+	return &Tracker{conf: conf}
+}
+
+// Update matches a revolution captured at timestamp against the previous one and moves the pose by the motion
+// between them. The motion since the previous revolution is assumed to continue at the same velocity, which gives
+// the initial guess. When the match fails, the pose is kept and the revolution becomes the new reference.
+func (t *Tracker) Update(revolution []geometry.Point, timestamp time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	reference := t.reference
+	previous := t.state.Timestamp
+	t.reference, t.state.Timestamp = revolution, timestamp
+	if reference == nil {
+		return nil
+	}
+
+	dt := timestamp.Sub(previous).Seconds()
+	if dt <= 0 {
+		return errors.New("revolutions must be given in time order")
+	}
+	guess := geometry.Pose{X: t.state.Velocity.X * dt, Y: t.state.Velocity.Y * dt, ThetaDeg: t.state.Velocity.ThetaDeg * dt}
+	match, err := MatchRevolutions(reference, revolution, guess, t.conf)
+	t.state.LastMatch = match
+	if err != nil {
+		t.state.Failures++
+		t.state.Velocity = geometry.Pose{}
+		return err
+	}
+
+	t.state.Revolutions++
+	t.state.Pose = t.state.Pose.Compose(match.Transform)
+	t.state.Velocity = geometry.Pose{
+		X:        match.Transform.X / dt,
+		Y:        match.Transform.Y / dt,
+		ThetaDeg: match.Transform.ThetaDeg / dt,
+	}
+	return nil
+}
+
+// State returns the current estimate.
+func (t *Tracker) State() State {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.state
+}
+
+// Reset moves the pose back to the origin, and starts again from the next revolution.
+func (t *Tracker) Reset() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.state = State{}
+	t.reference = nil
+}
@@ -0,0 +1,130 @@
+package odometry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{MaxIterations: -1}, "max_iterations must be positive"},
+		{Config{MaxCorrespondenceMM: -1}, "max_correspondence_mm must be positive"},
+		{Config{MinMatchRatio: 2}, "min_match_ratio must be between 0 and 1"},
+		{Config{MaxPoints: -1}, "max_points must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// scene is an L-shaped room with a pillar, so that every motion in the plane changes the revolution.
+var scene = [][2]geometry.Point{
+	{{X: -3000, Y: -2000}, {X: 4000, Y: -2000}},
+	{{X: 4000, Y: -2000}, {X: 4000, Y: 500}},
+	{{X: 4000, Y: 500}, {X: 1000, Y: 500}},
+	{{X: 1000, Y: 500}, {X: 1000, Y: 3000}},
+	{{X: 1000, Y: 3000}, {X: -3000, Y: 3000}},
+	{{X: -3000, Y: 3000}, {X: -3000, Y: -2000}},
+	{{X: 1500, Y: -1000}, {X: 1800, Y: -1000}},
+	{{X: 1800, Y: -1000}, {X: 1800, Y: -700}},
+	{{X: 1800, Y: -700}, {X: 1500, Y: -700}},
+	{{X: 1500, Y: -700}, {X: 1500, Y: -1000}},
+}
+
+// revolutionAt returns the returns of a revolution every half degree, in the frame of a lidar at pose.
+func revolutionAt(pose geometry.Pose) []geometry.Point {
+	var revolution []geometry.Point
+	for angle := 0.; angle < 360; angle += 0.5 {
+		sin, cos := math.Sincos((pose.ThetaDeg + angle) * math.Pi / 180)
+		closest := math.Inf(1)
+		for _, wall := range scene {
+			// Solve pose + d*(cos, sin) = a + s*(b - a) for d >= 0 and s in [0, 1].
+			a, b := wall[0], wall[1]
+			ex, ey := b.X-a.X, b.Y-a.Y
+			det := ex*sin - ey*cos
+			if det == 0 {
+				continue
+			}
+			wx, wy := a.X-pose.X, a.Y-pose.Y
+			d := (ex*wy - ey*wx) / det
+			s := (cos*wy - sin*wx) / det
+			if d > 0 && s >= 0 && s <= 1 {
+				closest = math.Min(closest, d)
+			}
+		}
+		if !math.IsInf(closest, 1) {
+			lidarSin, lidarCos := math.Sincos(angle * math.Pi / 180)
+			revolution = append(revolution, geometry.Point{X: closest * lidarCos, Y: closest * lidarSin})
+		}
+	}
+	return revolution
+}
+
+func TestMatchRevolutions(t *testing.T) {
+	reference := revolutionAt(geometry.Pose{})
+
+	t.Run("recovers the motion between revolutions", func(t *testing.T) {
+		motion := geometry.Pose{X: 60, Y: -25, ThetaDeg: 4}
+		match, err := MatchRevolutions(reference, revolutionAt(motion), geometry.Pose{}, Config{})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, match.Transform.X, test.ShouldAlmostEqual, motion.X, 1)
+		test.That(t, match.Transform.Y, test.ShouldAlmostEqual, motion.Y, 1)
+		test.That(t, match.Transform.ThetaDeg, test.ShouldAlmostEqual, motion.ThetaDeg, 0.05)
+		test.That(t, match.MatchRatio, test.ShouldBeGreaterThan, 0.9)
+		test.That(t, match.Iterations, test.ShouldBeGreaterThan, 1)
+	})
+
+	t.Run("fails without enough overlap", func(t *testing.T) {
+		_, err := MatchRevolutions(reference, nil, geometry.Pose{}, Config{})
+		test.That(t, err, test.ShouldNotBeNil)
+
+		far := revolutionAt(geometry.Pose{})
+		for i := range far {
+			far[i].X += 10000
+		}
+		_, err = MatchRevolutions(reference, far, geometry.Pose{}, Config{})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "only 0% of the points could be matched")
+	})
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Config{})
+	start := time.Unix(100, 0)
+
+	// Drive forward along x at 500 mm/s while turning at 10 deg/s, at 10 revolutions per second.
+	var pose geometry.Pose
+	step := geometry.Pose{X: 50, ThetaDeg: 1}
+	for i := 0; i <= 20; i++ {
+		test.That(t, tracker.Update(revolutionAt(pose), start.Add(time.Duration(i)*100*time.Millisecond)), test.ShouldBeNil)
+		pose = pose.Compose(step)
+	}
+	pose = geometry.Pose{}
+	for i := 0; i < 20; i++ {
+		pose = pose.Compose(step)
+	}
+
+	state := tracker.State()
+	test.That(t, state.Revolutions, test.ShouldEqual, 20)
+	test.That(t, state.Failures, test.ShouldEqual, 0)
+	test.That(t, state.Pose.X, test.ShouldAlmostEqual, pose.X, 5)
+	test.That(t, state.Pose.Y, test.ShouldAlmostEqual, pose.Y, 5)
+	test.That(t, state.Pose.ThetaDeg, test.ShouldAlmostEqual, pose.ThetaDeg, 0.2)
+	test.That(t, state.Velocity.X, test.ShouldAlmostEqual, 500, 50)
+	test.That(t, state.Velocity.ThetaDeg, test.ShouldAlmostEqual, 10, 2)
+
+	test.That(t, tracker.Update(revolutionAt(pose), start), test.ShouldNotBeNil)
+
+	tracker.Reset()
+	test.That(t, tracker.State(), test.ShouldResemble, State{})
+}
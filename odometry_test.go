@@ -0,0 +1,110 @@
+package rplidar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+// boxRevolution returns a revolution every half degree inside a 4 m by 3 m box centered on the lidar, rotated by
+// offsetDeg in node angles.
+func boxRevolution(offsetDeg float64) []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 0.; angle < 360; angle += 0.5 {
+		sin, cos := math.Sincos((angle + offsetDeg) * math.Pi / 180)
+		revolution = append(revolution, nodes.Node{
+			Angle:    angle,
+			Distance: math.Min(2000/math.Abs(cos), 1500/math.Abs(sin)),
+		})
+	}
+	return revolution
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+	}
+}
+
+func TestOdometry(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	_, _, err := (&OdometryConfig{}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	_, _, err = (&OdometryConfig{Camera: "lidar", MinMatchRatio: 2}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "min_match_ratio must be between 0 and 1")
+	deps, _, err := (&OdometryConfig{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+
+	rp := &rplidar{Named: camera.Named("lidar").AsNamed(), cache: &dataCache{}, logger: logger}
+	test.That(t, rp.startStreaming(ctx, nil), test.ShouldBeNil)
+	defer func() { test.That(t, rp.stopStreaming(), test.ShouldBeNil) }()
+
+	s, err := newOdometrySensor(ctx, resource.Dependencies{camera.Named("lidar"): rp}, resource.Config{
+		Name:                "odometry",
+		API:                 movementsensor.API,
+		ConvertedAttributes: &OdometryConfig{Camera: "lidar"},
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	subscriptions := func() int {
+		rp.commandSubscriptions.mutex.Lock()
+		defer rp.commandSubscriptions.mutex.Unlock()
+		return len(rp.commandSubscriptions.subscriptions)
+	}
+	waitFor(t, func() bool { return subscriptions() == 1 })
+
+	// Rotating the node angles clockwise by 2 degrees turns the lidar counter-clockwise by 2 degrees.
+	start := time.Now()
+	rp.publisher.Publish(publisher.Revolution{Timestamp: start, Nodes: boxRevolution(0)})
+	rp.publisher.Publish(publisher.Revolution{Timestamp: start.Add(100 * time.Millisecond), Nodes: boxRevolution(-2)})
+	waitFor(t, func() bool {
+		resp, err := s.DoCommand(ctx, map[string]interface{}{getOdometryCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		return resp["revolutions_matched"] == 1.
+	})
+
+	orientation, err := s.Orientation(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, orientation.EulerAngles().Yaw*180/math.Pi, test.ShouldAlmostEqual, 2, 0.05)
+	angular, err := s.AngularVelocity(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, angular.Z, test.ShouldAlmostEqual, 20, 2)
+	position, _, err := s.Position(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, position.Lat(), test.ShouldAlmostEqual, 0, 1e-6)
+	test.That(t, position.Lng(), test.ShouldAlmostEqual, 0, 1e-6)
+
+	readings, err := s.Readings(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, readings, test.ShouldContainKey, "orientation")
+	test.That(t, readings, test.ShouldNotContainKey, "compass")
+
+	_, err = s.DoCommand(ctx, map[string]interface{}{resetOdometryCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	resp, err := s.DoCommand(ctx, map[string]interface{}{getOdometryCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["theta_deg"], test.ShouldEqual, 0.)
+	_, err = s.DoCommand(ctx, map[string]interface{}{"other": true})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, s.Close(ctx), test.ShouldBeNil)
+	test.That(t, subscriptions(), test.ShouldEqual, 0)
+}
@@ -0,0 +1,265 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	getPipelineStatsCommand = "get_pipeline_stats"
+
+	// defaultPipelineBuffers is the number of raw revolution buffers shared by acquisition and processing. One is
+	// filled by acquisition while one is processed, and the rest absorb revolutions that take longer than usual to
+	// process.
+	defaultPipelineBuffers = 3
+
+	// pipelineSmoothing is the weight of the newest sample in the moving averages of the pipeline stats.
+	pipelineSmoothing = 0.1
+)
+
+// rawRevolution is a revolution copied out of the driver, before conversion and filtering.
+type rawRevolution struct {
+	nodes []gen.MeasurementNodeHq
+	// timestamp is when the revolution was grabbed from the driver.
+	timestamp time.Time
+	// scanTime is the time since the previous revolution was grabbed, which is how long the device took to measure
+	// this one. It is 0 when unknown, after an acquisition error.
+	scanTime time.Duration
+	// err is set when the revolution could not be grabbed, in which case nodes is empty.
+	err error
+}
+
+// revolutionRing hands raw revolution buffers from acquisition to processing and back. Buffers are only ever in the
+// free queue, the full queue, or held by one of the two goroutines, so neither queue can overflow.
+type revolutionRing struct {
+	free chan *rawRevolution
+	full chan *rawRevolution
+}
+
+func newRevolutionRing(size int) *revolutionRing {
+	ring := &revolutionRing{
+		free: make(chan *rawRevolution, size),
+		full: make(chan *rawRevolution, size),
+	}
+	for i := 0; i < size; i++ {
+		ring.free <- &rawRevolution{}
+	}
+	return ring
+}
+
+// acquire returns a buffer to fill. When processing has fallen behind and no buffer is free, the oldest
+// unprocessed revolution is discarded and its buffer is reused, so that acquisition never waits on processing.
+// dropped reports whether a revolution was discarded. A nil buffer is returned when ctx is done.
+func (ring *revolutionRing) acquire(ctx context.Context) (buf *rawRevolution, dropped bool) {
+	select {
+	case buf = <-ring.free:
+		return buf, false
+	default:
+	}
+	select {
+	case buf = <-ring.free:
+		return buf, false
+	case buf = <-ring.full:
+		return buf, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// pipelineStats counts the revolutions passing through the pipeline and times each side, so that processing which
+// cannot keep up with the device can be spotted and tuned.
+type pipelineStats struct {
+	mutex sync.Mutex
+
+	acquired          uint64
+	processed         uint64
+	dropped           uint64
+	acquisitionErrors uint64
+	maxQueued         int
+
+	// The durations are exponential moving averages, except for maxProcess.
+	revolutionPeriod time.Duration
+	acquire          time.Duration
+	process          time.Duration
+	maxProcess       time.Duration
+}
+
+// smooth adds a sample to an exponential moving average, starting the average at the first sample.
+func smooth(average, sample time.Duration) time.Duration {
+	if average == 0 {
+		return sample
+	}
+	return average + time.Duration(pipelineSmoothing*float64(sample-average))
+}
+
+func (s *pipelineStats) recordAcquired(raw *rawRevolution, took time.Duration, dropped bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if dropped {
+		s.dropped++
+	}
+	if raw.err != nil {
+		s.acquisitionErrors++
+		return
+	}
+	s.acquired++
+	s.acquire = smooth(s.acquire, took)
+	if raw.scanTime > 0 {
+		s.revolutionPeriod = smooth(s.revolutionPeriod, raw.scanTime)
+	}
+}
+
+func (s *pipelineStats) recordQueued(queued int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if queued > s.maxQueued {
+		s.maxQueued = queued
+	}
+}
+
+func (s *pipelineStats) recordProcessed(took time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.processed++
+	s.process = smooth(s.process, took)
+	if took > s.maxProcess {
+		s.maxProcess = took
+	}
+}
+
+func (s *pipelineStats) droppedTotal() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// startPipeline starts the acquisition and processing goroutines that keep the cache and subscribers up to date.
+// Acquisition only holds the device while copying a revolution out of the driver, so that the cost of filtering and
+// building point clouds does not slow down how often the driver is read.
+func (rp *rplidar) startPipeline(ctx context.Context) {
+	ring := newRevolutionRing(defaultPipelineBuffers)
+	rp.pipelineRing = ring
+	rp.pipelineStats = &pipelineStats{}
+
+	rp.cacheBackgroundWorkers.Add(2)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.acquisitionLoop(ctx, ring)
+	}()
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.processingLoop(ctx, ring)
+	}()
+}
+
+// acquisitionLoop repeatedly grabs revolutions from the driver into free buffers of the ring. The scan time of each
+// revolution is measured here, between consecutive grabs, since processing may drop revolutions.
+func (rp *rplidar) acquisitionLoop(ctx context.Context, ring *revolutionRing) {
+	var lastGrabbed time.Time
+	for {
+		buf, dropped := ring.acquire(ctx)
+		if buf == nil {
+			return
+		}
+		if dropped && rp.pipelineStats.droppedTotal() == 0 {
+			rp.logger.Warn("revolution processing is slower than the rplidar, dropping the oldest unprocessed revolutions; " +
+				"see the get_pipeline_stats DoCommand")
+		}
+
+		start := time.Now()
+		buf.nodes, buf.err = rp.grabRevolution(buf.nodes)
+		buf.timestamp = time.Now()
+		buf.scanTime = 0
+		if buf.err != nil {
+			lastGrabbed = time.Time{}
+		} else {
+			if !lastGrabbed.IsZero() {
+				buf.scanTime = buf.timestamp.Sub(lastGrabbed)
+			}
+			lastGrabbed = buf.timestamp
+		}
+		rp.pipelineStats.recordAcquired(buf, buf.timestamp.Sub(start), dropped)
+
+		ring.full <- buf
+		rp.pipelineStats.recordQueued(len(ring.full))
+	}
+}
+
+// processingLoop converts, filters, caches and publishes the revolutions acquired, oldest first.
+func (rp *rplidar) processingLoop(ctx context.Context, ring *revolutionRing) {
+	for {
+		var buf *rawRevolution
+		select {
+		case <-ctx.Done():
+			return
+		case buf = <-ring.full:
+		}
+
+		start := time.Now()
+		timestamp, scanTime, err := buf.timestamp, buf.scanTime, buf.err
+		var scanned []nodes.Node
+		if err == nil {
+			scanned = rp.prefilterRevolution(nodesFromRaw(buf.nodes))
+			rp.evaluateEmergencyStop(scanned, timestamp)
+			scanned = rp.smoothing.Filter(scanned)
+		}
+		// The nodes were copied out of the buffer, so it can be refilled while the revolution is processed.
+		ring.free <- buf
+
+		if err != nil {
+			rp.logger.Debugf("issue getting pointcloud to cache: %v", err)
+			rp.engageEmergencyStop(emergencyStopAcquisitionFailed, timestamp)
+			rp.processRevolution(nil, timestamp, 0)
+			continue
+		}
+		rp.processRevolution(scanned, timestamp, scanTime)
+		rp.pipelineStats.recordProcessed(time.Since(start))
+	}
+}
+
+// getPipelineStats returns the counters and timings of the acquisition and processing pipeline. A growing
+// revolutions_dropped means the configured filters take longer than a revolution of the device.
+func (rp *rplidar) getPipelineStats(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	if rp.pipelineStats == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	s := rp.pipelineStats
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var utilization float64
+	if s.revolutionPeriod > 0 {
+		utilization = float64(s.process) / float64(s.revolutionPeriod)
+	}
+	var queued int
+	if rp.pipelineRing != nil {
+		queued = len(rp.pipelineRing.full)
+	}
+	return map[string]interface{}{
+		"revolutions_acquired":  s.acquired,
+		"revolutions_processed": s.processed,
+		"revolutions_dropped":   s.dropped,
+		"acquisition_errors":    s.acquisitionErrors,
+		"buffers":               defaultPipelineBuffers,
+		"queued":                queued,
+		"max_queued":            s.maxQueued,
+		"revolution_period_ms":  durationMS(s.revolutionPeriod),
+		"acquire_ms":            durationMS(s.acquire),
+		"process_ms":            durationMS(s.process),
+		"max_process_ms":        durationMS(s.maxProcess),
+		"processing_load":       utilization,
+	}, nil
+}
+
+// durationMS returns d in fractional milliseconds.
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
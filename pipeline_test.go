@@ -0,0 +1,118 @@
+package rplidar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestRevolutionRing(t *testing.T) {
+	ctx := context.Background()
+	ring := newRevolutionRing(2)
+
+	first, dropped := ring.acquire(ctx)
+	test.That(t, first, test.ShouldNotBeNil)
+	test.That(t, dropped, test.ShouldBeFalse)
+	ring.full <- first
+	second, dropped := ring.acquire(ctx)
+	test.That(t, dropped, test.ShouldBeFalse)
+	ring.full <- second
+
+	t.Run("reuses the oldest unprocessed buffer when none are free", func(t *testing.T) {
+		buf, dropped := ring.acquire(ctx)
+		test.That(t, dropped, test.ShouldBeTrue)
+		test.That(t, buf, test.ShouldEqual, first)
+		ring.full <- buf
+	})
+
+	t.Run("returns nil when the context is done", func(t *testing.T) {
+		// Hold every buffer, as the processing goroutine would while acquisition is waiting.
+		<-ring.full
+		<-ring.full
+		cancelCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		buf, dropped := ring.acquire(cancelCtx)
+		test.That(t, buf, test.ShouldBeNil)
+		test.That(t, dropped, test.ShouldBeFalse)
+	})
+}
+
+func TestPipeline(t *testing.T) {
+	rp := &rplidar{
+		minRangeMM: 150,
+		cache:      &dataCache{},
+		logger:     logging.NewTestLogger(t),
+	}
+	injectRevolution(t, rp, []packedNode{{0, 4000, 47, 0}, {4096, 0, 0, 0}, {8192, 8000, 47, 0}, {12288, 400, 47, 0}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	test.That(t, rp.startStreaming(ctx, nil), test.ShouldBeNil)
+	sub := rp.publisher.Subscribe(10)
+	rp.startPipeline(ctx)
+
+	var revs []time.Time
+	var scanTimes []time.Duration
+	for i := 0; i < 3; i++ {
+		rev, err := sub.Next(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rev.Nodes, test.ShouldResemble, []nodes.Node{
+			{Angle: 0, Distance: 1000, Quality: 47},
+			{Angle: 45, Distance: 2000, Quality: 47},
+		})
+		revs = append(revs, rev.Timestamp)
+		scanTimes = append(scanTimes, rev.ScanTime)
+	}
+	test.That(t, revs[1].After(revs[0]), test.ShouldBeTrue)
+	// The scan time is measured between grabs, so it never spans revolutions dropped before processing.
+	for i := 1; i < len(revs); i++ {
+		test.That(t, scanTimes[i], test.ShouldBeGreaterThan, 0)
+		test.That(t, scanTimes[i], test.ShouldBeLessThanOrEqualTo, revs[i].Sub(revs[i-1]))
+	}
+
+	pc, err := rp.NextPointCloud(ctx, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+	cancel()
+	rp.cacheBackgroundWorkers.Wait()
+	test.That(t, rp.stopStreaming(), test.ShouldBeNil)
+
+	stats, err := rp.DoCommand(context.Background(), map[string]interface{}{getPipelineStatsCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stats["revolutions_acquired"], test.ShouldBeGreaterThanOrEqualTo, 3)
+	test.That(t, stats["revolutions_processed"], test.ShouldBeGreaterThanOrEqualTo, 3)
+	test.That(t, stats["acquisition_errors"], test.ShouldEqual, uint64(0))
+	test.That(t, stats["buffers"], test.ShouldEqual, defaultPipelineBuffers)
+}
+
+func TestPipelineStats(t *testing.T) {
+	s := &pipelineStats{}
+	start := time.Now()
+	s.recordAcquired(&rawRevolution{timestamp: start}, 2*time.Millisecond, false)
+	s.recordAcquired(&rawRevolution{timestamp: start.Add(100 * time.Millisecond), scanTime: 100 * time.Millisecond},
+		4*time.Millisecond, true)
+	s.recordAcquired(&rawRevolution{err: context.DeadlineExceeded}, time.Second, false)
+	s.recordQueued(2)
+	s.recordQueued(1)
+	s.recordProcessed(50 * time.Millisecond)
+	s.recordProcessed(30 * time.Millisecond)
+
+	rp := &rplidar{pipelineStats: s}
+	stats, err := rp.getPipelineStats(context.Background(), true)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, stats["revolutions_acquired"], test.ShouldEqual, uint64(2))
+	test.That(t, stats["revolutions_dropped"], test.ShouldEqual, uint64(1))
+	test.That(t, stats["acquisition_errors"], test.ShouldEqual, uint64(1))
+	test.That(t, stats["revolutions_processed"], test.ShouldEqual, uint64(2))
+	test.That(t, stats["max_queued"], test.ShouldEqual, 2)
+	test.That(t, stats["revolution_period_ms"], test.ShouldEqual, 100.)
+	test.That(t, stats["acquire_ms"], test.ShouldAlmostEqual, 2.2)
+	test.That(t, stats["process_ms"], test.ShouldAlmostEqual, 48.)
+	test.That(t, stats["max_process_ms"], test.ShouldEqual, 50.)
+	test.That(t, stats["processing_load"], test.ShouldAlmostEqual, 0.48)
+}
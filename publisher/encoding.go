@@ -0,0 +1,115 @@
+package publisher
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+// revolutionJSON is the column oriented wire format of a Revolution, used by the streaming socket and recordings.
+type revolutionJSON struct {
+	Sequence    uint64    `json:"sequence"`
+	Timestamp   time.Time `json:"timestamp"`
+	ScanTimeSec float64   `json:"scan_time_s"`
+	AnglesDeg   []float64 `json:"angles_deg"`
+	DistancesMM []float64 `json:"distances_mm"`
+	// Qualities and Flags use int since a uint8 slice is encoded as a base64 string.
+	Qualities []int   `json:"qualities"`
+	Flags     []int   `json:"flags"`
+	Sector    *Sector `json:"sector,omitempty"`
+}
+
+// MarshalJSON encodes the revolution with one array per node field, which is considerably more compact than an
+// array of objects.
+func (rev Revolution) MarshalJSON() ([]byte, error) {
+	out := revolutionJSON{
+		Sequence:    rev.Sequence,
+		Timestamp:   rev.Timestamp,
+		ScanTimeSec: rev.ScanTime.Seconds(),
+		AnglesDeg:   make([]float64, len(rev.Nodes)),
+		DistancesMM: make([]float64, len(rev.Nodes)),
+		Qualities:   make([]int, len(rev.Nodes)),
+		Flags:       make([]int, len(rev.Nodes)),
+		Sector:      rev.Sector,
+	}
+	for i, node := range rev.Nodes {
+		out.AnglesDeg[i] = node.Angle
+		out.DistancesMM[i] = node.Distance
+		out.Qualities[i] = int(node.Quality)
+		out.Flags[i] = int(node.Flag)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a revolution encoded by MarshalJSON.
+func (rev *Revolution) UnmarshalJSON(data []byte) error {
+	var in revolutionJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	rev.Sequence = in.Sequence
+	rev.Timestamp = in.Timestamp
+	rev.ScanTime = time.Duration(in.ScanTimeSec * float64(time.Second))
+	rev.Sector = in.Sector
+	rev.Nodes = make([]nodes.Node, len(in.AnglesDeg))
+	for i := range rev.Nodes {
+		rev.Nodes[i].Angle = in.AnglesDeg[i]
+		if i < len(in.DistancesMM) {
+			rev.Nodes[i].Distance = in.DistancesMM[i]
+		}
+		if i < len(in.Qualities) {
+			rev.Nodes[i].Quality = uint8(in.Qualities[i])
+		}
+		if i < len(in.Flags) {
+			rev.Nodes[i].Flag = uint8(in.Flags[i])
+		}
+	}
+	return nil
+}
+
+// Map returns the revolution in the same layout as its JSON encoding, suitable for a DoCommand response.
+func (rev Revolution) Map() map[string]interface{} {
+	angles := make([]interface{}, len(rev.Nodes))
+	distances := make([]interface{}, len(rev.Nodes))
+	qualities := make([]interface{}, len(rev.Nodes))
+	flags := make([]interface{}, len(rev.Nodes))
+	for i, node := range rev.Nodes {
+		angles[i] = node.Angle
+		distances[i] = node.Distance
+		qualities[i] = float64(node.Quality)
+		flags[i] = float64(node.Flag)
+	}
+
+	m := map[string]interface{}{
+		"sequence":     float64(rev.Sequence),
+		"timestamp":    rev.Timestamp.Format(time.RFC3339Nano),
+		"scan_time_s":  rev.ScanTime.Seconds(),
+		"angles_deg":   angles,
+		"distances_mm": distances,
+		"qualities":    qualities,
+		"flags":        flags,
+	}
+	if rev.Sector != nil {
+		m["sector"] = map[string]interface{}{
+			"revolution": float64(rev.Sector.Revolution),
+			"index":      float64(rev.Sector.Index),
+			"start_deg":  rev.Sector.StartDeg,
+			"end_deg":    rev.Sector.EndDeg,
+		}
+	}
+	return m
+}
+
+// RevolutionFromMap decodes a revolution from the layout returned by Map, such as a DoCommand response received
+// over the network.
+func RevolutionFromMap(m map[string]interface{}) (Revolution, error) {
+	var rev Revolution
+	b, err := json.Marshal(m)
+	if err != nil {
+		return rev, err
+	}
+	err = json.Unmarshal(b, &rev)
+	return rev, err
+}
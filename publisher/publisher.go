@@ -0,0 +1,211 @@
+// Package publisher fans every completed RPLiDAR revolution out to subscribers, so that consumers such as recorders
+// and fusers see each revolution rather than whatever happens to be cached when they poll.
+package publisher
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+// DefaultQueueSize is the number of revolutions a subscription buffers when no size is given.
+const DefaultQueueSize = 10
+
+// ErrClosed is returned when reading from a subscription that has been closed.
+var ErrClosed = errors.New("subscription closed")
+
+// lastSubscriptionID is shared by every publisher, so that subscriptions to different publishers can be told apart by
+// their ID alone.
+var lastSubscriptionID atomic.Uint64
+
+// Revolution is a single filtered revolution, as published to subscribers.
+type Revolution struct {
+	// Sequence increases by one for every published revolution, so subscribers can detect gaps.
+	Sequence  uint64
+	Timestamp time.Time
+	ScanTime  time.Duration
+	Nodes     []nodes.Node
+	// Sector is set when this is only part of a revolution.
+	Sector *Sector
+}
+
+// Sector describes which part of a revolution a partial revolution covers.
+type Sector struct {
+	// Revolution counts the revolutions started since scanning began, as marked by the sync flag of the nodes.
+	Revolution uint64 `json:"revolution"`
+	// Index is the position of the sector in its revolution, starting from 0 at StartDeg 0.
+	Index    int     `json:"index"`
+	StartDeg float64 `json:"start_deg"`
+	EndDeg   float64 `json:"end_deg"`
+}
+
+// Publisher distributes revolutions to every subscription. It is safe for concurrent use.
+type Publisher struct {
+	mutex         sync.Mutex
+	sequence      uint64
+	subscriptions map[uint64]*Subscription
+	closed        bool
+}
+
+// New creates a Publisher without any subscriptions.
+func New() *Publisher {
+	return &Publisher{subscriptions: map[uint64]*Subscription{}}
+}
+
+// Publish assigns the next sequence number to the revolution and queues it on every subscription. Publish never
+// blocks on slow subscribers; when a subscription's queue is full its oldest revolution is dropped.
+func (p *Publisher) Publish(rev Revolution) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.sequence++
+	rev.Sequence = p.sequence
+	for _, sub := range p.subscriptions {
+		sub.push(rev)
+	}
+}
+
+// Subscribe creates a subscription that buffers up to queueSize revolutions.
+func (p *Publisher) Subscribe(queueSize int) *Subscription {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	sub := &Subscription{
+		id:        lastSubscriptionID.Add(1),
+		publisher: p,
+		queue:     make([]Revolution, 0, queueSize),
+		size:      queueSize,
+		notify:    make(chan struct{}, 1),
+	}
+	if p.closed {
+		sub.closed = true
+	} else {
+		p.subscriptions[sub.id] = sub
+	}
+	return sub
+}
+
+// Close closes every subscription. Revolutions published after Close are discarded.
+func (p *Publisher) Close() {
+	p.mutex.Lock()
+	subscriptions := p.subscriptions
+	p.subscriptions = map[uint64]*Subscription{}
+	p.closed = true
+	p.mutex.Unlock()
+
+	for _, sub := range subscriptions {
+		sub.close()
+	}
+}
+
+func (p *Publisher) unsubscribe(id uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.subscriptions, id)
+}
+
+// Subscription is a bounded queue of revolutions with drop-oldest semantics.
+type Subscription struct {
+	id        uint64
+	publisher *Publisher
+
+	mutex   sync.Mutex
+	queue   []Revolution
+	size    int
+	dropped uint64
+	closed  bool
+	notify  chan struct{}
+}
+
+// ID returns the identifier of the subscription, unique across every publisher.
+func (s *Subscription) ID() uint64 {
+	return s.id
+}
+
+func (s *Subscription) push(rev Revolution) {
+	s.mutex.Lock()
+	if len(s.queue) == s.size {
+		copy(s.queue, s.queue[1:])
+		s.queue = s.queue[:len(s.queue)-1]
+		s.dropped++
+	}
+	s.queue = append(s.queue, rev)
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Next blocks until a revolution is available, the subscription is closed or the context is done.
+func (s *Subscription) Next(ctx context.Context) (Revolution, error) {
+	for {
+		s.mutex.Lock()
+		if len(s.queue) > 0 {
+			rev := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mutex.Unlock()
+			return rev, nil
+		}
+		closed := s.closed
+		s.mutex.Unlock()
+		if closed {
+			return Revolution{}, ErrClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return Revolution{}, ctx.Err()
+		case <-s.notify:
+		}
+	}
+}
+
+// Drain returns up to max queued revolutions without blocking. A max of zero or less returns every queued revolution.
+func (s *Subscription) Drain(max int) []Revolution {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n := len(s.queue)
+	if max > 0 && max < n {
+		n = max
+	}
+	drained := make([]Revolution, n)
+	copy(drained, s.queue[:n])
+	s.queue = s.queue[n:]
+	return drained
+}
+
+// Dropped returns the number of revolutions dropped because the queue was full.
+func (s *Subscription) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// Close removes the subscription from its publisher and wakes any blocked call to Next.
+func (s *Subscription) Close() {
+	s.publisher.unsubscribe(s.id)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
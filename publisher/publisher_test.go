@@ -0,0 +1,295 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("fans revolutions out to every subscription", func(t *testing.T) {
+		p := New()
+		sub1 := p.Subscribe(5)
+		sub2 := p.Subscribe(5)
+		test.That(t, sub1.ID(), test.ShouldNotEqual, sub2.ID())
+		test.That(t, New().Subscribe(5).ID(), test.ShouldNotBeIn, sub1.ID(), sub2.ID())
+
+		p.Publish(Revolution{Nodes: []nodes.Node{{Angle: 1}}})
+		p.Publish(Revolution{Nodes: []nodes.Node{{Angle: 2}}})
+
+		for _, sub := range []*Subscription{sub1, sub2} {
+			rev, err := sub.Next(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, rev.Sequence, test.ShouldEqual, 1)
+			rev, err = sub.Next(ctx)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, rev.Sequence, test.ShouldEqual, 2)
+			test.That(t, rev.Nodes, test.ShouldResemble, []nodes.Node{{Angle: 2}})
+		}
+	})
+
+	t.Run("drops the oldest revolution when the queue is full", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(2)
+		for i := 0; i < 5; i++ {
+			p.Publish(Revolution{})
+		}
+
+		drained := sub.Drain(0)
+		test.That(t, len(drained), test.ShouldEqual, 2)
+		test.That(t, drained[0].Sequence, test.ShouldEqual, 4)
+		test.That(t, drained[1].Sequence, test.ShouldEqual, 5)
+		test.That(t, sub.Dropped(), test.ShouldEqual, 3)
+		test.That(t, sub.Drain(0), test.ShouldBeEmpty)
+	})
+
+	t.Run("drains at most max revolutions", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(5)
+		for i := 0; i < 3; i++ {
+			p.Publish(Revolution{})
+		}
+		test.That(t, len(sub.Drain(2)), test.ShouldEqual, 2)
+		test.That(t, len(sub.Drain(2)), test.ShouldEqual, 1)
+	})
+
+	t.Run("next waits for a revolution", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(1)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			p.Publish(Revolution{})
+		}()
+		rev, err := sub.Next(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, rev.Sequence, test.ShouldEqual, 1)
+	})
+
+	t.Run("next returns when the context is done", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(1)
+		cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := sub.Next(cancelCtx)
+		test.That(t, errors.Is(err, context.DeadlineExceeded), test.ShouldBeTrue)
+	})
+
+	t.Run("closing unsubscribes and wakes next", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(1)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sub.Close()
+		}()
+		_, err := sub.Next(ctx)
+		test.That(t, errors.Is(err, ErrClosed), test.ShouldBeTrue)
+
+		p.Publish(Revolution{})
+		test.That(t, sub.Drain(0), test.ShouldBeEmpty)
+	})
+
+	t.Run("closing the publisher closes every subscription", func(t *testing.T) {
+		p := New()
+		sub := p.Subscribe(1)
+		p.Close()
+		_, err := sub.Next(ctx)
+		test.That(t, errors.Is(err, ErrClosed), test.ShouldBeTrue)
+
+		_, err = p.Subscribe(1).Next(ctx)
+		test.That(t, errors.Is(err, ErrClosed), test.ShouldBeTrue)
+	})
+}
+
+func TestEncoding(t *testing.T) {
+	rev := Revolution{
+		Sequence:  7,
+		Timestamp: time.Unix(100, 500).UTC(),
+		ScanTime:  100 * time.Millisecond,
+		Nodes:     []nodes.Node{{Angle: 1.5, Distance: 1000, Quality: 47, Flag: 1}, {Angle: 2.5, Distance: 2000}},
+	}
+
+	b, err := json.Marshal(rev)
+	test.That(t, err, test.ShouldBeNil)
+
+	var decoded Revolution
+	test.That(t, json.Unmarshal(b, &decoded), test.ShouldBeNil)
+	test.That(t, decoded, test.ShouldResemble, rev)
+
+	m := rev.Map()
+	test.That(t, m["sequence"], test.ShouldEqual, 7.)
+	test.That(t, m["qualities"], test.ShouldResemble, []interface{}{47., 0.})
+	test.That(t, m, test.ShouldNotContainKey, "sector")
+
+	fromMap, err := RevolutionFromMap(m)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fromMap, test.ShouldResemble, rev)
+
+	t.Run("partial revolutions carry their sector", func(t *testing.T) {
+		rev.Sector = &Sector{Revolution: 3, Index: 1, StartDeg: 45, EndDeg: 90}
+
+		b, err := json.Marshal(rev)
+		test.That(t, err, test.ShouldBeNil)
+		var decoded Revolution
+		test.That(t, json.Unmarshal(b, &decoded), test.ShouldBeNil)
+		test.That(t, decoded, test.ShouldResemble, rev)
+
+		test.That(t, rev.Map()["sector"], test.ShouldResemble, map[string]interface{}{
+			"revolution": 3., "index": 1., "start_deg": 45., "end_deg": 90.,
+		})
+		fromMap, err := RevolutionFromMap(rev.Map())
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, fromMap, test.ShouldResemble, rev)
+	})
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	logger := logging.NewTestLogger(t)
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "stream.sock"))
+	test.That(t, err, test.ShouldBeNil)
+
+	p := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Serve(ctx, listener, 5, logger)
+	}()
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.Close()
+
+	// Publish until the connection has subscribed and received a revolution.
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	var line string
+	for line == "" {
+		p.Publish(Revolution{Nodes: []nodes.Node{{Angle: 1, Distance: 1000}}})
+		select {
+		case line = <-lines:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	var rev Revolution
+	test.That(t, json.Unmarshal([]byte(line), &rev), test.ShouldBeNil)
+	test.That(t, rev.Nodes, test.ShouldResemble, []nodes.Node{{Angle: 1, Distance: 1000}})
+
+	cancel()
+	test.That(t, listener.Close(), test.ShouldBeNil)
+	<-done
+}
+
+// flakyListener fails the first accepts before accepting from the wrapped listener.
+type flakyListener struct {
+	net.Listener
+	failures atomic.Int32
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures.Add(-1) >= 0 {
+		return nil, errors.New("too many open files")
+	}
+	return l.Listener.Accept()
+}
+
+func TestServeRetriesAccept(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := logging.NewTestLogger(t)
+
+	inner, err := net.Listen("unix", filepath.Join(t.TempDir(), "stream.sock"))
+	test.That(t, err, test.ShouldBeNil)
+	listener := &flakyListener{Listener: inner}
+	listener.failures.Store(3)
+
+	p := New()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Serve(ctx, listener, 5, logger)
+	}()
+
+	// The connection is accepted once the failures are over, so it receives revolutions.
+	conn, err := net.Dial("unix", inner.Addr().String())
+	test.That(t, err, test.ShouldBeNil)
+	defer conn.Close()
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		if scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	var line string
+	for line == "" {
+		p.Publish(Revolution{Nodes: []nodes.Node{{Angle: 1, Distance: 1000}}})
+		select {
+		case line = <-lines:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	t.Run("serving stops when ctx is done while retrying", func(t *testing.T) {
+		listener.failures.Store(1000)
+		test.That(t, conn.Close(), test.ShouldBeNil)
+		// Wake the pending accept so that the failures start.
+		wake, err := net.Dial("unix", inner.Addr().String())
+		test.That(t, err, test.ShouldBeNil)
+		defer wake.Close()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return")
+		}
+	})
+	test.That(t, inner.Close(), test.ShouldBeNil)
+}
+
+func BenchmarkPublish(b *testing.B) {
+	p := New()
+	defer p.Close()
+	// Full queues exercise the drop-oldest path on every publish.
+	for i := 0; i < 4; i++ {
+		p.Subscribe(DefaultQueueSize)
+	}
+
+	rev := Revolution{Timestamp: time.Now(), Nodes: make([]nodes.Node, 8192)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Publish(rev)
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	rev := Revolution{Timestamp: time.Now(), Nodes: make([]nodes.Node, 8192)}
+	for i := range rev.Nodes {
+		rev.Nodes[i] = nodes.Node{Angle: 360 * float64(i) / 8192, Distance: 2000, Quality: 47}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(rev); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
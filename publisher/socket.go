@@ -0,0 +1,86 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+const (
+	// socketWriteTimeout bounds how long a single revolution may take to write before a client is disconnected.
+	socketWriteTimeout = 5 * time.Second
+
+	// After a failed accept, such as when the process runs out of file descriptors, accepting is retried after a
+	// delay that doubles on every consecutive failure, up to the maximum.
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+// Serve accepts connections on the listener until it is closed, subscribing each connection to the publisher and
+// writing every revolution to it as a line of JSON. Failed accepts are retried, so that a transient failure does not
+// lock clients out. Serve returns once the listener is closed, or ctx is done, and every connection has finished;
+// connections finish when ctx is done or the publisher is closed.
+func (p *Publisher) Serve(ctx context.Context, listener net.Listener, queueSize int, logger logging.Logger) {
+	var connections sync.WaitGroup
+	defer connections.Wait()
+
+	var retryDelay time.Duration
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if retryDelay == 0 {
+				retryDelay = minAcceptRetryDelay
+			} else if retryDelay = 2 * retryDelay; retryDelay > maxAcceptRetryDelay {
+				retryDelay = maxAcceptRetryDelay
+			}
+			logger.Warnf("error accepting stream connection, retrying in %v: %v", retryDelay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		retryDelay = 0
+
+		connections.Add(1)
+		go func() {
+			defer connections.Done()
+			p.stream(ctx, conn, queueSize, logger)
+		}()
+	}
+}
+
+// stream writes revolutions to a single connection until it fails or the subscription ends.
+func (p *Publisher) stream(ctx context.Context, conn net.Conn, queueSize int, logger logging.Logger) {
+	sub := p.Subscribe(queueSize)
+	defer sub.Close()
+	defer func() {
+		if err := conn.Close(); err != nil {
+			logger.Debugf("error closing stream connection: %v", err)
+		}
+	}()
+
+	encoder := json.NewEncoder(conn)
+	for {
+		rev, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout)); err != nil {
+			return
+		}
+		if err := encoder.Encode(rev); err != nil {
+			logger.Debugf("stream client disconnected: %v", err)
+			return
+		}
+	}
+}
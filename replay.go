@@ -0,0 +1,58 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"time"
+
+	"go.viam.com/rdk/logging"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+// ReplayProcessor runs recorded revolutions through the same filtering, point cloud conversion, caching and
+// publication as the camera, without a device, so that the per-revolution cost can be measured on the target
+// hardware. It is not safe for concurrent use.
+type ReplayProcessor struct {
+	rp *rplidar
+}
+
+// NewReplayProcessor creates a ReplayProcessor with the filters, downsampling and zones of conf. The stream, foxglove
+// and sectors attributes are ignored.
+func NewReplayProcessor(conf *Config, logger logging.Logger) *ReplayProcessor {
+	rp := &rplidar{
+		minRangeMM: conf.MinRangeMM,
+		cache:      &dataCache{},
+		logger:     logger,
+	}
+	rp.setupFilters(conf)
+	rp.setupZones(conf)
+	// Streaming is started without a socket, which cannot fail.
+	//nolint:errcheck
+	rp.startStreaming(context.Background(), nil)
+	return &ReplayProcessor{rp: rp}
+}
+
+// Filter applies the minimum range and the configured filters to a revolution, as done when scanning.
+// The revolution may be modified.
+func (p *ReplayProcessor) Filter(revolution []nodes.Node) []nodes.Node {
+	return p.rp.filterRevolution(revolution)
+}
+
+// Process converts a filtered revolution to point clouds, caches it and publishes it to subscribers. The scan time of
+// the revolution is left unknown.
+func (p *ReplayProcessor) Process(revolution []nodes.Node, timestamp time.Time) {
+	p.rp.processRevolution(revolution, timestamp, 0)
+}
+
+// Subscribe adds a subscriber to the published revolutions, to include fan out in the measured cost.
+func (p *ReplayProcessor) Subscribe(queueSize int) *publisher.Subscription {
+	return p.rp.publisher.Subscribe(queueSize)
+}
+
+// Close closes every subscription.
+func (p *ReplayProcessor) Close() {
+	//nolint:errcheck
+	p.rp.stopStreaming()
+}
@@ -0,0 +1,41 @@
+package rplidar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestReplayProcessor(t *testing.T) {
+	processor := NewReplayProcessor(&Config{
+		MinRangeMM: 150,
+		Downsample: &filters.DownsampleConfig{MaxPoints: 1},
+	}, logging.NewTestLogger(t))
+	defer processor.Close()
+	sub := processor.Subscribe(1)
+
+	revolution := []nodes.Node{{Angle: 0, Distance: 100}, {Angle: 10, Distance: 1000}, {Angle: 20, Distance: 2000}}
+	filtered := processor.Filter(revolution)
+	test.That(t, filtered, test.ShouldResemble, []nodes.Node{{Angle: 10, Distance: 1000}, {Angle: 20, Distance: 2000}})
+
+	timestamp := time.Now()
+	processor.Process(filtered, timestamp)
+
+	pc, err := processor.rp.NextPointCloud(context.Background(), nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 1)
+	pc, err = processor.rp.NextPointCloud(context.Background(), map[string]interface{}{"downsample": false})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+	published := sub.Drain(0)
+	test.That(t, len(published), test.ShouldEqual, 1)
+	test.That(t, published[0].Timestamp, test.ShouldEqual, timestamp)
+	test.That(t, published[0].Nodes, test.ShouldResemble, filtered)
+}
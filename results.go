@@ -24,6 +24,8 @@ var (
 	ResultOpNotSupported     = Result(gen.RESULT_OPERATION_NOT_SUPPORT)
 	ResultFormatNotSupported = Result(gen.RESULT_FORMAT_NOT_SUPPORT)
 	ResultInsufficientMemory = Result(gen.RESULT_INSUFFICIENT_MEMORY)
+	// ResultRemainingData is only defined in the SDK's internal headers, so it is not bound in gen.
+	ResultRemainingData = Result(0x21)
 )
 
 // Failed returns an error if the result is that of a failure.
@@ -55,6 +57,8 @@ func (r Result) String() string {
 		return "FormatNotSupported"
 	case ResultInsufficientMemory:
 		return "InsufficientMemory"
+	case ResultRemainingData:
+		return "RemainingData"
 	default:
 		return "Unknown"
 	}
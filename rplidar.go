@@ -3,7 +3,10 @@ package rplidar
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net"
 	"os"
 	"sort"
 	"strings"
@@ -24,7 +27,17 @@ import (
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/spatialmath"
 	"go.viam.com/rdk/utils"
-	rputils "go.viam.com/rplidar/utils"
+	"go.viam.com/rplidar/background"
+	"go.viam.com/rplidar/features"
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/foxglove"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/landmarks"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/occupancy"
+	"go.viam.com/rplidar/publisher"
+	"go.viam.com/rplidar/tracking"
+	"go.viam.com/rplidar/zones"
 )
 
 // RPLiDARModel represents the model of rplidar being used
@@ -56,6 +69,9 @@ const (
 	S1
 )
 
+// downsampleExtraKey is the NextPointCloud extra used to override the configured downsampling.
+const downsampleExtraKey = "downsample"
+
 var (
 	// Model is the model of the RPLiDAR
 	Model = resource.NewModel("viam", "lidar", "rplidar")
@@ -63,6 +79,8 @@ var (
 	rplidarModelByteMap = map[byte]RPLiDARModel{24: A1, 49: A3, 97: S1}
 	// The max capture frequency for rplidar models, based on their datasheets
 	maxScanningFrequencyByModel = map[RPLiDARModel]float64{A1: 10, A3: 15, S1: 15}
+	// The max measurement range in millimeters for rplidar models, based on their datasheets
+	maxRangeMMByModel = map[RPLiDARModel]float64{A1: 12000, A3: 25000, S1: 40000}
 )
 
 // modelToString converted the RPLiDARModel to a string
@@ -83,6 +101,14 @@ func modelToString(model RPLiDARModel) string {
 type dataCache struct {
 	mutex      sync.RWMutex
 	pointCloud pointcloud.PointCloud
+	// downsampledPointCloud is the point cloud with the configured downsampling applied, if any.
+	downsampledPointCloud pointcloud.PointCloud
+	// nodes are the full resolution nodes the point cloud was built from.
+	nodes []nodes.Node
+	// timestamp is when the nodes were captured and scanTime is how long the device took to measure them, or 0 when
+	// unknown.
+	timestamp time.Time
+	scanTime  time.Duration
 }
 
 // rplidar contains the connection, filters and data cached used to interface with an RPLiDAR device.
@@ -93,19 +119,64 @@ type rplidar struct {
 	lockFilePath string
 	device       *rplidarDevice
 	nodes        gen.Rplidar_response_measurement_node_hq_t
-	minRangeMM   float64
+	rawNodes     []gen.MeasurementNodeHq
+	// intervalNodes is only allocated when partial revolutions are published.
+	intervalNodes gen.Rplidar_response_measurement_node_hq_t
+	minRangeMM    float64
+	scanMode      string
+	// filters drop returns that are not obstacles, and smoothing drops or corrects noisy returns after them.
+	filters       filters.Chain
+	smoothing     filters.Chain
+	mask          *filters.MaskFilter
+	downsample    *filters.DownsampleFilter
+	zones         *zones.Monitor
+	estop         *emergencyStop
+	features      *featureExtractor
+	occupancyGrid *occupancy.Grid
+	baseMotion    *baseMotion
+	landmarks     *landmarkDetector
+	nodding       *noddingMount
+	background    *backgroundSubtractor
+	tracker       *objectTracker
 
 	cancelFunc             func()
 	cacheBackgroundWorkers sync.WaitGroup
 	cache                  *dataCache
+	pipelineRing           *revolutionRing
+	pipelineStats          *pipelineStats
+
+	publisher               *publisher.Publisher
+	commandSubscriptions    *commandSubscriptions
+	streamListener          net.Listener
+	sectorPublisher         *publisher.Publisher
+	sectorListener          net.Listener
+	activeBackgroundWorkers sync.WaitGroup
 
 	logger logging.Logger
 }
 
 // Config describes how to configure the RPLiDAR component.
 type Config struct {
-	SerialPath string  `json:"serial_path"`
-	MinRangeMM float64 `json:"min_range_mm"`
+	SerialPath    string                    `json:"serial_path"`
+	MinRangeMM    float64                   `json:"min_range_mm"`
+	ScanMode      string                    `json:"scan_mode,omitempty"`
+	Mounting      *geometry.Pose            `json:"mounting,omitempty"`
+	BodyMask      *filters.MaskConfig       `json:"body_mask,omitempty"`
+	ShadowFilter  *filters.ShadowConfig     `json:"shadow_filter,omitempty"`
+	OutlierFilter *filters.OutlierConfig    `json:"outlier_filter,omitempty"`
+	MedianFilter  *filters.MedianConfig     `json:"median_filter,omitempty"`
+	Downsample    *filters.DownsampleConfig `json:"downsample,omitempty"`
+	Stream        *StreamConfig             `json:"stream,omitempty"`
+	Foxglove      *foxglove.Config          `json:"foxglove,omitempty"`
+	Sectors       *SectorConfig             `json:"sectors,omitempty"`
+	Zones         []zones.Config            `json:"zones,omitempty"`
+	EmergencyStop *EmergencyStopConfig      `json:"emergency_stop,omitempty"`
+	Features      *features.Config          `json:"features,omitempty"`
+	OccupancyGrid *occupancy.Config         `json:"occupancy_grid,omitempty"`
+	Landmarks     *landmarks.Config         `json:"landmarks,omitempty"`
+	Nodding       *NoddingConfig            `json:"nodding,omitempty"`
+	Background    *background.Config        `json:"background,omitempty"`
+	Tracking      *tracking.Config          `json:"tracking,omitempty"`
 }
 
 // Validate checks that the config attributes are valid for an RPLiDAR.
@@ -115,14 +186,142 @@ func (conf *Config) Validate(_ string) ([]string, []string, error) {
 		return nil, nil, errors.New("min_range must be positive")
 	}
 
-	return nil, nil, nil
+	if conf.BodyMask != nil {
+		if err := conf.BodyMask.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid body_mask")
+		}
+	}
+
+	if conf.ShadowFilter != nil {
+		if err := conf.ShadowFilter.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid shadow_filter")
+		}
+	}
+
+	if conf.OutlierFilter != nil {
+		if err := conf.OutlierFilter.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid outlier_filter")
+		}
+	}
+
+	if conf.MedianFilter != nil {
+		if err := conf.MedianFilter.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid median_filter")
+		}
+	}
+
+	if conf.Downsample != nil {
+		if err := conf.Downsample.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid downsample")
+		}
+	}
+
+	if conf.Stream != nil {
+		if err := conf.Stream.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid stream")
+		}
+	}
+
+	if conf.Foxglove != nil {
+		if err := conf.Foxglove.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid foxglove")
+		}
+	}
+
+	if conf.Sectors != nil {
+		if err := conf.Sectors.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid sectors")
+		}
+		if conf.Stream != nil && conf.Sectors.SocketPath != "" && conf.Sectors.SocketPath == conf.Stream.SocketPath {
+			return nil, nil, errors.New("sectors socket_path must differ from the stream socket_path")
+		}
+	}
+
+	if err := zones.ValidateConfigs(conf.Zones); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid zones")
+	}
+
+	if conf.Features != nil {
+		if err := conf.Features.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid features")
+		}
+	}
+
+	if conf.OccupancyGrid != nil {
+		if err := conf.OccupancyGrid.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid occupancy_grid")
+		}
+	}
+
+	if conf.Landmarks != nil {
+		if err := conf.Landmarks.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid landmarks")
+		}
+	}
+
+	if conf.Background != nil {
+		if err := conf.Background.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid background")
+		}
+	}
+
+	if conf.Tracking != nil {
+		if err := conf.Tracking.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid tracking")
+		}
+	}
+
+	var deps []string
+	if conf.EmergencyStop != nil {
+		if err := conf.EmergencyStop.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid emergency_stop")
+		}
+		deps = append(deps, conf.EmergencyStop.Base)
+	}
+
+	if conf.Nodding != nil {
+		if err := conf.Nodding.Validate(); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid nodding")
+		}
+		deps = append(deps, conf.Nodding.dependency())
+	}
+
+	if conf.OccupancyGrid != nil && conf.OccupancyGrid.MovementSensor != "" {
+		deps = append(deps, conf.OccupancyGrid.MovementSensor)
+	}
+
+	if err := conf.validateBaseFrames(); err != nil {
+		return nil, nil, err
+	}
+
+	return deps, nil, nil
+}
+
+// validateBaseFrames checks that the mounting pose is set when anything is expressed in the robot's base frame, which
+// is only known through it.
+func (conf *Config) validateBaseFrames() error {
+	if conf.Mounting != nil {
+		return nil
+	}
+	if conf.BodyMask != nil && conf.BodyMask.Frame == filters.MaskFrameBase {
+		return errors.New("body_mask is in the base frame, which needs mounting to be set")
+	}
+	for _, zone := range conf.Zones {
+		if zone.Frame == zones.FrameBase {
+			return errors.Errorf("zone %q is in the base frame, which needs mounting to be set", zone.Name)
+		}
+	}
+	if conf.EmergencyStop != nil && conf.EmergencyStop.Frame == zones.FrameBase {
+		return errors.New("emergency_stop is in the base frame, which needs mounting to be set")
+	}
+	return nil
 }
 
 func init() {
 	resource.RegisterComponent(camera.API, Model, resource.Registration[camera.Camera, *Config]{Constructor: newRplidar})
 }
 
-func newRplidar(ctx context.Context, _ resource.Dependencies, c resource.Config, logger logging.Logger) (camera.Camera, error) {
+func newRplidar(ctx context.Context, deps resource.Dependencies, c resource.Config, logger logging.Logger) (camera.Camera, error) {
 	svcConf, err := resource.NativeConfig[*Config](c)
 	if err != nil {
 		return nil, err
@@ -147,55 +346,132 @@ func newRplidar(ctx context.Context, _ resource.Dependencies, c resource.Config,
 
 	rplidarDevice, err := getRplidarDevice(devicePath)
 	if err != nil {
+		if removeErr := os.Remove(lockFilePath); removeErr != nil {
+			logger.Warnf("could not remove lock file %v: %v", lockFilePath, removeErr)
+		}
 		return nil, err
 	}
 
 	rplidarModel := rplidarModelByteMap[rplidarDevice.model]
 	logger.Info("found and connected to an " + modelToString(rplidarModel) + " rplidar")
 
-	// Check configured capture frequency
-	captureFreqHz, err := getCaptureFrequencyHzFromConfig(c)
-	if err != nil {
-		return nil, err
-	}
-
-	if captureFreqHz > maxScanningFrequencyByModel[rplidarModel] {
-		return nil, errors.Errorf("configured capture frequency (%v) is greater than max frequency (%v) for rplidar %v",
-			captureFreqHz,
-			maxScanningFrequencyByModel[rplidarModel],
-			rplidarModel)
-	}
-
 	rp := &rplidar{
 		Named:        c.ResourceName().AsNamed(),
 		device:       rplidarDevice,
 		lockFilePath: lockFilePath,
 		minRangeMM:   svcConf.MinRangeMM,
+		scanMode:     svcConf.ScanMode,
 
 		cache:                  &dataCache{},
 		cacheBackgroundWorkers: sync.WaitGroup{},
 
 		logger: logger,
 	}
+	cancelCtx, cancelFunc := context.WithCancel(context.Background())
+	rp.cancelFunc = cancelFunc
+
+	// Check configured capture frequency
+	captureFreqHz, err := getCaptureFrequencyHzFromConfig(c)
+	if err != nil {
+		return nil, rp.closeAfterError(err)
+	}
+
+	if captureFreqHz > maxScanningFrequencyByModel[rplidarModel] {
+		return nil, rp.closeAfterError(errors.Errorf(
+			"configured capture frequency (%v) is greater than max frequency (%v) for rplidar %v",
+			captureFreqHz,
+			maxScanningFrequencyByModel[rplidarModel],
+			rplidarModel))
+	}
+
+	rp.setupFilters(svcConf)
+	rp.setupZones(svcConf)
+	rp.setupFeatures(svcConf)
+	rp.setupOccupancyGrid(svcConf)
+	rp.setupLandmarks(svcConf)
+	if err := rp.setupBackground(svcConf); err != nil {
+		return nil, rp.closeAfterError(err)
+	}
+	rp.setupTracking(svcConf)
 
 	// Setup RPLiDAR
 	if err := rp.setupRPLidar(ctx); err != nil {
-		return nil, errors.Wrap(err, "there was a problem setting up the rplidar")
+		return nil, rp.closeAfterError(errors.Wrap(err, "there was a problem setting up the rplidar"))
 	}
 
-	cancelCtx, cancelFunc := context.WithCancel(context.Background())
-	rp.cancelFunc = cancelFunc
+	// Start streaming revolutions to subscribers
+	if err := rp.startStreaming(cancelCtx, svcConf.Stream); err != nil {
+		return nil, rp.closeAfterError(err)
+	}
+	if svcConf.Foxglove != nil {
+		if err := rp.startFoxgloveBridge(cancelCtx, svcConf.Foxglove); err != nil {
+			return nil, rp.closeAfterError(err)
+		}
+	}
+
+	if err := rp.setupEmergencyStop(cancelCtx, svcConf, deps); err != nil {
+		return nil, rp.closeAfterError(err)
+	}
+	if err := rp.setupBaseMotion(cancelCtx, svcConf, deps); err != nil {
+		return nil, rp.closeAfterError(err)
+	}
+	if err := rp.setupNodding(cancelCtx, svcConf, deps); err != nil {
+		return nil, rp.closeAfterError(err)
+	}
 
 	// Start background caching of pointcloud data
-	rp.cacheBackgroundWorkers.Add(1)
-	go func() {
-		defer rp.cacheBackgroundWorkers.Done()
-		rp.cachePointCloudLoop(cancelCtx)
-	}()
+	rp.startPipeline(cancelCtx)
+
+	if svcConf.Sectors != nil {
+		if err := rp.startSectors(cancelCtx, svcConf.Sectors); err != nil {
+			return nil, rp.closeAfterError(err)
+		}
+	}
 
 	return rp, nil
 }
 
+// closeAfterError releases everything newRplidar acquired before a failing step and returns err. Like Close, it stops
+// the background workers before closing the device under its mutex, so that none is still using it, then removes the
+// lock file.
+func (rp *rplidar) closeAfterError(err error) error {
+	if closeErr := rp.Close(context.Background()); closeErr != nil {
+		rp.logger.Warnf("could not release the rplidar after a failed setup: %v", closeErr)
+	}
+	return err
+}
+
+// setupFilters builds the filters enabled in the config, in the order they are applied to each revolution.
+func (rp *rplidar) setupFilters(conf *Config) {
+	var mounting geometry.Pose
+	if conf.Mounting != nil {
+		mounting = *conf.Mounting
+	}
+
+	var chain, smoothing filters.Chain
+	if conf.BodyMask != nil {
+		rp.mask = filters.NewMaskFilter(*conf.BodyMask, mounting)
+		chain = append(chain, rp.mask)
+	}
+	if conf.ShadowFilter != nil {
+		chain = append(chain, filters.NewShadowFilter(*conf.ShadowFilter))
+	}
+	if conf.OutlierFilter != nil && conf.OutlierFilter.IsEnabled() {
+		smoothing = append(smoothing, filters.NewOutlierFilter(*conf.OutlierFilter))
+	}
+	if conf.MedianFilter != nil {
+		smoothing = append(smoothing, filters.NewMedianFilter(*conf.MedianFilter))
+	}
+	rp.filters = chain
+	rp.smoothing = smoothing
+
+	// Downsampling is applied when the point cloud is served rather than in the chain, so the cache always
+	// holds the full resolution data.
+	if conf.Downsample != nil {
+		rp.downsample = filters.NewDownsampleFilter(*conf.Downsample)
+	}
+}
+
 // setupRPLiDAR starts the motor, if necessary, warms up the device, and ensures data returned to the
 // user is valid.
 func (rp *rplidar) setupRPLidar(ctx context.Context) error {
@@ -206,8 +482,12 @@ func (rp *rplidar) setupRPLidar(ctx context.Context) error {
 	}
 
 	// Perform warmup scans
-	rp.device.driver.StartScan(false, true)
-	rp.nodes = gen.New_measurementNodeHqArray(defaultNodeSize)
+	if err := rp.startScan(); err != nil {
+		return err
+	}
+	if rp.nodes == nil {
+		rp.nodes = gen.New_measurementNodeHqArray(defaultNodeSize)
+	}
 
 	goutils.SelectContextOrWait(ctx, defaultWarmUpTimeout)
 	if _, err := rp.scan(ctx, defaultWarmupNumDiscardedScans); err != nil {
@@ -217,93 +497,267 @@ func (rp *rplidar) setupRPLidar(ctx context.Context) error {
 	return nil
 }
 
-// cachePointCloudLoop is a background process that repeatedly gets point cloud data from the RPLiDAR
-// and caches it for later access.
-func (rp *rplidar) cachePointCloudLoop(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			pc, err := rp.scan(ctx, defaultNumScans)
-			if err != nil {
-				rp.logger.Debugf("issue getting pointcloud to cache: %v", err)
-			}
+// startScan starts scanning in the configured scan mode, or in the device's typical mode when none is set.
+func (rp *rplidar) startScan() error {
+	if rp.scanMode == "" && rp.landmarks == nil {
+		rp.device.driver.StartScan(false, true)
+		return nil
+	}
+
+	modes, err := rp.device.scanModes()
+	if err != nil {
+		return err
+	}
+	mode, err := rp.selectScanMode(modes)
+	if err != nil {
+		return err
+	}
+	rp.logger.Debugf("scanning in the %v mode", mode.Name)
+	if result := rp.device.driver.StartScanExpress(false, mode.ID); Result(result) != ResultOk {
+		return fmt.Errorf("failed to start the %v scan mode: %w", mode.Name, Result(result).Failed())
+	}
+	return nil
+}
 
-			rp.cache.mutex.Lock()
-			rp.cache.pointCloud = pc
-			rp.cache.mutex.Unlock()
+// selectScanMode picks the configured scan mode, or the typical one, out of the modes the device supports. Only
+// the standard mode reports the reflectivity of each return; the capsule modes give every return the same quality,
+// which leaves landmarks with nothing to detect.
+func (rp *rplidar) selectScanMode(modes []ScanMode) (ScanMode, error) {
+	var mode ScanMode
+	found := false
+	names := make([]string, 0, len(modes))
+	for _, m := range modes {
+		names = append(names, m.Name)
+		if (rp.scanMode == "" && m.Typical) || (rp.scanMode != "" && strings.EqualFold(m.Name, rp.scanMode)) {
+			mode, found = m, true
 		}
 	}
+	switch {
+	case !found && rp.scanMode == "":
+		return ScanMode{}, errors.New("the device reported no typical scan mode")
+	case !found:
+		return ScanMode{}, errors.Errorf("scan mode %q is not supported by the device, choose one of %v",
+			rp.scanMode, strings.Join(names, ", "))
+	case rp.landmarks != nil && int(mode.AnswerType) != gen.RPLIDAR_ANS_TYPE_MEASUREMENT:
+		return ScanMode{}, errors.Errorf("landmarks need the reflectivity of each return, which the %v scan mode "+
+			"does not report, set scan_mode to a standard mode", mode.Name)
+	}
+	return mode, nil
 }
 
-// scan uses the serial connection to the RPLiDAR to get data and create a pointcloud from it
-func (rp *rplidar) scan(_ context.Context, numScans int) (pointcloud.PointCloud, error) {
-	rp.device.mutex.Lock()
-	defer rp.device.mutex.Unlock()
+// processRevolution converts filtered nodes to point clouds, caches them and publishes the revolution to subscribers.
+// A nil revolution clears the cache, so that a failing device is not reported with stale data. scanTime is how long
+// the revolution took to measure, or 0 when unknown.
+func (rp *rplidar) processRevolution(scanned []nodes.Node, now time.Time, scanTime time.Duration) {
+	var pc, downsampledPC pointcloud.PointCloud
+	pc, err := pointCloudFromNodes(scanned)
+	if err == nil && rp.downsample != nil {
+		downsampledPC, err = pointCloudFromNodes(rp.downsample.Filter(scanned))
+	}
+	if err != nil {
+		rp.logger.Debugf("issue getting pointcloud to cache: %v", err)
+	}
 
-	pc := pointcloud.NewBasicEmpty()
+	rp.cache.mutex.Lock()
+	rp.cache.pointCloud = pc
+	rp.cache.downsampledPointCloud = downsampledPC
+	rp.cache.nodes = scanned
+	if scanned != nil {
+		rp.cache.timestamp = now
+		rp.cache.scanTime = scanTime
+	}
+	rp.cache.mutex.Unlock()
+
+	if scanned != nil {
+		rp.evaluateZones(scanned, now)
+		rp.extractFeatures(scanned, now)
+		rp.updateOccupancyGrid(scanned, now)
+		rp.detectLandmarks(scanned, now)
+		rp.accumulateSweep(scanned, now, scanTime)
+		rp.subtractBackground(scanned, now)
+		rp.trackObjects(scanned, now)
+		rp.publishRevolution(publisher.Revolution{Timestamp: now, ScanTime: scanTime, Nodes: scanned})
+	}
+}
 
-	var dropCount int
-	nodeCount := int64(defaultNodeSize)
+// scan uses the serial connection to the RPLiDAR to get data and create a pointcloud from it
+func (rp *rplidar) scan(ctx context.Context, numScans int) (pointcloud.PointCloud, error) {
+	scanned, err := rp.scanNodes(ctx, numScans)
+	if err != nil {
+		return nil, err
+	}
+	return pointCloudFromNodes(scanned)
+}
+
+// scanNodes uses the serial connection to the RPLiDAR to get numScans revolutions of data and returns the nodes
+// that pass the configured filters.
+func (rp *rplidar) scanNodes(_ context.Context, numScans int) ([]nodes.Node, error) {
+	var scanned []nodes.Node
 	for i := 0; i < numScans; i++ {
-		result := rp.device.driver.GrabScanDataHq(rp.nodes, &nodeCount, defaultDeviceTimeoutMs)
-		if Result(result) != ResultOk {
-			return nil, fmt.Errorf("bad scan: %w", Result(result).Failed())
+		var err error
+		if rp.rawNodes, err = rp.grabRevolution(rp.rawNodes); err != nil {
+			return nil, err
 		}
-		rp.device.driver.AscendScanData(rp.nodes, nodeCount)
+		scanned = append(scanned, rp.filterRevolution(nodesFromRaw(rp.rawNodes))...)
+	}
+	return scanned, nil
+}
+
+// grabRevolution waits for the next revolution from the driver and copies it into dst, growing it if needed. The
+// device is only held while the revolution is read out of the driver.
+func (rp *rplidar) grabRevolution(dst []gen.MeasurementNodeHq) ([]gen.MeasurementNodeHq, error) {
+	rp.device.mutex.Lock()
+	defer rp.device.mutex.Unlock()
 
-		for pos := 0; pos < int(nodeCount); pos++ {
+	// The count is the size of the buffer going in and the number of nodes read coming out.
+	nodeCount := int64(defaultNodeSize)
+	result := rp.device.driver.GrabScanDataHq(rp.nodes, &nodeCount, defaultDeviceTimeoutMs)
+	if Result(result) != ResultOk {
+		return dst[:0], fmt.Errorf("bad scan: %w", Result(result).Failed())
+	}
+	rp.device.driver.AscendScanData(rp.nodes, nodeCount)
 
-			node := gen.MeasurementNodeHqArray_getitem(rp.nodes, rputils.CastInt(pos))
+	// Copy the whole revolution out of the driver at once; everything after this runs on Go memory.
+	return gen.CopyMeasurementNodesHq(rp.nodes, int(nodeCount), dst), nil
+}
 
-			if node.GetDist_mm_q2() == 0 {
-				dropCount++
-				continue // TODO(erd): okay to skip?
-			}
+// nodesFromRaw converts a revolution copied out of the driver to nodes, skipping the measurements without a return.
+func nodesFromRaw(raw []gen.MeasurementNodeHq) []nodes.Node {
+	revolution := make([]nodes.Node, 0, len(raw))
+	for _, node := range raw {
+		if node.DistMMQ2 == 0 {
+			continue // TODO(erd): okay to skip?
+		}
+		revolution = append(revolution, nodes.FromHQ(node.AngleZQ14, node.DistMMQ2, node.Quality, node.Flag))
+	}
+	return revolution
+}
 
-			nodeAngle := (float64(node.GetAngle_z_q14()) * 90 / (1 << 14))
-			nodeDistance := float64(node.GetDist_mm_q2()) / 4
+// PointCloudFromNodes creates a pointcloud from the given nodes in the same frame and units as NextPointCloud. If there
+// are no nodes, a nil pointcloud is returned.
+func PointCloudFromNodes(scanned []nodes.Node) (pointcloud.PointCloud, error) {
+	return pointCloudFromNodes(scanned)
+}
 
-			// Filter out points below minRange
-			if nodeDistance < rp.minRangeMM {
-				continue
-			}
+// filterRevolution removes the nodes below the minimum range from a revolution, then applies the configured filters.
+func (rp *rplidar) filterRevolution(revolution []nodes.Node) []nodes.Node {
+	return rp.smoothing.Filter(rp.prefilterRevolution(revolution))
+}
 
-			err := pc.Set(pointFrom(utils.DegToRad(nodeAngle), utils.DegToRad(0), nodeDistance/1000, 255))
-			if err != nil {
-				return nil, err
-			}
+// prefilterRevolution removes the nodes below the minimum range from a revolution, then applies the configured
+// filters except for smoothing.
+func (rp *rplidar) prefilterRevolution(revolution []nodes.Node) []nodes.Node {
+	kept := revolution[:0]
+	for _, n := range revolution {
+		// Filter out points below minRange
+		if n.Distance < rp.minRangeMM {
+			continue
 		}
+		kept = append(kept, n)
 	}
-	if pc.Size() == 0 {
+	return rp.filters.Filter(kept)
+}
+
+// pointCloudFromNodes creates a pointcloud from the given nodes, with the quality of each return as its intensity. If
+// there are no nodes, a nil pointcloud is returned.
+func pointCloudFromNodes(scanned []nodes.Node) (pointcloud.PointCloud, error) {
+	if len(scanned) == 0 {
 		return nil, nil
 	}
+
+	pc := pointcloud.NewBasicPointCloud(len(scanned))
+	for _, n := range scanned {
+		err := pc.Set(pointFrom(utils.DegToRad(n.Angle), 0, n.Distance/1000, n.Quality))
+		if err != nil {
+			return nil, err
+		}
+	}
 	return pc, nil
 }
 
 // NextPointCloud returns the current cached point cloud. If no pointcloud has been added to the cache at the
-// point this call is made, it will return an error
-func (rp *rplidar) NextPointCloud(_ context.Context, _ map[string]interface{}) (pointcloud.PointCloud, error) {
+// point this call is made, it will return an error. The configured downsampling can be overridden for a single call
+// by passing a "downsample" extra, either as an object with the same attributes as the config or as false to get the
+// full resolution point cloud. On a nodding mount, it returns the 3D point cloud of the last completed sweep instead.
+// A "foreground" extra set to true returns the returns in front of the learned background.
+func (rp *rplidar) NextPointCloud(_ context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	if foreground, _ := extra[foregroundExtraKey].(bool); foreground {
+		return rp.foregroundPointCloud()
+	}
+	if rp.nodding != nil {
+		return rp.nodding.pointCloud()
+	}
+
 	rp.cache.mutex.RLock()
 	defer rp.cache.mutex.RUnlock()
 
 	if rp.cache.pointCloud == nil {
 		return nil, errors.New("pointcloud has not been saved yet")
 	}
-	return rp.cache.pointCloud, nil
+
+	downsampleExtra, ok := extra[downsampleExtraKey]
+	switch {
+	case ok:
+		downsample, err := downsampleFromExtra(downsampleExtra)
+		if err != nil {
+			return nil, err
+		}
+		if downsample == nil {
+			return rp.cache.pointCloud, nil
+		}
+		return pointCloudFromNodes(downsample.Filter(rp.cache.nodes))
+	case rp.downsample != nil:
+		return rp.cache.downsampledPointCloud, nil
+	default:
+		return rp.cache.pointCloud, nil
+	}
 }
 
-// Images is a part of the camera interface but is not implemented for the RPLiDAR.
-func (rp *rplidar) Images(_ context.Context, _ []string, _ map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
-	return nil, resource.ResponseMetadata{}, errors.New("images unimplemented")
+// downsampleFromExtra parses the "downsample" extra passed to NextPointCloud. A nil filter is returned when the
+// caller asked for the full resolution point cloud.
+func downsampleFromExtra(downsampleExtra interface{}) (*filters.DownsampleFilter, error) {
+	if enabled, ok := downsampleExtra.(bool); ok {
+		if enabled {
+			return nil, errors.New("downsample extra must be false or an object")
+		}
+		return nil, nil
+	}
+
+	var conf filters.DownsampleConfig
+	b, err := json.Marshal(downsampleExtra)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, errors.Wrap(err, "invalid downsample extra")
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid downsample extra")
+	}
+	return filters.NewDownsampleFilter(conf), nil
 }
 
-// Properties returns information regarding the output of the RPLiDAR, in this case that it returns PCDs.
+// Images returns the occupancy grid as a grayscale image when it is configured, and is unimplemented otherwise.
+func (rp *rplidar) Images(
+	_ context.Context,
+	filterSourceNames []string,
+	_ map[string]interface{},
+) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	if rp.occupancyGrid == nil {
+		return nil, resource.ResponseMetadata{}, errors.New("images unimplemented")
+	}
+	return rp.occupancyGridImages(filterSourceNames)
+}
+
+// Properties returns information regarding the output of the RPLiDAR, in this case that it returns PCDs, and PNG
+// images when the occupancy grid is configured.
 func (rp *rplidar) Properties(_ context.Context) (camera.Properties, error) {
 	props := camera.Properties{
 		SupportsPCD: true,
 	}
+	if rp.occupancyGrid != nil {
+		props.MimeTypes = []string{utils.MimeTypePNG}
+	}
 	return props, nil
 }
 
@@ -318,6 +772,9 @@ func (rp *rplidar) Close(_ context.Context) error {
 	// Close background process
 	rp.cancelFunc()
 	rp.cacheBackgroundWorkers.Wait()
+	if err := rp.stopStreaming(); err != nil {
+		rp.logger.Debugf("error closing stream socket: %v", err)
+	}
 	rp.cache.mutex.Lock()
 	defer rp.cache.mutex.Unlock()
 
@@ -325,24 +782,7 @@ func (rp *rplidar) Close(_ context.Context) error {
 	rp.device.mutex.Lock()
 	defer rp.device.mutex.Unlock()
 
-	if rp.device.driver != nil {
-		if rp.nodes != nil {
-			defer func() {
-				gen.Delete_measurementNodeHqArray(rp.nodes)
-				rp.nodes = nil
-			}()
-		}
-		rp.device.driver.Stop()
-		// Stop the motor
-		// Note: S1 RPLiDAR do not require the motor to be stopped during closeout
-		if rplidarModelByteMap[rp.device.model] != S1 {
-			rp.logger.Debug("stopping motor")
-			rp.device.driver.StopMotor()
-		}
-
-		gen.RPlidarDriverDisposeDriver(rp.device.driver)
-		rp.device.driver = nil
-	}
+	rp.closeDevice()
 
 	if _, err := os.Stat(rp.lockFilePath); err == nil {
 		if err := os.Remove(rp.lockFilePath); err != nil {
@@ -353,20 +793,46 @@ func (rp *rplidar) Close(_ context.Context) error {
 	return nil
 }
 
-func pointFrom(yaw, pitch, distance float64, reflectivity uint8) (r3.Vector, pointcloud.Data) {
-	ea := spatialmath.NewEulerAngles()
-	ea.Yaw = yaw
-	ea.Pitch = pitch
+// closeDevice stops scanning and releases the driver. The device mutex must be held.
+func (rp *rplidar) closeDevice() {
+	if rp.device.driver == nil {
+		return
+	}
+	if rp.nodes != nil {
+		defer func() {
+			gen.Delete_measurementNodeHqArray(rp.nodes)
+			rp.nodes = nil
+		}()
+	}
+	if rp.intervalNodes != nil {
+		defer func() {
+			gen.Delete_measurementNodeHqArray(rp.intervalNodes)
+			rp.intervalNodes = nil
+		}()
+	}
+	rp.device.driver.Stop()
+	// Stop the motor
+	// Note: S1 RPLiDAR do not require the motor to be stopped during closeout
+	if rplidarModelByteMap[rp.device.model] != S1 {
+		rp.logger.Debug("stopping motor")
+		rp.device.driver.StopMotor()
+	}
+
+	gen.RPlidarDriverDisposeDriver(rp.device.driver)
+	rp.device.driver = nil
+}
 
-	pose1 := spatialmath.NewPose(r3.Vector{X: 0, Y: 0, Z: 0}, ea)
-	pose2 := spatialmath.NewPoseFromPoint(r3.Vector{X: distance, Y: 0, Z: 0})
-	p := spatialmath.Compose(pose1, pose2).Point()
+// pointFrom returns the point in millimeters at the given yaw, pitch and distance in meters, rotated 180 degrees on
+// the y axis to match the camera frame. It computes the rotation of (distance, 0, 0) directly rather than composing
+// poses, since it runs for every node of every revolution.
+func pointFrom(yaw, pitch, distance float64, reflectivity uint8) (r3.Vector, pointcloud.Data) {
+	sinYaw, cosYaw := math.Sincos(yaw)
+	sinPitch, cosPitch := math.Sincos(pitch)
+	distanceMM := distance * 1000
 
 	// Rotate the point 180 degrees on the y axis. Since lidar data is always 2D, we don't worry
 	// about the Z value.
-	p.X = -p.X
-
-	pos := pointcloud.NewVector(p.X*1000, p.Y*1000, p.Z*1000)
+	pos := pointcloud.NewVector(-distanceMM*cosYaw*cosPitch, distanceMM*sinYaw*cosPitch, -distanceMM*sinPitch)
 	d := pointcloud.NewBasicData()
 	d.SetIntensity(uint16(reflectivity) * 255)
 
@@ -2,17 +2,30 @@ package rplidar
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/golang/geo/r3"
 	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/pointcloud"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/foxglove"
 	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/geometry"
 	"go.viam.com/rplidar/inject"
+	"go.viam.com/rplidar/nodes"
 	"go.viam.com/test"
 )
 
@@ -48,6 +61,74 @@ func TestValidate(t *testing.T) {
 		test.That(t, deps, test.ShouldBeNil)
 		test.That(t, optionalDeps, test.ShouldBeNil)
 	})
+	t.Run("invalid body mask", func(t *testing.T) {
+		cfg := Config{
+			BodyMask: &filters.MaskConfig{PolygonMM: [][2]float64{{0, 0}, {1, 1}}},
+		}
+
+		deps, optionalDeps, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid body_mask")
+		test.That(t, deps, test.ShouldBeNil)
+		test.That(t, optionalDeps, test.ShouldBeNil)
+	})
+	t.Run("body mask in the base frame without mounting", func(t *testing.T) {
+		cfg := Config{
+			BodyMask: &filters.MaskConfig{Frame: filters.MaskFrameBase, PolygonMM: [][2]float64{{0, 0}, {1, 0}, {1, 1}}},
+		}
+
+		_, _, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "body_mask is in the base frame, which needs mounting to be set")
+
+		cfg.Mounting = &geometry.Pose{X: 100}
+		_, _, err = cfg.Validate("")
+		test.That(t, err, test.ShouldBeNil)
+	})
+	t.Run("invalid shadow filter", func(t *testing.T) {
+		cfg := Config{
+			ShadowFilter: &filters.ShadowConfig{MinAngleDeg: 20, MaxAngleDeg: 10},
+		}
+
+		deps, optionalDeps, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid shadow_filter")
+		test.That(t, deps, test.ShouldBeNil)
+		test.That(t, optionalDeps, test.ShouldBeNil)
+	})
+	t.Run("invalid outlier filter", func(t *testing.T) {
+		cfg := Config{
+			OutlierFilter: &filters.OutlierConfig{RadiusMM: -1},
+		}
+
+		deps, optionalDeps, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid outlier_filter")
+		test.That(t, deps, test.ShouldBeNil)
+		test.That(t, optionalDeps, test.ShouldBeNil)
+	})
+	t.Run("invalid median filter", func(t *testing.T) {
+		cfg := Config{
+			MedianFilter: &filters.MedianConfig{WindowSize: -1},
+		}
+
+		deps, optionalDeps, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid median_filter")
+		test.That(t, deps, test.ShouldBeNil)
+		test.That(t, optionalDeps, test.ShouldBeNil)
+	})
+	t.Run("invalid foxglove bridge", func(t *testing.T) {
+		cfg := Config{
+			Foxglove: &foxglove.Config{Port: 0},
+		}
+
+		deps, optionalDeps, err := cfg.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid foxglove")
+		test.That(t, deps, test.ShouldBeNil)
+		test.That(t, optionalDeps, test.ShouldBeNil)
+	})
 }
 
 func TestScan(t *testing.T) {
@@ -89,6 +170,108 @@ func TestScan(t *testing.T) {
 	})
 }
 
+// packedNode is a measurement in the packed layout of rplidar_response_measurement_node_hq_t.
+type packedNode struct {
+	angleZQ14 uint16
+	distMMQ2  uint32
+	quality   uint8
+	flag      uint8
+}
+
+// packNodes writes nodes into buf in the packed layout, growing it if needed, and returns it.
+func packNodes(buf []byte, raw []packedNode) []byte {
+	if len(buf) < 8*len(raw) {
+		buf = make([]byte, 8*len(raw))
+	}
+	for i, node := range raw {
+		binary.LittleEndian.PutUint16(buf[8*i:], node.angleZQ14)
+		binary.LittleEndian.PutUint32(buf[8*i+2:], node.distMMQ2)
+		buf[8*i+6] = node.quality
+		buf[8*i+7] = node.flag
+	}
+	return buf
+}
+
+// pinnedAddress pins buf until the test ends and returns its address, to stand in for a node array of the driver.
+func pinnedAddress(t *testing.T, buf []byte) uintptr {
+	t.Helper()
+	var pinner runtime.Pinner
+	pinner.Pin(&buf[0])
+	t.Cleanup(pinner.Unpin)
+	return uintptr(unsafe.Pointer(&buf[0]))
+}
+
+// injectRevolution connects rp to an injected driver that returns raw as every revolution.
+func injectRevolution(t *testing.T, rp *rplidar, raw []packedNode) {
+	t.Helper()
+	address := pinnedAddress(t, packNodes(nil, raw))
+	injectedNode := inject.NewRPLiDARNodes()
+	injectedNode.SwigcptrFunc = func() uintptr {
+		return address
+	}
+
+	injectedRPlidarDriver := inject.NewRPLiDARDriver()
+	injectedRPlidarDriver.GrabScanDataHqFunc = func(a ...interface{}) uint {
+		args := a[0].([]interface{})
+		*args[1].(*int64) = int64(len(raw))
+		return uint(gen.RESULT_OK)
+	}
+	injectedRPlidarDriver.AscendScanDataFunc = func(_ ...interface{}) uint {
+		return 0
+	}
+
+	rp.device = &rplidarDevice{driver: &injectedRPlidarDriver}
+	rp.nodes = &injectedNode
+}
+
+func TestScanNodes(t *testing.T) {
+	// The second node has no return.
+	rp := &rplidar{minRangeMM: 150}
+	injectRevolution(t, rp, []packedNode{{0, 4000, 47, 0}, {4096, 0, 0, 0}, {8192, 8000, 47, 0}, {12288, 400, 47, 0}})
+
+	scanned, err := rp.scanNodes(context.Background(), 2)
+	test.That(t, err, test.ShouldBeNil)
+	expected := []nodes.Node{
+		{Angle: 0, Distance: 1000, Quality: 47},
+		{Angle: 45, Distance: 2000, Quality: 47},
+	}
+	test.That(t, scanned, test.ShouldResemble, append(expected, expected...))
+}
+
+func TestSelectScanMode(t *testing.T) {
+	modes := []ScanMode{
+		{ID: 0, Name: "Standard", AnswerType: 0x81},
+		{ID: 1, Name: "Express", AnswerType: 0x82},
+		{ID: 3, Name: "Sensitivity", AnswerType: 0x84, Typical: true},
+	}
+
+	t.Run("typical mode by default", func(t *testing.T) {
+		mode, err := (&rplidar{}).selectScanMode(modes)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, mode.Name, test.ShouldEqual, "Sensitivity")
+	})
+
+	t.Run("configured mode", func(t *testing.T) {
+		mode, err := (&rplidar{scanMode: "standard"}).selectScanMode(modes)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, mode.ID, test.ShouldEqual, 0)
+
+		_, err = (&rplidar{scanMode: "Boost"}).selectScanMode(modes)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "Standard, Express, Sensitivity")
+	})
+
+	t.Run("landmarks need a mode that reports reflectivity", func(t *testing.T) {
+		_, err := (&rplidar{landmarks: &landmarkDetector{}}).selectScanMode(modes)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "Sensitivity scan mode does not report")
+
+		mode, err := (&rplidar{scanMode: "Standard", landmarks: &landmarkDetector{}}).selectScanMode(modes)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, mode.Name, test.ShouldEqual, "Standard")
+	})
+}
+
 func TestNextPointCloud(t *testing.T) {
 	ctx := context.Background()
 	rp := rplidar{
@@ -122,6 +305,43 @@ func TestNextPointCloud(t *testing.T) {
 		test.That(t, err, test.ShouldBeNil)
 		test.That(t, pc, test.ShouldResemble, cachedPointCloud)
 	})
+
+	cachedNodes := []nodes.Node{{Angle: 0, Distance: 1000}, {Angle: 0.5, Distance: 900}, {Angle: 90, Distance: 1000}}
+	fullPointCloud, err := pointCloudFromNodes(cachedNodes)
+	test.That(t, err, test.ShouldBeNil)
+	downsampledPointCloud, err := pointCloudFromNodes(cachedNodes[1:])
+	test.That(t, err, test.ShouldBeNil)
+
+	t.Run("returns downsampled pointcloud from cache when configured", func(t *testing.T) {
+		rp.downsample = filters.NewDownsampleFilter(filters.DownsampleConfig{AngularBinDeg: 1})
+		rp.cache.nodes = cachedNodes
+		rp.cache.pointCloud = fullPointCloud
+		rp.cache.downsampledPointCloud = downsampledPointCloud
+
+		pc, err := rp.NextPointCloud(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 2)
+
+		pc, err = rp.NextPointCloud(ctx, map[string]interface{}{"downsample": false})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 3)
+	})
+
+	t.Run("overrides downsampling per call", func(t *testing.T) {
+		rp.downsample = nil
+
+		pc, err := rp.NextPointCloud(ctx, map[string]interface{}{"downsample": map[string]interface{}{"max_points": 1}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, pc.Size(), test.ShouldEqual, 1)
+
+		_, err = rp.NextPointCloud(ctx, map[string]interface{}{"downsample": map[string]interface{}{"max_points": -1}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "max_points must be positive")
+
+		_, err = rp.NextPointCloud(ctx, map[string]interface{}{"downsample": true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "downsample extra must be false or an object")
+	})
 }
 
 func TestProperties(t *testing.T) {
@@ -186,6 +406,33 @@ func TestClose(t *testing.T) {
 	})
 }
 
+func TestCloseAfterError(t *testing.T) {
+	lockFilePath := filepath.Join(t.TempDir(), "rplidar.lock")
+	test.That(t, os.WriteFile(lockFilePath, nil, 0o600), test.ShouldBeNil)
+
+	var workerStopped bool
+	ctx, cancel := context.WithCancel(context.Background())
+	rp := rplidar{
+		device:       &rplidarDevice{},
+		cache:        &dataCache{},
+		cancelFunc:   cancel,
+		lockFilePath: lockFilePath,
+		logger:       logging.NewTestLogger(t),
+	}
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		<-ctx.Done()
+		workerStopped = true
+	}()
+
+	setupErr := errors.New("setup failed")
+	test.That(t, rp.closeAfterError(setupErr), test.ShouldEqual, setupErr)
+	test.That(t, workerStopped, test.ShouldBeTrue)
+	_, err := os.Stat(lockFilePath)
+	test.That(t, os.IsNotExist(err), test.ShouldBeTrue)
+}
+
 func TestUnimplementedFunctions(t *testing.T) {
 	ctx := context.Background()
 	rp := rplidar{}
@@ -198,3 +445,109 @@ func TestUnimplementedFunctions(t *testing.T) {
 		test.That(t, namedImage, test.ShouldBeNil)
 	})
 }
+
+// pointFromPose is the original implementation of pointFrom, which composes spatialmath poses. It is kept as the
+// reference the direct computation is checked against.
+func pointFromPose(yaw, pitch, distance float64) r3.Vector {
+	ea := spatialmath.NewEulerAngles()
+	ea.Yaw = yaw
+	ea.Pitch = pitch
+
+	pose1 := spatialmath.NewPose(r3.Vector{X: 0, Y: 0, Z: 0}, ea)
+	pose2 := spatialmath.NewPoseFromPoint(r3.Vector{X: distance, Y: 0, Z: 0})
+	p := spatialmath.Compose(pose1, pose2).Point()
+	p.X = -p.X
+	return p.Mul(1000)
+}
+
+func TestPointCloudFromNodes(t *testing.T) {
+	pc, err := pointCloudFromNodes([]nodes.Node{{Angle: 0, Distance: 1000, Quality: 47}, {Angle: 180, Distance: 2000, Quality: 200}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, pc.Size(), test.ShouldEqual, 2)
+	// The intensity is the quality of each return, as in the point clouds of rplidar-export.
+	pc.Iterate(0, 0, func(pt r3.Vector, d pointcloud.Data) bool {
+		if pt.X < 0 {
+			test.That(t, d.Intensity(), test.ShouldEqual, 47*255)
+		} else {
+			test.That(t, d.Intensity(), test.ShouldEqual, 200*255)
+		}
+		return true
+	})
+}
+
+func TestPointFrom(t *testing.T) {
+	for yawDeg := 0.0; yawDeg < 360; yawDeg += 7.5 {
+		for _, pitchDeg := range []float64{0, 5, -30, 60} {
+			yaw, pitch := utils.DegToRad(yawDeg), utils.DegToRad(pitchDeg)
+			pos, d := pointFrom(yaw, pitch, 2.5, 255)
+			expected := pointFromPose(yaw, pitch, 2.5)
+			test.That(t, pos.X, test.ShouldAlmostEqual, expected.X, 1e-6)
+			test.That(t, pos.Y, test.ShouldAlmostEqual, expected.Y, 1e-6)
+			test.That(t, pos.Z, test.ShouldAlmostEqual, expected.Z, 1e-6)
+			test.That(t, d.Intensity(), test.ShouldEqual, 255*255)
+		}
+	}
+}
+
+// benchmarkRevolution is a revolution the size of the node buffer, as produced by an S1 in boost mode.
+func benchmarkRevolution() []nodes.Node {
+	revolution := make([]nodes.Node, defaultNodeSize)
+	for i := range revolution {
+		angle := 360 * float64(i) / float64(len(revolution))
+		revolution[i] = nodes.Node{
+			Angle:    angle,
+			Distance: 2000 + 500*math.Sin(utils.DegToRad(3*angle)),
+			Quality:  47,
+		}
+	}
+	return revolution
+}
+
+func BenchmarkPointFrom(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pointFrom(utils.DegToRad(float64(i%360)), 0, 2, 255)
+	}
+}
+
+func BenchmarkPointFromPose(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pointFromPose(utils.DegToRad(float64(i%360)), 0, 2)
+	}
+}
+
+func BenchmarkPointCloudFromNodes(b *testing.B) {
+	revolution := benchmarkRevolution()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pointCloudFromNodes(revolution); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessRevolution(b *testing.B) {
+	processor := NewReplayProcessor(&Config{
+		ShadowFilter:  &filters.ShadowConfig{},
+		OutlierFilter: &filters.OutlierConfig{},
+		MedianFilter:  &filters.MedianConfig{},
+		Downsample:    &filters.DownsampleConfig{AngularBinDeg: 0.5},
+	}, logging.NewTestLogger(b))
+	defer processor.Close()
+	sub := processor.Subscribe(1)
+
+	revolution := benchmarkRevolution()
+	scratch := make([]nodes.Node, len(revolution))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(scratch, revolution)
+		processor.Process(processor.Filter(scratch), time.Now())
+		sub.Drain(0)
+	}
+}
+
+func TestModelValues(t *testing.T) {
+	// The model constants are exported, so their values must not change when constants are added next to them.
+	test.That(t, A1, test.ShouldEqual, RPLiDARModel(8))
+	test.That(t, A3, test.ShouldEqual, RPLiDARModel(9))
+	test.That(t, S1, test.ShouldEqual, RPLiDARModel(10))
+}
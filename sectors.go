@@ -0,0 +1,202 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	defaultSectorDeg = 45.
+
+	// sectorPollInterval is how often the driver is polled for the measurements received since the previous poll.
+	// It is well under the time a 45 degree sector takes at the fastest scan rates.
+	sectorPollInterval = 5 * time.Millisecond
+
+	// syncFlag is RPLIDAR_RESP_HQ_FLAG_SYNCBIT, set on the first measurement of each revolution.
+	syncFlag = 0x1
+)
+
+// SectorConfig describes how partial revolutions are published as the measurements arrive, for consumers that cannot
+// wait for a whole revolution.
+type SectorConfig struct {
+	SectorDeg  float64 `json:"sector_deg,omitempty"`
+	SocketPath string  `json:"socket_path,omitempty"`
+	QueueSize  int     `json:"queue_size,omitempty"`
+}
+
+// Validate checks that the sector attributes are valid.
+func (conf *SectorConfig) Validate() error {
+	if conf.SectorDeg < 0 || conf.SectorDeg > 360 {
+		return errors.New("sector_deg must be between 0 and 360")
+	}
+	if conf.QueueSize < 0 {
+		return errors.New("queue_size must be positive")
+	}
+	return nil
+}
+
+func (conf *SectorConfig) sectorDeg() float64 {
+	if conf.SectorDeg == 0 {
+		return defaultSectorDeg
+	}
+	return conf.SectorDeg
+}
+
+// sectorSplitter splits the measurements received from the driver into fixed angular sectors, starting a new
+// revolution at every measurement with the sync flag. Returns are dropped by range and by the body mask, which judge
+// each return on its own; the other filters need the neighbours of a return and are not applied. It is not safe for
+// concurrent use.
+type sectorSplitter struct {
+	sectorDeg  float64
+	minRangeMM float64
+	// mask is nil when no body mask is configured.
+	mask *filters.MaskFilter
+
+	revolution uint64
+	// index is the sector being filled, or -1 until the first revolution starts.
+	index    int
+	nodes    []nodes.Node
+	lastSent time.Time
+}
+
+func newSectorSplitter(sectorDeg, minRangeMM float64, mask *filters.MaskFilter) *sectorSplitter {
+	return &sectorSplitter{sectorDeg: sectorDeg, minRangeMM: minRangeMM, mask: mask, index: -1}
+}
+
+// add appends the measurements received at now and returns the sectors they complete. A sector is complete once a
+// measurement from a later sector arrives; sectors without any valid measurement are still returned, so that
+// consumers know the sector is clear. Measurements received before the first revolution starts are discarded.
+func (s *sectorSplitter) add(raw []gen.MeasurementNodeHq, now time.Time) []publisher.Revolution {
+	var complete []publisher.Revolution
+	lastIndex := int(math.Ceil(360/s.sectorDeg)) - 1
+	for _, node := range raw {
+		n := nodes.FromHQ(node.AngleZQ14, node.DistMMQ2, node.Quality, node.Flag)
+		index := int(n.Angle / s.sectorDeg)
+		if index > lastIndex {
+			index = lastIndex
+		}
+
+		switch {
+		case node.Flag&syncFlag != 0:
+			if s.index >= 0 {
+				complete = append(complete, s.flush(now))
+			}
+			s.revolution++
+			s.index = index
+		case s.index < 0:
+			continue
+		case index != s.index:
+			complete = append(complete, s.flush(now))
+			s.index = index
+		}
+
+		if node.DistMMQ2 == 0 || n.Distance < s.minRangeMM || (s.mask != nil && s.mask.Contains(n)) {
+			continue
+		}
+		s.nodes = append(s.nodes, n)
+	}
+	return complete
+}
+
+// flush returns the sector being filled and starts an empty one.
+func (s *sectorSplitter) flush(now time.Time) publisher.Revolution {
+	sector := publisher.Revolution{
+		Timestamp: now,
+		Nodes:     s.nodes,
+		Sector: &publisher.Sector{
+			Revolution: s.revolution,
+			Index:      s.index,
+			StartDeg:   float64(s.index) * s.sectorDeg,
+			EndDeg:     math.Min(float64(s.index+1)*s.sectorDeg, 360),
+		},
+	}
+	if !s.lastSent.IsZero() {
+		sector.ScanTime = now.Sub(s.lastSent)
+	}
+	s.lastSent = now
+	s.nodes = nil
+	return sector
+}
+
+// startSectors starts publishing partial revolutions, and serving them on a Unix socket when configured.
+func (rp *rplidar) startSectors(ctx context.Context, conf *SectorConfig) error {
+	rp.sectorPublisher = publisher.New()
+	if conf.SocketPath != "" {
+		listener, err := listenUnix(conf.SocketPath)
+		if err != nil {
+			return errors.Wrap(err, "could not listen on sector socket")
+		}
+		rp.sectorListener = listener
+
+		rp.activeBackgroundWorkers.Add(1)
+		go func() {
+			defer rp.activeBackgroundWorkers.Done()
+			rp.sectorPublisher.Serve(ctx, listener, conf.QueueSize, rp.logger)
+		}()
+	}
+
+	rp.intervalNodes = gen.New_measurementNodeHqArray(defaultNodeSize)
+	splitter := newSectorSplitter(conf.sectorDeg(), rp.minRangeMM, rp.mask)
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.sectorLoop(ctx, splitter)
+	}()
+	return nil
+}
+
+// sectorLoop polls the driver for new measurements and publishes every sector they complete.
+func (rp *rplidar) sectorLoop(ctx context.Context, splitter *sectorSplitter) {
+	ticker := time.NewTicker(sectorPollInterval)
+	defer ticker.Stop()
+
+	var raw []gen.MeasurementNodeHq
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for more := true; more; {
+			var err error
+			if raw, more, err = rp.grabInterval(raw); err != nil {
+				rp.logger.Debugf("issue getting partial revolution: %v", err)
+				break
+			}
+			for _, sector := range splitter.add(raw, time.Now()) {
+				rp.sectorPublisher.Publish(sector)
+			}
+		}
+	}
+}
+
+// grabInterval copies the measurements received since the previous call into dst, growing it if needed. more reports
+// whether the driver holds further measurements than fit in a call.
+//
+// Unlike grabRevolution, this does not hold the device mutex: grabRevolution holds it while waiting for a whole
+// revolution, which would delay every poll by up to a revolution. The driver guards its own buffers, and the driver
+// is only released after the background workers have stopped.
+func (rp *rplidar) grabInterval(dst []gen.MeasurementNodeHq) (_ []gen.MeasurementNodeHq, more bool, err error) {
+	// The count is the size of the buffer going in and the number of nodes read coming out.
+	nodeCount := int64(defaultNodeSize)
+	result := Result(rp.device.driver.GetScanDataWithIntervalHq(rp.intervalNodes, &nodeCount))
+	if result == ResultOpTimeout {
+		// Nothing was received since the previous call.
+		return dst[:0], false, nil
+	}
+	if err := result.Failed(); err != nil {
+		return dst[:0], false, fmt.Errorf("bad interval scan: %w", err)
+	}
+	return gen.CopyMeasurementNodesHq(rp.intervalNodes, int(nodeCount), dst), result == ResultRemainingData, nil
+}
@@ -0,0 +1,182 @@
+package rplidar
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/filters"
+	"go.viam.com/rplidar/gen"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/inject"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func TestSectorConfigValidate(t *testing.T) {
+	conf := SectorConfig{}
+	test.That(t, conf.Validate(), test.ShouldBeNil)
+	test.That(t, conf.sectorDeg(), test.ShouldEqual, defaultSectorDeg)
+
+	conf = SectorConfig{SectorDeg: 400}
+	err := conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "sector_deg must be between 0 and 360")
+
+	conf = SectorConfig{QueueSize: -1}
+	err = conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "queue_size must be positive")
+
+	serviceConf := &Config{
+		Stream:  &StreamConfig{SocketPath: "/tmp/rplidar.sock"},
+		Sectors: &SectorConfig{SocketPath: "/tmp/rplidar.sock"},
+	}
+	_, _, err = serviceConf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "sectors socket_path must differ from the stream socket_path")
+	serviceConf.Sectors.SocketPath = "/tmp/rplidar-sectors.sock"
+	_, _, err = serviceConf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+}
+
+// rawNodes converts packed nodes to the form copied out of the driver.
+func rawNodes(packed []packedNode) []gen.MeasurementNodeHq {
+	raw := make([]gen.MeasurementNodeHq, len(packed))
+	for i, n := range packed {
+		raw[i] = gen.MeasurementNodeHq{AngleZQ14: n.angleZQ14, DistMMQ2: n.distMMQ2, Quality: n.quality, Flag: n.flag}
+	}
+	return raw
+}
+
+func TestSectorSplitter(t *testing.T) {
+	splitter := newSectorSplitter(90, 150, nil)
+	start := time.Now()
+
+	// Angles are in Q14 quarter turns, so 16384 is 90 degrees, and distances are in quarter millimeters.
+	complete := splitter.add(rawNodes([]packedNode{
+		{57344, 4000, 47, 0}, // before the first revolution starts
+		{0, 4000, 47, syncFlag},
+		{8192, 0, 0, 0},      // no return
+		{12288, 400, 47, 0},  // below the minimum range
+		{14336, 6000, 47, 0}, // still in the first sector
+	}), start)
+	test.That(t, complete, test.ShouldBeEmpty)
+
+	later := start.Add(30 * time.Millisecond)
+	complete = splitter.add(rawNodes([]packedNode{
+		{16384, 8000, 47, 0},
+		{32768, 4000, 47, 0},
+		{0, 4000, 47, syncFlag},
+	}), later)
+	test.That(t, len(complete), test.ShouldEqual, 3)
+
+	test.That(t, complete[0].Timestamp, test.ShouldEqual, later)
+	test.That(t, complete[0].ScanTime, test.ShouldEqual, 0)
+	test.That(t, complete[0].Nodes, test.ShouldResemble, []nodes.Node{
+		{Angle: 0, Distance: 1000, Quality: 47, Flag: syncFlag},
+		{Angle: 78.75, Distance: 1500, Quality: 47},
+	})
+	test.That(t, *complete[0].Sector, test.ShouldResemble, publisher.Sector{Revolution: 1, Index: 0, StartDeg: 0, EndDeg: 90})
+	test.That(t, complete[1].Nodes, test.ShouldResemble, []nodes.Node{{Angle: 90, Distance: 2000, Quality: 47}})
+	test.That(t, *complete[1].Sector, test.ShouldResemble, publisher.Sector{Revolution: 1, Index: 1, StartDeg: 90, EndDeg: 180})
+	test.That(t, *complete[2].Sector, test.ShouldResemble, publisher.Sector{Revolution: 1, Index: 2, StartDeg: 180, EndDeg: 270})
+
+	t.Run("the last sector ends at 360 degrees", func(t *testing.T) {
+		splitter := newSectorSplitter(100, 0, nil)
+		complete := splitter.add(rawNodes([]packedNode{{0, 4000, 47, syncFlag}, {63716, 4000, 47, 0}, {0, 4000, 47, syncFlag}}), start)
+		test.That(t, len(complete), test.ShouldEqual, 2)
+		test.That(t, *complete[1].Sector, test.ShouldResemble, publisher.Sector{Revolution: 1, Index: 3, StartDeg: 300, EndDeg: 360})
+	})
+
+	t.Run("the body mask is applied", func(t *testing.T) {
+		// A box behind the lidar, where the 1 m return at 0 degrees lands.
+		mask := filters.NewMaskFilter(filters.MaskConfig{PolygonMM: [][2]float64{{-1100, -50}, {-900, -50}, {-900, 50}, {-1100, 50}}},
+			geometry.Pose{})
+		splitter := newSectorSplitter(90, 0, mask)
+		complete := splitter.add(rawNodes([]packedNode{{0, 4000, 47, syncFlag}, {4096, 4000, 47, 0}, {0, 4000, 47, syncFlag}}), start)
+		test.That(t, len(complete), test.ShouldEqual, 1)
+		test.That(t, complete[0].Nodes, test.ShouldResemble, []nodes.Node{{Angle: 22.5, Distance: 1000, Quality: 47}})
+		// Masking sectors leaves the masked points of the whole revolutions alone.
+		test.That(t, mask.Masked(), test.ShouldBeEmpty)
+	})
+}
+
+func TestSectors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The driver has more measurements than fit in the first call, then none.
+	chunks := [][]packedNode{
+		{{0, 4000, 47, syncFlag}, {8192, 4000, 47, 0}},
+		{{16384, 4000, 47, 0}, {32768, 4000, 47, 0}},
+	}
+	buf := make([]byte, 8*2)
+	address := pinnedAddress(t, buf)
+	injectedNode := inject.NewRPLiDARNodes()
+	injectedNode.SwigcptrFunc = func() uintptr {
+		return address
+	}
+
+	var mutex sync.Mutex
+	injectedRPlidarDriver := inject.NewRPLiDARDriver()
+	injectedRPlidarDriver.GetScanDataWithIntervalHqFunc = func(_ gen.Rplidar_response_measurement_node_hq_t, count *int64) uint {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(chunks) == 0 {
+			return uint(gen.RESULT_OPERATION_TIMEOUT)
+		}
+		packNodes(buf, chunks[0])
+		*count = int64(len(chunks[0]))
+		chunks = chunks[1:]
+		if len(chunks) > 0 {
+			return uint(ResultRemainingData)
+		}
+		return uint(gen.RESULT_OK)
+	}
+
+	rp := &rplidar{
+		device:        &rplidarDevice{driver: &injectedRPlidarDriver},
+		intervalNodes: &injectedNode,
+		logger:        logging.NewTestLogger(t),
+	}
+	test.That(t, rp.startStreaming(ctx, nil), test.ShouldBeNil)
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{subscribeCommand: map[string]interface{}{"sectors": true}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "sectors are not configured")
+
+	rp.sectorPublisher = publisher.New()
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{subscribeCommand: map[string]interface{}{"sectors": true}})
+	test.That(t, err, test.ShouldBeNil)
+	id := resp["subscription_id"]
+	sub := rp.sectorPublisher.Subscribe(10)
+
+	rp.cacheBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.cacheBackgroundWorkers.Done()
+		rp.sectorLoop(ctx, newSectorSplitter(defaultSectorDeg, 0, nil))
+	}()
+
+	for i := 0; i < 3; i++ {
+		sector, err := sub.Next(ctx)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, sector.Sector.Index, test.ShouldEqual, i)
+		test.That(t, len(sector.Nodes), test.ShouldEqual, 1)
+	}
+	cancel()
+	rp.cacheBackgroundWorkers.Wait()
+
+	resp, err = rp.DoCommand(context.Background(), map[string]interface{}{getRevolutionsCommand: map[string]interface{}{"subscription_id": id}})
+	test.That(t, err, test.ShouldBeNil)
+	sectors := resp["revolutions"].([]interface{})
+	test.That(t, len(sectors), test.ShouldEqual, 3)
+	test.That(t, sectors[2].(map[string]interface{})["sector"], test.ShouldResemble, map[string]interface{}{
+		"revolution": 1., "index": 2., "start_deg": 90., "end_deg": 135.,
+	})
+	test.That(t, rp.stopStreaming(), test.ShouldBeNil)
+}
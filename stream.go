@@ -0,0 +1,262 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/foxglove"
+	"go.viam.com/rplidar/publisher"
+)
+
+const (
+	subscribeCommand      = "subscribe"
+	getRevolutionsCommand = "get_revolutions"
+	unsubscribeCommand    = "unsubscribe"
+
+	// DoCommand subscriptions that are not polled for this long are assumed abandoned and removed.
+	subscriptionIdleTimeout = 30 * time.Second
+)
+
+// StreamConfig describes how every revolution is streamed to local subscribers over a Unix socket.
+type StreamConfig struct {
+	SocketPath string `json:"socket_path"`
+	QueueSize  int    `json:"queue_size,omitempty"`
+}
+
+// Validate checks that the stream attributes are valid.
+func (conf *StreamConfig) Validate() error {
+	if conf.SocketPath == "" {
+		return errors.New("socket_path must be set")
+	}
+	if conf.QueueSize < 0 {
+		return errors.New("queue_size must be positive")
+	}
+	return nil
+}
+
+// commandSubscriptions holds the subscriptions created through DoCommand. This data is under mutex protection.
+type commandSubscriptions struct {
+	mutex         sync.Mutex
+	subscriptions map[uint64]*commandSubscription
+}
+
+type commandSubscription struct {
+	sub        *publisher.Subscription
+	lastPolled time.Time
+}
+
+// startStreaming creates the publisher and, if configured, starts serving revolutions on a Unix socket.
+func (rp *rplidar) startStreaming(ctx context.Context, conf *StreamConfig) error {
+	rp.publisher = publisher.New()
+	rp.commandSubscriptions = &commandSubscriptions{subscriptions: map[uint64]*commandSubscription{}}
+	if conf == nil {
+		return nil
+	}
+
+	listener, err := listenUnix(conf.SocketPath)
+	if err != nil {
+		return errors.Wrap(err, "could not listen on stream socket")
+	}
+	rp.streamListener = listener
+
+	rp.activeBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.activeBackgroundWorkers.Done()
+		rp.publisher.Serve(ctx, listener, conf.QueueSize, rp.logger)
+	}()
+	return nil
+}
+
+// listenUnix listens on a Unix socket at path. A socket left behind by a previous session that did not shut down
+// cleanly is removed first, but anything else at path is left alone: a file that is not a socket, or a socket that
+// another server still accepts connections on, is an error.
+func listenUnix(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeSocket == 0:
+		return nil, errors.Errorf("%v exists and is not a socket", path)
+	default:
+		if conn, err := net.Dial("unix", path); err == nil {
+			//nolint:errcheck
+			conn.Close()
+			return nil, errors.Errorf("%v is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, errors.Wrap(err, "could not remove stale socket")
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// startFoxgloveBridge serves revolutions to Foxglove Studio over the Foxglove WebSocket protocol.
+func (rp *rplidar) startFoxgloveBridge(ctx context.Context, conf *foxglove.Config) error {
+	listener, err := net.Listen("tcp", conf.Address())
+	if err != nil {
+		return errors.Wrap(err, "could not listen for foxglove clients")
+	}
+	rp.logger.Infof("serving foxglove bridge on %v", listener.Addr())
+
+	server := foxglove.NewServer(*conf, rp.laserScanOptions(), rp.publisher, rp.logger)
+	rp.activeBackgroundWorkers.Add(1)
+	go func() {
+		defer rp.activeBackgroundWorkers.Done()
+		if err := server.Serve(ctx, listener); err != nil {
+			rp.logger.Warnf("foxglove bridge stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// stopStreaming closes the stream and sector sockets and every subscription. The foxglove bridge stops when its
+// context is cancelled.
+func (rp *rplidar) stopStreaming() error {
+	var err error
+	if rp.streamListener != nil {
+		err = rp.streamListener.Close()
+	}
+	if rp.sectorListener != nil {
+		if closeErr := rp.sectorListener.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if rp.publisher != nil {
+		rp.publisher.Close()
+	}
+	if rp.sectorPublisher != nil {
+		rp.sectorPublisher.Close()
+	}
+	rp.activeBackgroundWorkers.Wait()
+	return err
+}
+
+// publishRevolution sends a revolution to every subscriber and removes abandoned DoCommand subscriptions.
+func (rp *rplidar) publishRevolution(rev publisher.Revolution) {
+	if rp.publisher == nil {
+		return
+	}
+	rp.publisher.Publish(rev)
+
+	rp.commandSubscriptions.mutex.Lock()
+	defer rp.commandSubscriptions.mutex.Unlock()
+	for id, cs := range rp.commandSubscriptions.subscriptions {
+		if time.Since(cs.lastPolled) > subscriptionIdleTimeout {
+			cs.sub.Close()
+			delete(rp.commandSubscriptions.subscriptions, id)
+		}
+	}
+}
+
+// subscribe creates a subscription that is polled with get_revolutions, e.g. {"subscribe": {"queue_size": 20}}. With
+// {"subscribe": {"sectors": true}} the subscription receives partial revolutions instead.
+func (rp *rplidar) subscribe(_ context.Context, args interface{}) (map[string]interface{}, error) {
+	if rp.publisher == nil {
+		return nil, errors.New("streaming is not available")
+	}
+
+	source := rp.publisher
+	queueSize := publisher.DefaultQueueSize
+	if argsMap, ok := args.(map[string]interface{}); ok {
+		if sectors, ok := argsMap["sectors"]; ok {
+			sectorsBool, ok := sectors.(bool)
+			if !ok {
+				return nil, errors.New("sectors must be a boolean")
+			}
+			if sectorsBool {
+				if rp.sectorPublisher == nil {
+					return nil, errors.New("sectors are not configured")
+				}
+				source = rp.sectorPublisher
+			}
+		}
+		if size, ok := argsMap["queue_size"]; ok {
+			sizeFloat, ok := size.(float64)
+			if !ok || sizeFloat < 1 {
+				return nil, errors.New("queue_size must be a positive number")
+			}
+			queueSize = int(sizeFloat)
+		}
+	}
+
+	sub := source.Subscribe(queueSize)
+	rp.commandSubscriptions.mutex.Lock()
+	rp.commandSubscriptions.subscriptions[sub.ID()] = &commandSubscription{sub: sub, lastPolled: time.Now()}
+	rp.commandSubscriptions.mutex.Unlock()
+
+	return map[string]interface{}{"subscription_id": float64(sub.ID())}, nil
+}
+
+// getRevolutions returns the revolutions queued on a subscription without blocking, e.g.
+// {"get_revolutions": {"subscription_id": 1, "max": 5}}.
+func (rp *rplidar) getRevolutions(_ context.Context, args interface{}) (map[string]interface{}, error) {
+	cs, argsMap, err := rp.commandSubscription(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var max int
+	if maxArg, ok := argsMap["max"]; ok {
+		maxFloat, ok := maxArg.(float64)
+		if !ok {
+			return nil, errors.New("max must be a number")
+		}
+		max = int(maxFloat)
+	}
+
+	drained := cs.sub.Drain(max)
+	revolutions := make([]interface{}, 0, len(drained))
+	for _, rev := range drained {
+		revolutions = append(revolutions, rev.Map())
+	}
+	return map[string]interface{}{
+		"revolutions": revolutions,
+		"dropped":     float64(cs.sub.Dropped()),
+	}, nil
+}
+
+// unsubscribe removes a subscription created with subscribe, e.g. {"unsubscribe": {"subscription_id": 1}}.
+func (rp *rplidar) unsubscribe(_ context.Context, args interface{}) (map[string]interface{}, error) {
+	cs, _, err := rp.commandSubscription(args)
+	if err != nil {
+		return nil, err
+	}
+
+	rp.commandSubscriptions.mutex.Lock()
+	delete(rp.commandSubscriptions.subscriptions, cs.sub.ID())
+	rp.commandSubscriptions.mutex.Unlock()
+	cs.sub.Close()
+
+	return map[string]interface{}{}, nil
+}
+
+// commandSubscription looks up the subscription named by the subscription_id argument and marks it as polled.
+func (rp *rplidar) commandSubscription(args interface{}) (*commandSubscription, map[string]interface{}, error) {
+	if rp.commandSubscriptions == nil {
+		return nil, nil, errors.New("streaming is not available")
+	}
+	argsMap, ok := args.(map[string]interface{})
+	if !ok {
+		return nil, nil, errors.New("subscription_id must be given")
+	}
+	id, ok := argsMap["subscription_id"].(float64)
+	if !ok {
+		return nil, nil, errors.New("subscription_id must be a number")
+	}
+
+	rp.commandSubscriptions.mutex.Lock()
+	defer rp.commandSubscriptions.mutex.Unlock()
+	cs, ok := rp.commandSubscriptions.subscriptions[uint64(id)]
+	if !ok {
+		return nil, nil, errors.Errorf("no subscription with id %v, it may have expired", id)
+	}
+	cs.lastPolled = time.Now()
+	return cs, argsMap, nil
+}
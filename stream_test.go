@@ -0,0 +1,143 @@
+package rplidar
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/publisher"
+)
+
+func TestStreamConfigValidate(t *testing.T) {
+	conf := StreamConfig{}
+	err := conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "socket_path must be set")
+
+	conf = StreamConfig{SocketPath: "/tmp/rplidar.sock", QueueSize: -1}
+	err = conf.Validate()
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "queue_size must be positive")
+}
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("a file that is not a socket is kept", func(t *testing.T) {
+		path := filepath.Join(dir, "notes.txt")
+		test.That(t, os.WriteFile(path, []byte("keep me"), 0o600), test.ShouldBeNil)
+		_, err := listenUnix(path)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "is not a socket")
+		contents, err := os.ReadFile(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, string(contents), test.ShouldEqual, "keep me")
+	})
+
+	t.Run("a live socket is not taken over", func(t *testing.T) {
+		path := filepath.Join(dir, "live.sock")
+		live, err := listenUnix(path)
+		test.That(t, err, test.ShouldBeNil)
+		defer live.Close()
+		go func() {
+			for {
+				conn, err := live.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+
+		_, err = listenUnix(path)
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "in use by another server")
+	})
+
+	t.Run("a stale socket is replaced", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		stale, err := net.Listen("unix", path)
+		test.That(t, err, test.ShouldBeNil)
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		test.That(t, stale.Close(), test.ShouldBeNil)
+
+		listener, err := listenUnix(path)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, listener.Close(), test.ShouldBeNil)
+	})
+}
+
+func TestStreaming(t *testing.T) {
+	ctx := context.Background()
+	socketPath := filepath.Join(t.TempDir(), "rplidar.sock")
+	rp := rplidar{logger: logging.NewTestLogger(t)}
+	test.That(t, rp.startStreaming(ctx, &StreamConfig{SocketPath: socketPath}), test.ShouldBeNil)
+
+	t.Run("subscriptions receive every published revolution", func(t *testing.T) {
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{subscribeCommand: map[string]interface{}{"queue_size": 2.}})
+		test.That(t, err, test.ShouldBeNil)
+		id := resp["subscription_id"]
+
+		for i := 0; i < 3; i++ {
+			rp.publishRevolution(publisher.Revolution{Nodes: []nodes.Node{{Angle: float64(i), Distance: 1000}}})
+		}
+
+		resp, err = rp.DoCommand(ctx, map[string]interface{}{getRevolutionsCommand: map[string]interface{}{"subscription_id": id}})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(resp["revolutions"].([]interface{})), test.ShouldEqual, 2)
+		test.That(t, resp["dropped"], test.ShouldEqual, 1.)
+
+		_, err = rp.DoCommand(ctx, map[string]interface{}{unsubscribeCommand: map[string]interface{}{"subscription_id": id}})
+		test.That(t, err, test.ShouldBeNil)
+
+		_, err = rp.DoCommand(ctx, map[string]interface{}{getRevolutionsCommand: map[string]interface{}{"subscription_id": id}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "no subscription with id")
+	})
+
+	t.Run("invalid subscription arguments", func(t *testing.T) {
+		_, err := rp.DoCommand(ctx, map[string]interface{}{subscribeCommand: map[string]interface{}{"queue_size": 0.}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "queue_size must be a positive number")
+
+		_, err = rp.DoCommand(ctx, map[string]interface{}{getRevolutionsCommand: true})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, "subscription_id must be given")
+	})
+
+	t.Run("socket clients receive revolutions", func(t *testing.T) {
+		conn, err := net.Dial("unix", socketPath)
+		test.That(t, err, test.ShouldBeNil)
+		defer conn.Close()
+
+		lines := make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}()
+
+		// The connection subscribes asynchronously, so keep publishing until a revolution arrives.
+		var line string
+		for line == "" {
+			rp.publishRevolution(publisher.Revolution{Nodes: []nodes.Node{{Angle: 1, Distance: 1000}}})
+			select {
+			case line = <-lines:
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		test.That(t, line, test.ShouldContainSubstring, `"distances_mm":[1000]`)
+	})
+
+	test.That(t, rp.stopStreaming(), test.ShouldBeNil)
+}
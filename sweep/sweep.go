@@ -0,0 +1,213 @@
+// Package sweep accumulates the revolutions of a lidar on a tilting mount into sweeps, each a full 3D scan.
+package sweep
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	// DefaultMaxRevolutions bounds a sweep when the mount stops moving.
+	DefaultMaxRevolutions = 100
+
+	// historySize is the number of tilt samples kept, a few seconds at the rate they are polled.
+	historySize = 256
+	// maxSampleGap is the longest a node may be from the nearest tilt sample for its tilt to be known.
+	maxSampleGap = 100 * time.Millisecond
+	// minStepDeg is the smallest change of tilt between revolutions that counts as motion, so that noise on a
+	// stationary mount does not read as a reversal.
+	minStepDeg = 0.5
+	// fullTurnDeg is the tilt covered by a sweep of a continuously rotating mount. Half a turn sees the whole
+	// sphere, since the scan plane covers both sides of the rotation axis.
+	fullTurnDeg = 180
+)
+
+// Sample is the tilt of the mount at a time.
+type Sample struct {
+	Time    time.Time
+	TiltDeg float64
+}
+
+// History holds the most recent tilt samples. It is safe for concurrent use.
+type History struct {
+	mutex   sync.Mutex
+	samples []Sample
+}
+
+// NewHistory returns an empty history.
+func NewHistory() *History {
+	return &History{samples: make([]Sample, 0, historySize)}
+}
+
+// Add records a sample. Samples must be added in time order.
+func (h *History) Add(sample Sample) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.samples) == historySize {
+		copy(h.samples, h.samples[1:])
+		h.samples = h.samples[:historySize-1]
+	}
+	h.samples = append(h.samples, sample)
+}
+
+// Latest returns the most recent sample, and false when there is none.
+func (h *History) Latest() (Sample, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.samples) == 0 {
+		return Sample{}, false
+	}
+	return h.samples[len(h.samples)-1], true
+}
+
+// Window returns the samples covering the time from start to end, including the last sample before start and the
+// first after end. It returns false when there is no sample within a short time of either end, since the tilt in
+// between would then be a guess.
+func (h *History) Window(start, end time.Time) (Window, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	first := sort.Search(len(h.samples), func(i int) bool { return h.samples[i].Time.After(start) })
+	if first > 0 {
+		first--
+	}
+	last := sort.Search(len(h.samples), func(i int) bool { return !h.samples[i].Time.Before(end) })
+	if last == len(h.samples) {
+		last--
+	}
+	if last < first {
+		return nil, false
+	}
+	if absDuration(h.samples[first].Time.Sub(start)) > maxSampleGap || absDuration(h.samples[last].Time.Sub(end)) > maxSampleGap {
+		return nil, false
+	}
+	return append(Window{}, h.samples[first:last+1]...), true
+}
+
+// Window is a run of consecutive tilt samples.
+type Window []Sample
+
+// At returns the tilt at a time, interpolated between the samples around it. Times outside of the window get the
+// tilt of the nearest sample.
+func (w Window) At(t time.Time) float64 {
+	i := sort.Search(len(w), func(i int) bool { return !w[i].Time.Before(t) })
+	switch {
+	case i == 0:
+		return w[0].TiltDeg
+	case i == len(w):
+		return w[len(w)-1].TiltDeg
+	}
+	before, after := w[i-1], w[i]
+	span := after.Time.Sub(before.Time)
+	if span <= 0 {
+		return after.TiltDeg
+	}
+	fraction := float64(t.Sub(before.Time)) / float64(span)
+	return before.TiltDeg + fraction*(after.TiltDeg-before.TiltDeg)
+}
+
+// ErrNoTilt is returned when the tilt during a revolution is not known.
+var ErrNoTilt = errors.New("the tilt of the mount is not known during the revolution")
+
+// Tilts returns the tilt of every node of a revolution that ended at end and took scanTime, assuming the nodes
+// are sorted by angle and spread evenly over the revolution.
+func (h *History) Tilts(revolution []nodes.Node, end time.Time, scanTime time.Duration) ([]float64, error) {
+	start := end.Add(-scanTime)
+	window, ok := h.Window(start, end)
+	if !ok {
+		return nil, ErrNoTilt
+	}
+	tilts := make([]float64, len(revolution))
+	for i, n := range revolution {
+		tilts[i] = window.At(start.Add(time.Duration(float64(scanTime) * n.Angle / 360)))
+	}
+	return tilts, nil
+}
+
+// Point is a node with the tilt of the mount when it was measured.
+type Point struct {
+	Node    nodes.Node
+	TiltDeg float64
+}
+
+// Sweep is the nodes of consecutive revolutions over one pass of the mount.
+type Sweep struct {
+	Points      []Point
+	Start       time.Time
+	End         time.Time
+	Revolutions int
+	MinTiltDeg  float64
+	MaxTiltDeg  float64
+}
+
+// Accumulator groups revolutions into sweeps. A sweep ends when a nodding mount reverses, when a rotating mount has
+// covered half a turn, or after a maximum number of revolutions.
+type Accumulator struct {
+	maxRevolutions int
+
+	current   Sweep
+	startTilt float64
+	lastTilt  float64
+	direction int
+}
+
+// NewAccumulator returns an accumulator that ends sweeps after at most maxRevolutions, or DefaultMaxRevolutions
+// when it is zero.
+func NewAccumulator(maxRevolutions int) *Accumulator {
+	if maxRevolutions <= 0 {
+		maxRevolutions = DefaultMaxRevolutions
+	}
+	return &Accumulator{maxRevolutions: maxRevolutions}
+}
+
+// Add adds the nodes of a revolution with their tilts, and returns the sweep it completes, if any. The revolution
+// that completes a sweep is part of it.
+func (a *Accumulator) Add(revolution []nodes.Node, tilts []float64, start, end time.Time) (Sweep, bool) {
+	if len(revolution) == 0 {
+		return Sweep{}, false
+	}
+	if a.current.Revolutions == 0 {
+		a.current = Sweep{Start: start, MinTiltDeg: tilts[0], MaxTiltDeg: tilts[0]}
+		a.startTilt = tilts[0]
+		a.lastTilt = tilts[0]
+		a.direction = 0
+	}
+	for i, n := range revolution {
+		a.current.Points = append(a.current.Points, Point{Node: n, TiltDeg: tilts[i]})
+		a.current.MinTiltDeg = math.Min(a.current.MinTiltDeg, tilts[i])
+		a.current.MaxTiltDeg = math.Max(a.current.MaxTiltDeg, tilts[i])
+	}
+	a.current.Revolutions++
+	a.current.End = end
+
+	endTilt := tilts[len(tilts)-1]
+	reversed := false
+	if step := endTilt - a.lastTilt; math.Abs(step) >= minStepDeg {
+		direction := 1
+		if step < 0 {
+			direction = -1
+		}
+		reversed = a.direction != 0 && direction != a.direction
+		a.direction = direction
+		a.lastTilt = endTilt
+	}
+
+	if !reversed && math.Abs(endTilt-a.startTilt) < fullTurnDeg && a.current.Revolutions < a.maxRevolutions {
+		return Sweep{}, false
+	}
+	completed := a.current
+	a.current = Sweep{}
+	return completed, true
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
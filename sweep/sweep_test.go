@@ -0,0 +1,133 @@
+package sweep
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory()
+	_, ok := h.Latest()
+	test.That(t, ok, test.ShouldBeFalse)
+
+	base := time.Unix(100, 0)
+	// The tilt rises one degree every 10 ms.
+	for i := 0; i <= 20; i++ {
+		h.Add(Sample{Time: base.Add(time.Duration(i) * 10 * time.Millisecond), TiltDeg: float64(i)})
+	}
+	latest, ok := h.Latest()
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, latest.TiltDeg, test.ShouldEqual, 20)
+
+	window, ok := h.Window(base.Add(25*time.Millisecond), base.Add(55*time.Millisecond))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, len(window), test.ShouldEqual, 5)
+	test.That(t, window.At(base.Add(25*time.Millisecond)), test.ShouldAlmostEqual, 2.5)
+	test.That(t, window.At(base.Add(40*time.Millisecond)), test.ShouldAlmostEqual, 4)
+	test.That(t, window.At(base), test.ShouldEqual, 2)
+	test.That(t, window.At(base.Add(time.Second)), test.ShouldEqual, 6)
+
+	// Samples are only trusted close to the revolution.
+	_, ok = h.Window(base.Add(-time.Second), base.Add(50*time.Millisecond))
+	test.That(t, ok, test.ShouldBeFalse)
+	_, ok = h.Window(base.Add(100*time.Millisecond), base.Add(time.Second))
+	test.That(t, ok, test.ShouldBeFalse)
+
+	revolution := []nodes.Node{{Angle: 0}, {Angle: 180}, {Angle: 359}}
+	tilts, err := h.Tilts(revolution, base.Add(200*time.Millisecond), 100*time.Millisecond)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, tilts[0], test.ShouldAlmostEqual, 10)
+	test.That(t, tilts[1], test.ShouldAlmostEqual, 15)
+	test.That(t, tilts[2], test.ShouldAlmostEqual, 19.97, 0.01)
+
+	_, err = h.Tilts(revolution, base.Add(time.Hour), 100*time.Millisecond)
+	test.That(t, err, test.ShouldEqual, ErrNoTilt)
+
+	t.Run("old samples are dropped", func(t *testing.T) {
+		h := NewHistory()
+		for i := 0; i < 2*historySize; i++ {
+			h.Add(Sample{Time: base.Add(time.Duration(i) * time.Millisecond), TiltDeg: float64(i)})
+		}
+		test.That(t, len(h.samples), test.ShouldEqual, historySize)
+		test.That(t, h.samples[0].TiltDeg, test.ShouldEqual, historySize)
+	})
+}
+
+// revolution returns a revolution of n nodes whose tilt rises linearly from fromDeg to toDeg.
+func revolution(n int, fromDeg, toDeg float64) ([]nodes.Node, []float64) {
+	revolution := make([]nodes.Node, n)
+	tilts := make([]float64, n)
+	for i := range revolution {
+		revolution[i] = nodes.Node{Angle: 360 * float64(i) / float64(n), Distance: 1000}
+		tilts[i] = fromDeg + (toDeg-fromDeg)*float64(i)/float64(n-1)
+	}
+	return revolution, tilts
+}
+
+func TestAccumulator(t *testing.T) {
+	base := time.Unix(100, 0)
+	add := func(a *Accumulator, fromDeg, toDeg float64) (Sweep, bool) {
+		revolution, tilts := revolution(4, fromDeg, toDeg)
+		return a.Add(revolution, tilts, base, base.Add(100*time.Millisecond))
+	}
+
+	t.Run("a nodding mount ends a sweep when it reverses", func(t *testing.T) {
+		a := NewAccumulator(0)
+		for tilt := -30.; tilt < 30; tilt += 10 {
+			_, done := add(a, tilt, tilt+10)
+			test.That(t, done, test.ShouldBeFalse)
+		}
+		sweep, done := add(a, 30, 25)
+		test.That(t, done, test.ShouldBeTrue)
+		test.That(t, sweep.Revolutions, test.ShouldEqual, 7)
+		test.That(t, len(sweep.Points), test.ShouldEqual, 28)
+		test.That(t, sweep.MinTiltDeg, test.ShouldEqual, -30)
+		test.That(t, sweep.MaxTiltDeg, test.ShouldEqual, 30)
+
+		// The next sweep runs down, and a pause at the end is not a reversal.
+		for tilt := 25.; tilt > -30; tilt -= 10 {
+			_, done := add(a, tilt, tilt-10)
+			test.That(t, done, test.ShouldBeFalse)
+		}
+		_, done = add(a, -35, -35.2)
+		test.That(t, done, test.ShouldBeFalse)
+		sweep, done = add(a, -35, -30)
+		test.That(t, done, test.ShouldBeTrue)
+		test.That(t, sweep.Revolutions, test.ShouldEqual, 8)
+	})
+
+	t.Run("a rotating mount ends a sweep every half turn", func(t *testing.T) {
+		a := NewAccumulator(0)
+		var revolutions int
+		for tilt := 0.; ; tilt += 20 {
+			revolutions++
+			if sweep, done := add(a, tilt, tilt+20); done {
+				test.That(t, sweep.Revolutions, test.ShouldEqual, 9)
+				break
+			}
+			test.That(t, revolutions, test.ShouldBeLessThan, 9)
+		}
+	})
+
+	t.Run("a stationary mount ends a sweep after the maximum revolutions", func(t *testing.T) {
+		a := NewAccumulator(3)
+		_, done := add(a, 10, 10)
+		test.That(t, done, test.ShouldBeFalse)
+		_, done = add(a, 10, 10)
+		test.That(t, done, test.ShouldBeFalse)
+		sweep, done := add(a, 10, 10)
+		test.That(t, done, test.ShouldBeTrue)
+		test.That(t, sweep.Revolutions, test.ShouldEqual, 3)
+		test.That(t, sweep.Start, test.ShouldEqual, base)
+		test.That(t, sweep.End, test.ShouldEqual, base.Add(100*time.Millisecond))
+	})
+
+	t.Run("empty revolutions are ignored", func(t *testing.T) {
+		_, done := NewAccumulator(1).Add(nil, nil, base, base)
+		test.That(t, done, test.ShouldBeFalse)
+	})
+}
@@ -0,0 +1,158 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/tracking"
+)
+
+const (
+	getTracksCommand   = "get_tracks"
+	resetTracksCommand = "reset_tracks"
+)
+
+// TracksModel is the model of the vision service that reports the objects tracked by an RPLiDAR camera.
+var TracksModel = resource.NewModel("viam", "lidar", "rplidar-tracks")
+
+func init() {
+	resource.RegisterService(vision.API, TracksModel, resource.Registration[vision.Service, *TracksConfig]{
+		Constructor: newTracksService,
+	})
+}
+
+// objectTracker follows the clusters of every revolution. It runs in the camera rather than in a service so that
+// each revolution is tracked, with the time it was captured.
+type objectTracker struct {
+	conf tracking.Config
+
+	mutex     sync.Mutex
+	tracker   *tracking.Tracker
+	timestamp time.Time
+}
+
+// setupTracking enables object tracking when configured.
+func (rp *rplidar) setupTracking(conf *Config) {
+	if conf.Tracking == nil {
+		return
+	}
+	rp.tracker = &objectTracker{conf: *conf.Tracking, tracker: tracking.NewTracker(*conf.Tracking)}
+}
+
+// trackObjects updates the tracks with the clusters of a filtered revolution. When the background is configured,
+// only the foreground is tracked, and nothing is while the background is being learned.
+func (rp *rplidar) trackObjects(scanned []nodes.Node, now time.Time) {
+	o := rp.tracker
+	if o == nil {
+		return
+	}
+	if b := rp.background; b != nil {
+		b.mutex.Lock()
+		learning := b.model.Learning()
+		scanned = b.foreground
+		b.mutex.Unlock()
+		if learning {
+			return
+		}
+	}
+	detections := tracking.Detect(scanned, o.conf)
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.tracker.Update(detections, now)
+	o.timestamp = now
+}
+
+// getTracks returns the confirmed tracks, in the frame of the point cloud. When the occupancy grid is configured,
+// each track also has its bounding box in the pixels of the grid image.
+func (rp *rplidar) getTracks(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	o := rp.tracker
+	if o == nil {
+		return nil, errors.New("tracking is not configured")
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.timestamp.IsZero() {
+		return nil, errors.New("no revolution has been tracked yet")
+	}
+	tracks := o.tracker.Tracks()
+	out := make([]interface{}, 0, len(tracks))
+	for _, track := range tracks {
+		m := track.Map()
+		// Boxes are only drawn for tracks seen in this revolution, since the returns of a missed track are stale.
+		if track.Misses == 0 {
+			if box, ok := rp.gridBox(track.Nodes); ok {
+				m["grid_box_px"] = box
+			}
+		}
+		out = append(out, m)
+	}
+	resp := map[string]interface{}{
+		"timestamp": o.timestamp.Format(time.RFC3339Nano),
+		"tracks":    out,
+	}
+	if rp.occupancyGrid != nil {
+		resp["grid_width_px"] = float64(rp.occupancyGrid.Width())
+	}
+	return resp, nil
+}
+
+// resetTracks drops every track, for example after the lidar was moved.
+func (rp *rplidar) resetTracks(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	o := rp.tracker
+	if o == nil {
+		return nil, errors.New("tracking is not configured")
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.tracker.Reset()
+	return map[string]interface{}{"tracks": []interface{}{}}, nil
+}
+
+// TracksConfig describes how to configure the tracks vision service.
+type TracksConfig struct {
+	// Camera is the name of the rplidar camera whose tracks are reported.
+	Camera string `json:"camera"`
+}
+
+// Validate checks that the camera is set and depends on it.
+func (conf *TracksConfig) Validate(path string) ([]string, []string, error) {
+	if conf.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	return []string{conf.Camera}, nil, nil
+}
+
+// newTracksService reports the objects tracked by an rplidar camera, labelled by their track ID. Detections of
+// moving tracks are scored 1 and those of static tracks 0.5, so that a score threshold keeps only moving objects.
+func newTracksService(
+	_ context.Context,
+	deps resource.Dependencies,
+	c resource.Config,
+	_ logging.Logger,
+) (vision.Service, error) {
+	conf, err := resource.NativeConfig[*TracksConfig](c)
+	if err != nil {
+		return nil, err
+	}
+	return newGridObjectsService(deps, c, conf.Camera, getTracksCommand, "tracks",
+		func(track map[string]interface{}) (string, float64) {
+			id, _ := track["id"].(float64)
+			score := 0.5
+			if moving, _ := track["moving"].(bool); moving {
+				score = 1
+			}
+			return fmt.Sprintf("track-%d", int(id)), score
+		})
+}
@@ -0,0 +1,327 @@
+// Package tracking follows the objects around an RPLiDAR across revolutions, giving each a persistent ID and
+// estimating its velocity with a constant velocity Kalman filter. All distances are in millimeters.
+package tracking
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/clusters"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	defaultMaxSizeMM           = 1000.
+	defaultMaxDistanceMM       = 500.
+	defaultAccelerationMMPerS2 = 2000.
+	defaultMeasurementNoiseMM  = 50.
+	defaultMinHits             = 3
+	defaultMaxMisses           = 5
+	defaultMovingSpeedMMPerS   = 100.
+
+	// initialSpeedMMPerS is the standard deviation of the velocity of a new track, about a running pace.
+	initialSpeedMMPerS = 3000.
+)
+
+// Config describes which clusters are tracked and how they are associated across revolutions.
+type Config struct {
+	// The segmentation attributes are flattened into the tracking ones, which mapstructure only does when asked.
+	clusters.Config `json:",squash"`
+	// MaxSizeMM is the largest extent of a tracked cluster, so that walls are not tracked.
+	MaxSizeMM float64 `json:"max_size_mm,omitempty"`
+	// MaxDistanceMM is the largest distance between the predicted position of a track and the cluster it is
+	// associated with.
+	MaxDistanceMM float64 `json:"max_distance_mm,omitempty"`
+	// AccelerationMMPerS2 is the standard deviation of the acceleration of tracked objects, the process noise of the
+	// filter.
+	AccelerationMMPerS2 float64 `json:"acceleration_mm_per_s2,omitempty"`
+	// MeasurementNoiseMM is the standard deviation of the position of a cluster's centroid.
+	MeasurementNoiseMM float64 `json:"measurement_noise_mm,omitempty"`
+	// MinHits is the number of revolutions a track must be seen in before it is confirmed.
+	MinHits int `json:"min_hits,omitempty"`
+	// MaxMisses is the number of consecutive revolutions a confirmed track may go unseen before it is dropped.
+	MaxMisses int `json:"max_misses,omitempty"`
+	// MovingSpeedMMPerS is the speed above which a track is reported as moving.
+	MovingSpeedMMPerS float64 `json:"moving_speed_mm_per_s,omitempty"`
+}
+
+// Validate checks that the tracking attributes are valid.
+func (conf *Config) Validate() error {
+	if err := conf.Config.Validate(); err != nil {
+		return err
+	}
+	if conf.MaxSizeMM < 0 || conf.MaxDistanceMM < 0 || conf.MeasurementNoiseMM < 0 {
+		return errors.New("distances must be positive")
+	}
+	if conf.AccelerationMMPerS2 < 0 || conf.MovingSpeedMMPerS < 0 {
+		return errors.New("acceleration_mm_per_s2 and moving_speed_mm_per_s must be positive")
+	}
+	if conf.MinHits < 0 || conf.MaxMisses < 0 {
+		return errors.New("min_hits and max_misses must be positive")
+	}
+	return nil
+}
+
+func (conf Config) withDefaults() Config {
+	if conf.MaxSizeMM == 0 {
+		conf.MaxSizeMM = defaultMaxSizeMM
+	}
+	if conf.MaxDistanceMM == 0 {
+		conf.MaxDistanceMM = defaultMaxDistanceMM
+	}
+	if conf.AccelerationMMPerS2 == 0 {
+		conf.AccelerationMMPerS2 = defaultAccelerationMMPerS2
+	}
+	if conf.MeasurementNoiseMM == 0 {
+		conf.MeasurementNoiseMM = defaultMeasurementNoiseMM
+	}
+	if conf.MinHits == 0 {
+		conf.MinHits = defaultMinHits
+	}
+	if conf.MaxMisses == 0 {
+		conf.MaxMisses = defaultMaxMisses
+	}
+	if conf.MovingSpeedMMPerS == 0 {
+		conf.MovingSpeedMMPerS = defaultMovingSpeedMMPerS
+	}
+	return conf
+}
+
+// Detection is a cluster of a revolution that may be tracked.
+type Detection struct {
+	// Center is the centroid of the cluster's returns.
+	Center geometry.Point
+	// SizeMM is the largest distance between two returns of the cluster along either axis.
+	SizeMM float64
+	Nodes  []nodes.Node
+}
+
+// Detect segments a revolution into clusters and returns those small enough to be tracked.
+func Detect(revolution []nodes.Node, conf Config) []Detection {
+	conf = conf.withDefaults()
+	var detections []Detection
+	for _, cluster := range clusters.Segment(revolution, conf.Config) {
+		minPt := geometry.Point{X: math.Inf(1), Y: math.Inf(1)}
+		maxPt := geometry.Point{X: math.Inf(-1), Y: math.Inf(-1)}
+		var center geometry.Point
+		for _, n := range cluster {
+			pt := n.Point()
+			center.X += pt.X / float64(len(cluster))
+			center.Y += pt.Y / float64(len(cluster))
+			minPt = geometry.Point{X: math.Min(minPt.X, pt.X), Y: math.Min(minPt.Y, pt.Y)}
+			maxPt = geometry.Point{X: math.Max(maxPt.X, pt.X), Y: math.Max(maxPt.Y, pt.Y)}
+		}
+		size := math.Max(maxPt.X-minPt.X, maxPt.Y-minPt.Y)
+		if size > conf.MaxSizeMM {
+			continue
+		}
+		detections = append(detections, Detection{Center: center, SizeMM: size, Nodes: cluster})
+	}
+	return detections
+}
+
+// axis is the constant velocity Kalman filter of one axis: the position and velocity, and their covariance.
+type axis struct {
+	position, velocity float64
+	p                  [2][2]float64
+}
+
+func newAxis(position, measurementNoise float64) axis {
+	return axis{
+		position: position,
+		p:        [2][2]float64{{measurementNoise * measurementNoise, 0}, {0, initialSpeedMMPerS * initialSpeedMMPerS}},
+	}
+}
+
+// predict moves the state forward by dt seconds, with white noise acceleration of the given variance.
+func (a *axis) predict(dt, accelerationVariance float64) {
+	a.position += a.velocity * dt
+	p := a.p
+	a.p[0][0] = p[0][0] + dt*(p[0][1]+p[1][0]) + dt*dt*p[1][1] + accelerationVariance*dt*dt*dt*dt/4
+	a.p[0][1] = p[0][1] + dt*p[1][1] + accelerationVariance*dt*dt*dt/2
+	a.p[1][0] = a.p[0][1]
+	a.p[1][1] = p[1][1] + accelerationVariance*dt*dt
+}
+
+// update corrects the state with a measured position of the given variance.
+func (a *axis) update(measured, measurementVariance float64) {
+	p := a.p
+	s := p[0][0] + measurementVariance
+	k0, k1 := p[0][0]/s, p[1][0]/s
+	residual := measured - a.position
+	a.position += k0 * residual
+	a.velocity += k1 * residual
+	a.p[0][0] = (1 - k0) * p[0][0]
+	a.p[0][1] = (1 - k0) * p[0][1]
+	a.p[1][0] = p[1][0] - k1*p[0][0]
+	a.p[1][1] = p[1][1] - k1*p[0][1]
+}
+
+// Track is an object followed across revolutions.
+type Track struct {
+	ID uint64
+	// Position and Velocity are the filtered estimates, in millimeters and millimeters per second in the frame of
+	// the point cloud.
+	Position geometry.Point
+	Velocity geometry.Point
+	// SizeMM and Nodes are from the most recent cluster associated with the track.
+	SizeMM float64
+	Nodes  []nodes.Node
+	// Hits is the number of revolutions the track was seen in, and Misses the number of consecutive revolutions it
+	// was not, during which its position is predicted.
+	Hits      int
+	Misses    int
+	Confirmed bool
+	Moving    bool
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// SpeedMMPerS returns the speed of the track.
+func (t Track) SpeedMMPerS() float64 {
+	return math.Hypot(t.Velocity.X, t.Velocity.Y)
+}
+
+// Map returns the track in a layout suitable for a DoCommand response, including the position of the returns of
+// its most recent cluster.
+func (t Track) Map() map[string]interface{} {
+	points := make([]interface{}, 0, len(t.Nodes))
+	for _, n := range t.Nodes {
+		pt := n.Point()
+		points = append(points, []interface{}{pt.X, pt.Y})
+	}
+	return map[string]interface{}{
+		"id":                float64(t.ID),
+		"position_mm":       []interface{}{t.Position.X, t.Position.Y},
+		"velocity_mm_per_s": []interface{}{t.Velocity.X, t.Velocity.Y},
+		"speed_mm_per_s":    t.SpeedMMPerS(),
+		"heading_deg":       math.Atan2(t.Velocity.Y, t.Velocity.X) * 180 / math.Pi,
+		"size_mm":           t.SizeMM,
+		"hits":              float64(t.Hits),
+		"misses":            float64(t.Misses),
+		"moving":            t.Moving,
+		"first_seen":        t.FirstSeen.Format(time.RFC3339Nano),
+		"last_seen":         t.LastSeen.Format(time.RFC3339Nano),
+		"points_mm":         points,
+	}
+}
+
+type track struct {
+	Track
+	x, y axis
+}
+
+// Tracker associates the detections of each revolution with the tracks of the previous ones. It is not safe for
+// concurrent use.
+type Tracker struct {
+	conf     Config
+	tracks   []*track
+	nextID   uint64
+	lastTime time.Time
+}
+
+// NewTracker returns a tracker without tracks.
+func NewTracker(conf Config) *Tracker {
+	return &Tracker{conf: conf.withDefaults(), nextID: 1}
+}
+
+// Update predicts every track to the time of a revolution, associates its detections with the tracks nearest to
+// them, and starts a track for every detection left over. Tracks that go unseen for too long are dropped.
+func (t *Tracker) Update(detections []Detection, timestamp time.Time) {
+	dt := 0.
+	if !t.lastTime.IsZero() {
+		dt = timestamp.Sub(t.lastTime).Seconds()
+	}
+	t.lastTime = timestamp
+	accelerationVariance := t.conf.AccelerationMMPerS2 * t.conf.AccelerationMMPerS2
+	for _, tr := range t.tracks {
+		tr.x.predict(dt, accelerationVariance)
+		tr.y.predict(dt, accelerationVariance)
+	}
+
+	// Greedily associate the closest pairs of track and detection first.
+	type pair struct {
+		track, detection int
+		distance         float64
+	}
+	var pairs []pair
+	for i, tr := range t.tracks {
+		for j, d := range detections {
+			if distance := math.Hypot(d.Center.X-tr.x.position, d.Center.Y-tr.y.position); distance <= t.conf.MaxDistanceMM {
+				pairs = append(pairs, pair{track: i, detection: j, distance: distance})
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].distance < pairs[b].distance })
+	trackMatched := make([]bool, len(t.tracks))
+	detectionMatched := make([]bool, len(detections))
+	measurementVariance := t.conf.MeasurementNoiseMM * t.conf.MeasurementNoiseMM
+	for _, p := range pairs {
+		if trackMatched[p.track] || detectionMatched[p.detection] {
+			continue
+		}
+		trackMatched[p.track], detectionMatched[p.detection] = true, true
+		tr, d := t.tracks[p.track], detections[p.detection]
+		tr.x.update(d.Center.X, measurementVariance)
+		tr.y.update(d.Center.Y, measurementVariance)
+		tr.SizeMM, tr.Nodes = d.SizeMM, d.Nodes
+		tr.Hits++
+		tr.Misses = 0
+		tr.LastSeen = timestamp
+	}
+
+	kept := t.tracks[:0]
+	for i, tr := range t.tracks {
+		if !trackMatched[i] {
+			tr.Misses++
+			// Tentative tracks are dropped as soon as they are missed, since most are noise.
+			if !tr.Confirmed || tr.Misses > t.conf.MaxMisses {
+				continue
+			}
+		}
+		tr.Confirmed = tr.Confirmed || tr.Hits >= t.conf.MinHits
+		kept = append(kept, tr)
+	}
+	t.tracks = kept
+
+	for j, d := range detections {
+		if detectionMatched[j] {
+			continue
+		}
+		tr := &track{
+			Track: Track{ID: t.nextID, SizeMM: d.SizeMM, Nodes: d.Nodes, Hits: 1, FirstSeen: timestamp, LastSeen: timestamp},
+			x:     newAxis(d.Center.X, t.conf.MeasurementNoiseMM),
+			y:     newAxis(d.Center.Y, t.conf.MeasurementNoiseMM),
+		}
+		tr.Confirmed = tr.Hits >= t.conf.MinHits
+		t.nextID++
+		t.tracks = append(t.tracks, tr)
+	}
+}
+
+// Tracks returns the confirmed tracks, sorted by ID.
+func (t *Tracker) Tracks() []Track {
+	var tracks []Track
+	for _, tr := range t.tracks {
+		if !tr.Confirmed {
+			continue
+		}
+		out := tr.Track
+		out.Position = geometry.Point{X: tr.x.position, Y: tr.y.position}
+		out.Velocity = geometry.Point{X: tr.x.velocity, Y: tr.y.velocity}
+		out.Moving = out.SpeedMMPerS() > t.conf.MovingSpeedMMPerS
+		tracks = append(tracks, out)
+	}
+	sort.Slice(tracks, func(a, b int) bool { return tracks[a].ID < tracks[b].ID })
+	return tracks
+}
+
+// Reset drops every track. IDs keep increasing, so that they are never reused.
+func (t *Tracker) Reset() {
+	t.tracks = nil
+	t.lastTime = time.Time{}
+}
@@ -0,0 +1,143 @@
+package tracking
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/clusters"
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, (&Config{}).Validate(), test.ShouldBeNil)
+	for _, tc := range []struct {
+		conf Config
+		err  string
+	}{
+		{Config{Config: clusters.Config{MinPoints: -1}}, "min_points must be positive"},
+		{Config{MaxDistanceMM: -1}, "distances must be positive"},
+		{Config{AccelerationMMPerS2: -1}, "acceleration_mm_per_s2 and moving_speed_mm_per_s must be positive"},
+		{Config{MaxMisses: -1}, "min_hits and max_misses must be positive"},
+	} {
+		err := tc.conf.Validate()
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, tc.err)
+	}
+}
+
+// scanCircles returns a revolution every half degree of posts of radius 150 mm at the given centers, in the frame of
+// the point cloud.
+func scanCircles(centers ...geometry.Point) []nodes.Node {
+	var revolution []nodes.Node
+	for angle := 0.; angle < 360; angle += 0.5 {
+		// Node angle a lands at 180 - a degrees in the frame of the points.
+		sin, cos := math.Sincos((180 - angle) * math.Pi / 180)
+		nearest := math.Inf(1)
+		for _, c := range centers {
+			// Solve |r (cos, sin) - c| = radius for the nearest positive range r.
+			along := cos*c.X + sin*c.Y
+			discriminant := along*along - (c.X*c.X + c.Y*c.Y - 150*150)
+			if discriminant < 0 {
+				continue
+			}
+			if r := along - math.Sqrt(discriminant); r > 0 && r < nearest {
+				nearest = r
+			}
+		}
+		if !math.IsInf(nearest, 1) {
+			revolution = append(revolution, nodes.Node{Angle: angle, Distance: nearest})
+		}
+	}
+	return revolution
+}
+
+func TestDetect(t *testing.T) {
+	revolution := scanCircles(geometry.Point{X: 1000, Y: 0}, geometry.Point{X: 0, Y: -2000})
+	// A wall is too large to be tracked.
+	for angle := 80.; angle <= 100; angle += 0.5 {
+		revolution = append(revolution, nodes.Node{Angle: angle, Distance: 3000 / math.Cos((angle-90)*math.Pi/180)})
+	}
+	detections := Detect(revolution, Config{})
+	test.That(t, len(detections), test.ShouldEqual, 2)
+	for _, d := range detections {
+		test.That(t, d.SizeMM, test.ShouldBeLessThan, 300)
+		test.That(t, len(d.Nodes), test.ShouldBeGreaterThan, 3)
+	}
+	// The centroid of the visible side of a post is in front of its center.
+	test.That(t, detections[0].Center.X, test.ShouldBeBetween, 850, 1000)
+	test.That(t, detections[0].Center.Y, test.ShouldAlmostEqual, 0, 1)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker(Config{MinHits: 3, MaxMisses: 2})
+	start := time.Unix(100, 0)
+	// A person walks along x at 1 m/s past a static post, one revolution every 100 ms.
+	person := func(i int) geometry.Point { return geometry.Point{X: -1500 + 100*float64(i), Y: 1500} }
+	post := geometry.Point{X: 0, Y: -1500}
+	update := func(i int, centers ...geometry.Point) {
+		tracker.Update(Detect(scanCircles(centers...), Config{}), start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	update(0, person(0), post)
+	update(1, person(1), post)
+	test.That(t, tracker.Tracks(), test.ShouldBeEmpty)
+	update(2, person(2), post)
+	tracks := tracker.Tracks()
+	test.That(t, len(tracks), test.ShouldEqual, 2)
+	personID, postID := tracks[0].ID, tracks[1].ID
+	if tracks[0].Position.Y < 0 {
+		personID, postID = postID, personID
+	}
+
+	for i := 3; i < 20; i++ {
+		update(i, person(i), post)
+	}
+	byID := func() map[uint64]Track {
+		m := map[uint64]Track{}
+		for _, tr := range tracker.Tracks() {
+			m[tr.ID] = tr
+		}
+		return m
+	}
+	tracks2 := byID()
+	test.That(t, len(tracks2), test.ShouldEqual, 2)
+	walking := tracks2[personID]
+	// The centroid of the visible side of the person turns towards the lidar as they pass, so it moves a little
+	// slower than they do.
+	test.That(t, walking.Velocity.X, test.ShouldAlmostEqual, 1000, 150)
+	test.That(t, walking.Velocity.Y, test.ShouldAlmostEqual, 0, 50)
+	test.That(t, walking.Moving, test.ShouldBeTrue)
+	test.That(t, walking.Hits, test.ShouldEqual, 20)
+	test.That(t, walking.Map()["heading_deg"], test.ShouldAlmostEqual, 0, 3)
+	test.That(t, tracks2[postID].Moving, test.ShouldBeFalse)
+	test.That(t, tracks2[postID].SpeedMMPerS(), test.ShouldBeLessThan, 50)
+
+	// The person is hidden for two revolutions and keeps their ID, since the track coasts along its velocity.
+	update(20, post)
+	update(21, post)
+	hidden := byID()[personID]
+	test.That(t, hidden.Misses, test.ShouldEqual, 2)
+	test.That(t, hidden.Position.X, test.ShouldAlmostEqual, person(21).X-100, 100)
+	update(22, person(22), post)
+	test.That(t, byID()[personID].Misses, test.ShouldEqual, 0)
+
+	// Gone for longer than max_misses, the track is dropped, and the person gets a new ID when they come back.
+	for i := 23; i < 26; i++ {
+		update(i, post)
+	}
+	_, ok := byID()[personID]
+	test.That(t, ok, test.ShouldBeFalse)
+	for i := 26; i < 29; i++ {
+		update(i, person(i), post)
+	}
+	tracks = tracker.Tracks()
+	test.That(t, len(tracks), test.ShouldEqual, 2)
+	test.That(t, tracks[1].ID, test.ShouldBeGreaterThan, personID)
+
+	tracker.Reset()
+	test.That(t, tracker.Tracks(), test.ShouldBeEmpty)
+}
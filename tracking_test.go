@@ -0,0 +1,141 @@
+package rplidar
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/background"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/occupancy"
+	"go.viam.com/rplidar/tracking"
+)
+
+// postRevolution returns a revolution every half degree of a post of radius 150 mm centered at (x, y) in the frame
+// of the point cloud.
+func postRevolution(x, y float64) []nodes.Node {
+	var scanned []nodes.Node
+	for angle := 0.; angle < 360; angle += 0.5 {
+		// Node angle a lands at 180 - a degrees in the frame of the points.
+		sin, cos := math.Sincos((180 - angle) * math.Pi / 180)
+		along := cos*x + sin*y
+		discriminant := along*along - (x*x + y*y - 150*150)
+		if discriminant < 0 || along <= 0 {
+			continue
+		}
+		scanned = append(scanned, nodes.Node{Angle: angle, Distance: along - math.Sqrt(discriminant)})
+	}
+	return scanned
+}
+
+func TestGetTracks(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getTracksCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "tracking is not configured")
+
+	conf := &Config{Tracking: &tracking.Config{MinHits: -1}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "invalid tracking")
+
+	conf.Tracking = &tracking.Config{}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	rp.setupTracking(conf)
+
+	_, err = rp.DoCommand(ctx, map[string]interface{}{getTracksCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "no revolution has been tracked yet")
+
+	// A person walks along x at 1 m/s, one revolution every 100 ms.
+	start := time.Unix(100, 0)
+	for i := 0; i < 10; i++ {
+		rp.processRevolution(postRevolution(-500+100*float64(i), 1500), start.Add(time.Duration(i)*100*time.Millisecond), 0)
+	}
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getTracksCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp, test.ShouldNotContainKey, "grid_width_px")
+	tracks := resp["tracks"].([]interface{})
+	test.That(t, len(tracks), test.ShouldEqual, 1)
+	track := tracks[0].(map[string]interface{})
+	test.That(t, track["moving"], test.ShouldBeTrue)
+	test.That(t, track["hits"], test.ShouldEqual, 10.)
+	test.That(t, track["heading_deg"], test.ShouldAlmostEqual, 0, 5)
+	test.That(t, track, test.ShouldNotContainKey, "grid_box_px")
+
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{resetTracksCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["tracks"], test.ShouldBeEmpty)
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{getTracksCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["tracks"], test.ShouldBeEmpty)
+
+	t.Run("only the foreground is tracked once the background is learned", func(t *testing.T) {
+		rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+		conf := &Config{Tracking: &tracking.Config{}, Background: &background.Config{LearnRevolutions: 2}}
+		test.That(t, rp.setupBackground(conf), test.ShouldBeNil)
+		rp.setupTracking(conf)
+
+		// A static post is learned as background, so it is never tracked.
+		for i := 0; i < 10; i++ {
+			rp.processRevolution(postRevolution(1000, 0), start.Add(time.Duration(i)*100*time.Millisecond), 0)
+		}
+		resp, err := rp.DoCommand(ctx, map[string]interface{}{getTracksCommand: true})
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, resp["tracks"], test.ShouldBeEmpty)
+	})
+}
+
+func TestTracks(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTestLogger(t)
+
+	_, _, err := (&TracksConfig{}).Validate("path")
+	test.That(t, err, test.ShouldNotBeNil)
+	deps, _, err := (&TracksConfig{Camera: "lidar"}).Validate("path")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+
+	rp := &rplidar{Named: camera.Named("lidar").AsNamed(), cache: &dataCache{}, logger: logger}
+	conf := &Config{Tracking: &tracking.Config{}, OccupancyGrid: &occupancy.Config{ResolutionMM: 100, SizeMM: 6000}}
+	rp.setupTracking(conf)
+	rp.setupOccupancyGrid(conf)
+	svc, err := newTracksService(ctx, resource.Dependencies{camera.Named("lidar"): rp}, resource.Config{
+		Name:                "tracks",
+		API:                 vision.API,
+		ConvertedAttributes: &TracksConfig{Camera: "lidar"},
+	}, logger)
+	test.That(t, err, test.ShouldBeNil)
+
+	// A person walks past a static post.
+	start := time.Unix(100, 0)
+	for i := 0; i < 10; i++ {
+		scanned := append(postRevolution(-500+100*float64(i), 1500), postRevolution(0, -1500)...)
+		rp.processRevolution(scanned, start.Add(time.Duration(i)*100*time.Millisecond), 0)
+	}
+
+	objects, err := svc.GetObjectPointClouds(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(objects), test.ShouldEqual, 2)
+
+	detections, err := svc.DetectionsFromCamera(ctx, "lidar", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(detections), test.ShouldEqual, 2)
+	scores := map[float64]bool{}
+	for _, d := range detections {
+		test.That(t, d.Label(), test.ShouldStartWith, "track-")
+		scores[d.Score()] = true
+	}
+	// The person is moving and the post is not.
+	test.That(t, scores, test.ShouldResemble, map[float64]bool{1: true, 0.5: true})
+}
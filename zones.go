@@ -0,0 +1,163 @@
+// Package rplidar implements a general rplidar LIDAR as a camera.
+package rplidar
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/zones"
+)
+
+const (
+	getZonesCommand      = "get_zones"
+	getZoneEventsCommand = "get_zone_events"
+)
+
+// ZonesModel is the model of the sensor that reports the protection zones of an RPLiDAR camera as readings.
+var ZonesModel = resource.NewModel("viam", "lidar", "rplidar-zones")
+
+func init() {
+	resource.RegisterComponent(sensor.API, ZonesModel, resource.Registration[sensor.Sensor, *ZonesSensorConfig]{
+		Constructor: newZonesSensor,
+	})
+}
+
+// setupZones builds the protection zones enabled in the config.
+func (rp *rplidar) setupZones(conf *Config) {
+	if len(conf.Zones) == 0 {
+		return
+	}
+	var mounting geometry.Pose
+	if conf.Mounting != nil {
+		mounting = *conf.Mounting
+	}
+	rp.zones = zones.NewMonitor(conf.Zones, mounting)
+}
+
+// evaluateZones updates the protection zones with a filtered revolution and logs every change of level.
+func (rp *rplidar) evaluateZones(scanned []nodes.Node, now time.Time) {
+	if rp.zones == nil {
+		return
+	}
+	for _, event := range rp.zones.Evaluate(scanned, now) {
+		if event.To == zones.LevelStop {
+			rp.logger.Warnf("zone %q changed from %v to %v", event.Zone, event.From, event.To)
+		} else {
+			rp.logger.Infof("zone %q changed from %v to %v", event.Zone, event.From, event.To)
+		}
+	}
+}
+
+// getZones returns the state of every protection zone, in the configured order.
+func (rp *rplidar) getZones(_ context.Context, _ interface{}) (map[string]interface{}, error) {
+	if rp.zones == nil {
+		return nil, errors.New("zones are not configured")
+	}
+
+	now := time.Now()
+	states := rp.zones.States()
+	out := make([]interface{}, 0, len(states))
+	for _, state := range states {
+		out = append(out, state.Map(now))
+	}
+	return map[string]interface{}{"zones": out}, nil
+}
+
+// getZoneEvents returns the recent changes of level of the protection zones, oldest first. Only the events after a
+// sequence are returned with {"get_zone_events": {"after_sequence": 12}}.
+func (rp *rplidar) getZoneEvents(_ context.Context, args interface{}) (map[string]interface{}, error) {
+	if rp.zones == nil {
+		return nil, errors.New("zones are not configured")
+	}
+
+	var after uint64
+	if argsMap, ok := args.(map[string]interface{}); ok {
+		if afterArg, ok := argsMap["after_sequence"]; ok {
+			afterFloat, ok := afterArg.(float64)
+			if !ok || afterFloat < 0 {
+				return nil, errors.New("after_sequence must be a positive number")
+			}
+			after = uint64(afterFloat)
+		}
+	}
+
+	events := rp.zones.Events(after)
+	out := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		out = append(out, event.Map())
+	}
+	return map[string]interface{}{"events": out}, nil
+}
+
+// ZonesSensorConfig describes how to configure the zones sensor.
+type ZonesSensorConfig struct {
+	// Camera is the name of the rplidar camera whose zones are reported.
+	Camera string `json:"camera"`
+}
+
+// Validate checks that the camera is set and depends on it.
+func (conf *ZonesSensorConfig) Validate(path string) ([]string, []string, error) {
+	if conf.Camera == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "camera")
+	}
+	return []string{conf.Camera}, nil, nil
+}
+
+// zonesSensor reports the protection zones of an rplidar camera as sensor readings, so that they can be used
+// wherever a sensor can, such as data capture.
+type zonesSensor struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+
+	// camera is only used through DoCommand.
+	camera resource.Resource
+}
+
+func newZonesSensor(_ context.Context, deps resource.Dependencies, c resource.Config, _ logging.Logger) (sensor.Sensor, error) {
+	conf, err := resource.NativeConfig[*ZonesSensorConfig](c)
+	if err != nil {
+		return nil, err
+	}
+	cam, err := resource.FromProvider[resource.Resource](deps, camera.Named(conf.Camera))
+	if err != nil {
+		return nil, err
+	}
+	return &zonesSensor{Named: c.ResourceName().AsNamed(), camera: cam}, nil
+}
+
+// Readings returns the state of each zone of the camera, keyed by zone name.
+func (s *zonesSensor) Readings(ctx context.Context, _ map[string]interface{}) (map[string]interface{}, error) {
+	resp, err := s.camera.DoCommand(ctx, map[string]interface{}{getZonesCommand: true})
+	if err != nil {
+		return nil, err
+	}
+	states, ok := resp["zones"].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("camera %q did not return zones", s.camera.Name().ShortName())
+	}
+
+	readings := make(map[string]interface{}, len(states))
+	for _, state := range states {
+		stateMap, ok := state.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("camera %q returned a malformed zone", s.camera.Name().ShortName())
+		}
+		name, _ := stateMap["name"].(string)
+		readings[name] = stateMap
+	}
+	return readings, nil
+}
+
+// DoCommand passes commands through to the camera, e.g. {"get_zone_events": {"after_sequence": 12}}.
+func (s *zonesSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return s.camera.DoCommand(ctx, cmd)
+}
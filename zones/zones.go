@@ -0,0 +1,378 @@
+// Package zones evaluates 2D protection zones around a robot against each RPLiDAR revolution, such as a stop zone
+// directly in front of a base and a wider warning zone around it.
+package zones
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+const (
+	// FrameSensor expresses a zone in the lidar's own frame.
+	FrameSensor = "sensor"
+	// FrameBase expresses a zone in the robot's base frame, using the lidar's mounting pose.
+	FrameBase = "base"
+
+	// maxEvents is the number of most recent zone transitions kept for polling.
+	maxEvents = 100
+)
+
+// Level is how far into a zone the closest intrusion is.
+type Level int
+
+// The levels of a zone, in increasing severity.
+const (
+	LevelClear Level = iota
+	LevelWarning
+	LevelStop
+)
+
+// String returns the name of the level used in DoCommand responses and readings.
+func (l Level) String() string {
+	switch l {
+	case LevelClear:
+		return "clear"
+	case LevelWarning:
+		return "warning"
+	case LevelStop:
+		return "stop"
+	default:
+		return "unknown"
+	}
+}
+
+// ArcConfig describes a zone bounded by an arc around the origin of its frame. Angles are in degrees,
+// counter-clockwise from the x axis, and the arc runs counter-clockwise from StartDeg to EndDeg. Equal angles cover
+// the full circle.
+type ArcConfig struct {
+	StartDeg          float64 `json:"start_deg"`
+	EndDeg            float64 `json:"end_deg"`
+	WarningDistanceMM float64 `json:"warning_distance_mm,omitempty"`
+	StopDistanceMM    float64 `json:"stop_distance_mm,omitempty"`
+}
+
+// Config describes a protection zone, either as polygons or as an arc. A zone may have only a warning level, only a
+// stop level, or both.
+type Config struct {
+	Name string `json:"name"`
+	// Frame is the frame the zone is expressed in, either "sensor" (the default) or "base".
+	Frame            string       `json:"frame,omitempty"`
+	WarningPolygonMM [][2]float64 `json:"warning_polygon_mm,omitempty"`
+	StopPolygonMM    [][2]float64 `json:"stop_polygon_mm,omitempty"`
+	Arc              *ArcConfig   `json:"arc,omitempty"`
+	// DebounceRevolutions is the number of consecutive revolutions at a new level before the zone changes to it.
+	DebounceRevolutions int `json:"debounce_revolutions,omitempty"`
+}
+
+// Validate checks that the zone attributes are valid.
+func (conf *Config) Validate() error {
+	if conf.Name == "" {
+		return errors.New("name must be set")
+	}
+	switch conf.Frame {
+	case "", FrameSensor, FrameBase:
+	default:
+		return errors.Errorf("frame must be %q or %q", FrameSensor, FrameBase)
+	}
+	if conf.DebounceRevolutions < 0 {
+		return errors.New("debounce_revolutions must be positive")
+	}
+
+	hasPolygon := conf.WarningPolygonMM != nil || conf.StopPolygonMM != nil
+	if hasPolygon == (conf.Arc != nil) {
+		return errors.New("exactly one of arc or warning_polygon_mm and stop_polygon_mm must be set")
+	}
+	if conf.Arc != nil {
+		if conf.Arc.WarningDistanceMM < 0 || conf.Arc.StopDistanceMM < 0 {
+			return errors.New("arc distances must be positive")
+		}
+		if conf.Arc.WarningDistanceMM == 0 && conf.Arc.StopDistanceMM == 0 {
+			return errors.New("arc must set warning_distance_mm or stop_distance_mm")
+		}
+		return nil
+	}
+	for _, polygon := range [][][2]float64{conf.WarningPolygonMM, conf.StopPolygonMM} {
+		if polygon == nil {
+			continue
+		}
+		if err := geometry.PolygonFromConfig(polygon).Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateConfigs checks every zone and that zone names are unique.
+func ValidateConfigs(confs []Config) error {
+	names := map[string]bool{}
+	for i := range confs {
+		if err := confs[i].Validate(); err != nil {
+			return errors.Wrapf(err, "zone %d", i)
+		}
+		if names[confs[i].Name] {
+			return errors.Errorf("zone name %q is used more than once", confs[i].Name)
+		}
+		names[confs[i].Name] = true
+	}
+	return nil
+}
+
+// Intrusion is a point of a revolution inside a zone.
+type Intrusion struct {
+	// Point is in the frame of the zone.
+	Point geometry.Point
+	// DistanceMM is the distance of the point from the origin of the zone's frame.
+	DistanceMM float64
+	Level      Level
+}
+
+// State is the debounced state of a zone.
+type State struct {
+	Name  string
+	Level Level
+	// Since is the time of the revolution that changed the zone to its level.
+	Since time.Time
+	// Closest is the closest intrusion of the most recent revolution, regardless of debouncing, or nil when the
+	// revolution was clear.
+	Closest *Intrusion
+	// UpdatedAt is the time of the most recent revolution evaluated.
+	UpdatedAt time.Time
+}
+
+// Event is a change in the level of a zone.
+type Event struct {
+	// Sequence increases by one for every event, so that pollers can ask for the events after the last one seen.
+	Sequence  uint64
+	Zone      string
+	From      Level
+	To        Level
+	Timestamp time.Time
+	Closest   *Intrusion
+}
+
+type zone struct {
+	conf Config
+	// toZoneFrame maps a point in the lidar's frame into the frame of the zone.
+	toZoneFrame   geometry.Pose
+	warning, stop geometry.Polygon
+	// arcSpan is the angle covered counter-clockwise from arcStart, up to 360.
+	arcStart, arcSpan float64
+	debounce          int
+
+	state State
+	// pending is the level seen for the last pendingCount revolutions, while it differs from the state's level.
+	pending      Level
+	pendingCount int
+}
+
+// classify returns the level of a point in the frame of the zone.
+func (z *zone) classify(pt geometry.Point) (Level, float64) {
+	distance := math.Hypot(pt.X, pt.Y)
+	if z.conf.Arc == nil {
+		switch {
+		case z.stop != nil && z.stop.Contains(pt):
+			return LevelStop, distance
+		case z.warning != nil && z.warning.Contains(pt):
+			return LevelWarning, distance
+		default:
+			return LevelClear, distance
+		}
+	}
+
+	if z.arcSpan < 360 {
+		angle := math.Atan2(pt.Y, pt.X) * 180 / math.Pi
+		if normalizeDeg(angle-z.arcStart) > z.arcSpan {
+			return LevelClear, distance
+		}
+	}
+	switch {
+	case distance <= z.conf.Arc.StopDistanceMM:
+		return LevelStop, distance
+	case distance <= z.conf.Arc.WarningDistanceMM:
+		return LevelWarning, distance
+	default:
+		return LevelClear, distance
+	}
+}
+
+// normalizeDeg returns the angle in the range [0, 360).
+func normalizeDeg(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// Monitor tracks the state of a set of zones across revolutions. It is safe for concurrent use.
+type Monitor struct {
+	mutex    sync.Mutex
+	zones    []*zone
+	events   []Event
+	sequence uint64
+}
+
+// NewMonitor creates a Monitor for the given zones, which must be valid. The mounting pose of the lidar in the base
+// frame is only used by zones expressed in the base frame.
+func NewMonitor(confs []Config, mounting geometry.Pose) *Monitor {
+	m := &Monitor{}
+	for _, conf := range confs {
+		z := &zone{conf: conf, debounce: conf.DebounceRevolutions, state: State{Name: conf.Name}}
+		if z.debounce == 0 {
+			z.debounce = 1
+		}
+		if conf.Frame == FrameBase {
+			z.toZoneFrame = mounting
+		}
+		if conf.WarningPolygonMM != nil {
+			z.warning = geometry.PolygonFromConfig(conf.WarningPolygonMM)
+		}
+		if conf.StopPolygonMM != nil {
+			z.stop = geometry.PolygonFromConfig(conf.StopPolygonMM)
+		}
+		if conf.Arc != nil {
+			z.arcStart = conf.Arc.StartDeg
+			z.arcSpan = normalizeDeg(conf.Arc.EndDeg - conf.Arc.StartDeg)
+			if z.arcSpan == 0 {
+				z.arcSpan = 360
+			}
+		}
+		m.zones = append(m.zones, z)
+	}
+	return m
+}
+
+// Evaluate updates every zone with a revolution and returns the events for the zones whose level changed.
+func (m *Monitor) Evaluate(revolution []nodes.Node, timestamp time.Time) []Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var events []Event
+	for _, z := range m.zones {
+		// The closest intrusion is not necessarily the most severe one for polygons, so both are tracked.
+		level := LevelClear
+		var closest *Intrusion
+		for _, n := range revolution {
+			pt := z.toZoneFrame.Transform(n.Point())
+			pointLevel, distance := z.classify(pt)
+			if pointLevel == LevelClear {
+				continue
+			}
+			if pointLevel > level {
+				level = pointLevel
+			}
+			if closest == nil || distance < closest.DistanceMM {
+				closest = &Intrusion{Point: pt, DistanceMM: distance, Level: pointLevel}
+			}
+		}
+
+		if z.state.Since.IsZero() {
+			z.state.Since = timestamp
+		}
+		z.state.Closest = closest
+		z.state.UpdatedAt = timestamp
+
+		if level == z.state.Level {
+			z.pendingCount = 0
+			continue
+		}
+		if level == z.pending && z.pendingCount > 0 {
+			z.pendingCount++
+		} else {
+			z.pending, z.pendingCount = level, 1
+		}
+		if z.pendingCount < z.debounce {
+			continue
+		}
+
+		m.sequence++
+		event := Event{
+			Sequence:  m.sequence,
+			Zone:      z.conf.Name,
+			From:      z.state.Level,
+			To:        level,
+			Timestamp: timestamp,
+			Closest:   closest,
+		}
+		z.state.Level, z.state.Since, z.pendingCount = level, timestamp, 0
+		events = append(events, event)
+		m.events = append(m.events, event)
+	}
+	if len(m.events) > maxEvents {
+		m.events = append([]Event(nil), m.events[len(m.events)-maxEvents:]...)
+	}
+	return events
+}
+
+// States returns the state of every zone, in the configured order.
+func (m *Monitor) States() []State {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	states := make([]State, 0, len(m.zones))
+	for _, z := range m.zones {
+		states = append(states, z.state)
+	}
+	return states
+}
+
+// Events returns the retained events with a sequence greater than afterSequence, oldest first.
+func (m *Monitor) Events(afterSequence uint64) []Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var events []Event
+	for _, event := range m.events {
+		if event.Sequence > afterSequence {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Map returns the state in a layout suitable for a DoCommand response or sensor readings, computing the time in the
+// current level at now.
+func (s State) Map(now time.Time) map[string]interface{} {
+	m := map[string]interface{}{
+		"name":            s.Name,
+		"state":           s.Level.String(),
+		"since":           s.Since.Format(time.RFC3339Nano),
+		"time_in_state_s": now.Sub(s.Since).Seconds(),
+		"updated_at":      s.UpdatedAt.Format(time.RFC3339Nano),
+	}
+	if s.Closest != nil {
+		m["closest"] = s.Closest.Map()
+	}
+	return m
+}
+
+// Map returns the intrusion in a layout suitable for a DoCommand response.
+func (i Intrusion) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"x_mm":        i.Point.X,
+		"y_mm":        i.Point.Y,
+		"distance_mm": i.DistanceMM,
+		"level":       i.Level.String(),
+	}
+}
+
+// Map returns the event in a layout suitable for a DoCommand response.
+func (e Event) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"sequence":  float64(e.Sequence),
+		"zone":      e.Zone,
+		"from":      e.From.String(),
+		"to":        e.To.String(),
+		"timestamp": e.Timestamp.Format(time.RFC3339Nano),
+	}
+	if e.Closest != nil {
+		m["closest"] = e.Closest.Map()
+	}
+	return m
+}
@@ -0,0 +1,164 @@
+package zones
+
+import (
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/geometry"
+	"go.viam.com/rplidar/nodes"
+)
+
+func TestConfigValidate(t *testing.T) {
+	square := [][2]float64{{-100, -100}, {100, -100}, {100, 100}, {-100, 100}}
+	arc := &ArcConfig{StartDeg: 170, EndDeg: 190, StopDistanceMM: 500}
+
+	for _, tc := range []struct {
+		name string
+		conf Config
+		err  string
+	}{
+		{"polygon zone", Config{Name: "body", StopPolygonMM: square}, ""},
+		{"arc zone", Config{Name: "front", Frame: FrameBase, Arc: arc, DebounceRevolutions: 2}, ""},
+		{"no name", Config{Arc: arc}, "name must be set"},
+		{"unknown frame", Config{Name: "front", Frame: "world", Arc: arc}, `frame must be "sensor" or "base"`},
+		{"negative debounce", Config{Name: "front", Arc: arc, DebounceRevolutions: -1}, "debounce_revolutions must be positive"},
+		{"no shape", Config{Name: "front"}, "exactly one of arc or warning_polygon_mm and stop_polygon_mm must be set"},
+		{
+			"both shapes", Config{Name: "front", Arc: arc, WarningPolygonMM: square},
+			"exactly one of arc or warning_polygon_mm and stop_polygon_mm must be set",
+		},
+		{"no arc distance", Config{Name: "front", Arc: &ArcConfig{EndDeg: 90}}, "arc must set warning_distance_mm or stop_distance_mm"},
+		{"negative arc distance", Config{Name: "front", Arc: &ArcConfig{StopDistanceMM: -1}}, "arc distances must be positive"},
+		{"bad polygon", Config{Name: "body", WarningPolygonMM: square[:2]}, "polygon must have at least 3 vertices"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.conf.Validate()
+			if tc.err == "" {
+				test.That(t, err, test.ShouldBeNil)
+				return
+			}
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldEqual, tc.err)
+		})
+	}
+
+	t.Run("names must be unique", func(t *testing.T) {
+		err := ValidateConfigs([]Config{{Name: "front", Arc: arc}, {Name: "front", Arc: arc}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, `zone name "front" is used more than once`)
+
+		err = ValidateConfigs([]Config{{Name: "front"}})
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "zone 0")
+	})
+}
+
+func TestClassify(t *testing.T) {
+	t.Run("arc", func(t *testing.T) {
+		// Node angle 0 lands on the negative x axis, at 180 degrees in the frame of the points.
+		m := NewMonitor([]Config{{Name: "front", Arc: &ArcConfig{StartDeg: 170, EndDeg: 190, WarningDistanceMM: 1000, StopDistanceMM: 500}}},
+			geometry.Pose{})
+		z := m.zones[0]
+		for _, tc := range []struct {
+			node  nodes.Node
+			level Level
+		}{
+			{nodes.Node{Angle: 0, Distance: 400}, LevelStop},
+			{nodes.Node{Angle: 5, Distance: 800}, LevelWarning},
+			{nodes.Node{Angle: 0, Distance: 1500}, LevelClear},
+			{nodes.Node{Angle: 90, Distance: 100}, LevelClear},
+		} {
+			level, _ := z.classify(tc.node.Point())
+			test.That(t, level, test.ShouldEqual, tc.level)
+		}
+	})
+
+	t.Run("arc across zero degrees", func(t *testing.T) {
+		m := NewMonitor([]Config{{Name: "back", Arc: &ArcConfig{StartDeg: -10, EndDeg: 10, StopDistanceMM: 500}}}, geometry.Pose{})
+		level, _ := m.zones[0].classify(geometry.Point{X: 400, Y: -50})
+		test.That(t, level, test.ShouldEqual, LevelStop)
+		level, _ = m.zones[0].classify(geometry.Point{X: -400, Y: 0})
+		test.That(t, level, test.ShouldEqual, LevelClear)
+	})
+
+	t.Run("polygons in the base frame", func(t *testing.T) {
+		// The lidar is 1 m ahead of the base origin, so a point 200 mm behind it is 800 mm ahead of the base.
+		m := NewMonitor([]Config{{
+			Name:             "front",
+			Frame:            FrameBase,
+			WarningPolygonMM: [][2]float64{{0, -500}, {2000, -500}, {2000, 500}, {0, 500}},
+			StopPolygonMM:    [][2]float64{{0, -500}, {1000, -500}, {1000, 500}, {0, 500}},
+		}}, geometry.Pose{X: 1000})
+		states := m.Evaluate([]nodes.Node{{Angle: 0, Distance: 200}}, time.Now())
+		test.That(t, len(states), test.ShouldEqual, 1)
+		test.That(t, states[0].To, test.ShouldEqual, LevelStop)
+		test.That(t, states[0].Closest.Point, test.ShouldResemble, geometry.Point{X: 800, Y: 0})
+
+		m.Evaluate([]nodes.Node{{Angle: 180, Distance: 800}}, time.Now())
+		test.That(t, m.States()[0].Level, test.ShouldEqual, LevelWarning)
+	})
+}
+
+func TestMonitor(t *testing.T) {
+	m := NewMonitor([]Config{{
+		Name:                "front",
+		Arc:                 &ArcConfig{StartDeg: 90, EndDeg: 270, WarningDistanceMM: 1000, StopDistanceMM: 500},
+		DebounceRevolutions: 2,
+	}}, geometry.Pose{})
+	start := time.Now()
+	at := func(revolution int) time.Time { return start.Add(time.Duration(revolution) * 100 * time.Millisecond) }
+	clear := []nodes.Node{{Angle: 0, Distance: 3000}}
+	stop := []nodes.Node{{Angle: 0, Distance: 3000}, {Angle: 10, Distance: 800}, {Angle: 0, Distance: 400}}
+
+	test.That(t, m.Evaluate(clear, at(0)), test.ShouldBeEmpty)
+	state := m.States()[0]
+	test.That(t, state.Level, test.ShouldEqual, LevelClear)
+	test.That(t, state.Since, test.ShouldEqual, at(0))
+	test.That(t, state.Closest, test.ShouldBeNil)
+
+	// A single revolution is not enough to change level, or to reset the count once back at the current level.
+	test.That(t, m.Evaluate(stop, at(1)), test.ShouldBeEmpty)
+	test.That(t, m.States()[0].Closest.DistanceMM, test.ShouldEqual, 400)
+	test.That(t, m.Evaluate(clear, at(2)), test.ShouldBeEmpty)
+	test.That(t, m.Evaluate(stop, at(3)), test.ShouldBeEmpty)
+
+	events := m.Evaluate(stop, at(4))
+	test.That(t, len(events), test.ShouldEqual, 1)
+	test.That(t, events[0].Sequence, test.ShouldEqual, 1)
+	test.That(t, events[0].From, test.ShouldEqual, LevelClear)
+	test.That(t, events[0].To, test.ShouldEqual, LevelStop)
+	test.That(t, events[0].Closest.Level, test.ShouldEqual, LevelStop)
+
+	m.Evaluate(clear, at(5))
+	m.Evaluate(clear, at(6))
+	state = m.States()[0]
+	test.That(t, state.Level, test.ShouldEqual, LevelClear)
+	test.That(t, state.Since, test.ShouldEqual, at(6))
+	test.That(t, state.UpdatedAt, test.ShouldEqual, at(6))
+
+	test.That(t, len(m.Events(0)), test.ShouldEqual, 2)
+	test.That(t, len(m.Events(1)), test.ShouldEqual, 1)
+	test.That(t, m.Events(2), test.ShouldBeEmpty)
+
+	stateMap := state.Map(at(16))
+	test.That(t, stateMap["state"], test.ShouldEqual, "clear")
+	test.That(t, stateMap["time_in_state_s"], test.ShouldAlmostEqual, 1.)
+	test.That(t, stateMap, test.ShouldNotContainKey, "closest")
+	eventMap := m.Events(0)[0].Map()
+	test.That(t, eventMap["to"], test.ShouldEqual, "stop")
+	test.That(t, eventMap["closest"].(map[string]interface{})["distance_mm"], test.ShouldEqual, 400.)
+
+	t.Run("only the most recent events are kept", func(t *testing.T) {
+		for i := 0; i < maxEvents; i++ {
+			m.Evaluate(stop, at(7))
+			m.Evaluate(stop, at(7))
+			m.Evaluate(clear, at(7))
+			m.Evaluate(clear, at(7))
+		}
+		events := m.Events(0)
+		test.That(t, len(events), test.ShouldEqual, maxEvents)
+		test.That(t, events[len(events)-1].Sequence, test.ShouldEqual, 2+2*maxEvents)
+	})
+}
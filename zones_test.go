@@ -0,0 +1,89 @@
+package rplidar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+
+	"go.viam.com/rplidar/nodes"
+	"go.viam.com/rplidar/zones"
+)
+
+func TestZones(t *testing.T) {
+	ctx := context.Background()
+	rp := &rplidar{cache: &dataCache{}, logger: logging.NewTestLogger(t)}
+
+	_, err := rp.DoCommand(ctx, map[string]interface{}{getZonesCommand: true})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "zones are not configured")
+
+	conf := &Config{Zones: []zones.Config{{
+		Name: "front",
+		Arc:  &zones.ArcConfig{StartDeg: 135, EndDeg: 225, WarningDistanceMM: 1000, StopDistanceMM: 500},
+	}}}
+	_, _, err = conf.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	rp.setupZones(conf)
+
+	rp.processRevolution([]nodes.Node{{Angle: 0, Distance: 400}, {Angle: 90, Distance: 300}}, time.Now(), 0)
+
+	resp, err := rp.DoCommand(ctx, map[string]interface{}{getZonesCommand: true})
+	test.That(t, err, test.ShouldBeNil)
+	states := resp["zones"].([]interface{})
+	test.That(t, len(states), test.ShouldEqual, 1)
+	test.That(t, states[0].(map[string]interface{})["state"], test.ShouldEqual, "stop")
+
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{getZoneEventsCommand: map[string]interface{}{"after_sequence": 0.}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(resp["events"].([]interface{})), test.ShouldEqual, 1)
+	resp, err = rp.DoCommand(ctx, map[string]interface{}{getZoneEventsCommand: map[string]interface{}{"after_sequence": 1.}})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, resp["events"], test.ShouldBeEmpty)
+
+	_, err = rp.DoCommand(ctx, map[string]interface{}{getZoneEventsCommand: map[string]interface{}{"after_sequence": "1"}})
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldEqual, "after_sequence must be a positive number")
+
+	t.Run("invalid zones fail validation", func(t *testing.T) {
+		conf := &Config{Zones: []zones.Config{{Name: "front"}}}
+		_, _, err := conf.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldContainSubstring, "invalid zones")
+	})
+
+	t.Run("base frame zones need the mounting", func(t *testing.T) {
+		conf := &Config{Zones: []zones.Config{{Name: "front", Frame: zones.FrameBase, Arc: conf.Zones[0].Arc}}}
+		_, _, err := conf.Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+		test.That(t, err.Error(), test.ShouldEqual, `zone "front" is in the base frame, which needs mounting to be set`)
+	})
+
+	t.Run("sensor readings", func(t *testing.T) {
+		_, _, err := (&ZonesSensorConfig{}).Validate("path")
+		test.That(t, err, test.ShouldNotBeNil)
+
+		deps, _, err := (&ZonesSensorConfig{Camera: "lidar"}).Validate("path")
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, deps, test.ShouldResemble, []string{"lidar"})
+
+		rp.Named = camera.Named("lidar").AsNamed()
+		s, err := newZonesSensor(ctx, resource.Dependencies{camera.Named("lidar"): rp}, resource.Config{
+			Name:                "zones",
+			API:                 sensor.API,
+			ConvertedAttributes: &ZonesSensorConfig{Camera: "lidar"},
+		}, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeNil)
+
+		readings, err := s.Readings(ctx, nil)
+		test.That(t, err, test.ShouldBeNil)
+		front := readings["front"].(map[string]interface{})
+		test.That(t, front["state"], test.ShouldEqual, "stop")
+		test.That(t, front["closest"].(map[string]interface{})["distance_mm"], test.ShouldEqual, 400.)
+	})
+}